@@ -6,10 +6,15 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/husainaj20/task-manager-api/internal/api"
+	"github.com/husainaj20/task-manager-api/internal/models"
 	"github.com/husainaj20/task-manager-api/internal/service"
 	"github.com/husainaj20/task-manager-api/internal/store"
 )
@@ -20,28 +25,109 @@ func main() {
 		port = "8080"
 	}
 
+	redisAddr := os.Getenv("REDIS_ADDR")
+	if redisAddr == "" {
+		redisAddr = "localhost:6379"
+	}
+
 	var st store.Store
-	if os.Getenv("STORE") == "redis" {
-		addr := os.Getenv("REDIS_ADDR")
-		if addr == "" {
-			addr = "localhost:6379"
-		}
-		r := store.NewRedisStore(addr, "taskmgr")
+	switch os.Getenv("STORE") {
+	case "redis":
+		r := store.NewRedisStore(redisAddr, "taskmgr")
 		st = r
-	} else {
+	case "postgres":
+		pg, err := store.NewPostgresStore(os.Getenv("POSTGRES_DSN"))
+		if err != nil {
+			log.Fatalf("postgres store: %v", err)
+		}
+		defer pg.Close()
+		st = pg
+	case "bolt":
+		bs, err := store.NewBoltStore(filepath.Join(dataDir(), "tasks.db"))
+		if err != nil {
+			log.Fatalf("bolt store: %v", err)
+		}
+		defer bs.Close()
+		st = bs
+	default:
 		ms := store.NewMemoryStore()
 		st = ms
 	}
+	st.SetIdempotencyTTL(envDuration("IDEMPOTENCY_TTL", 24*time.Hour))
 
-	queue := service.NewQueue(8) // 8 workers by default
-	defer queue.Stop()
-
-	queue.SetProcessor(func(ctx context.Context, t *service.TaskWork) error {
+	reg := service.NewRegistry()
+	reg.Register("echo", func(ctx context.Context, t *service.TaskWork) (map[string]any, error) {
 		time.Sleep(150 * time.Millisecond)
-		return st.UpdateStatus(ctx, t.ID, "done", t.Result)
+		return map[string]any{"echo": t.Payload, "processedAt": time.Now().UTC()}, nil
 	})
 
-	h := api.New(st, queue)
+	// an embedded store keeps its queue next to it unless told otherwise
+	queueBackend := os.Getenv("QUEUE")
+	if queueBackend == "" && os.Getenv("STORE") == "bolt" {
+		queueBackend = "bolt"
+	}
+	for taskType, rate := range envRates("TYPE_RATE_LIMITS") {
+		reg.SetRateLimit(taskType, rate)
+	}
+	queueRates := envRates("QUEUE_RATE_LIMITS")
+	queueRetry := envRetryPolicies("QUEUE_RETRY")
+	// with Redis the limits hold across replicas
+	var limiter service.Limiter = service.NewMemoryLimiter()
+	if queueBackend == "redis" {
+		rl := service.NewRedisLimiter(redisAddr, "taskmgr")
+		defer rl.Close()
+		limiter = rl
+	}
+
+	queue := service.NewQueueSet()
+	defer queue.Stop()
+	var queues []*service.Queue
+	for _, qc := range queueConfigs() {
+		q := service.NewQueueWithBroker(newBroker(queueBackend, redisAddr, qc.name), qc.workers)
+		q.SetStore(st)
+		q.SetTimeouts(time.Minute, reg.Timeout)
+		q.SetLimiter(limiter)
+		q.SetRateLimits(queueRates[qc.name], reg.RateLimit)
+		delete(queueRates, qc.name)
+		q.SetRetryPolicy(queueRetry[qc.name])
+		delete(queueRetry, qc.name)
+		queue.Add(qc.name, q)
+		queues = append(queues, q)
+	}
+	for name := range queueRates {
+		log.Fatalf("QUEUE_RATE_LIMITS: unknown queue %q", name)
+	}
+	for name := range queueRetry {
+		log.Fatalf("QUEUE_RETRY: unknown queue %q", name)
+	}
+	queue.SetRouter(reg.Queue)
+
+	// apply stored pauses before any worker starts taking work
+	pauses := service.NewPauseWatcher(st, queue, time.Second)
+	defer pauses.Stop()
+	// finishing tasks release their dependents; the poll is only a safety net
+	resolver := service.NewDependencyResolver(st, queue, time.Minute)
+	defer resolver.Stop()
+	for _, q := range queues {
+		q.SetFinishHandler(resolver.Settle)
+		q.SetProcessor(reg.Process)
+	}
+
+	promoter := service.NewPromoter(st, queue, time.Second)
+	defer promoter.Stop()
+	scheduler := service.NewScheduler(st, queue, time.Second)
+	defer scheduler.Stop()
+
+	janitor := service.NewJanitor(st, service.Retention{
+		models.StatusSucceeded: envDuration("RETAIN_SUCCEEDED", 24*time.Hour),
+		models.StatusFailed:    envDuration("RETAIN_FAILED", 7*24*time.Hour),
+		models.StatusCancelled: envDuration("RETAIN_CANCELLED", 24*time.Hour),
+		models.StatusDead:      envDuration("RETAIN_DEAD", 0),
+	}, time.Minute)
+	defer janitor.Stop()
+
+	h := api.New(st, queue, reg)
+	h.SetJanitor(janitor)
 
 	srv := &http.Server{
 		Addr:    ":" + port,
@@ -66,3 +152,130 @@ func main() {
 	}
 	log.Println("server exited")
 }
+
+type queueConfig struct {
+	name    string
+	workers int
+}
+
+var queueName = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// queueConfigs returns the default queue with WORKERS workers (default 8),
+// followed by the queues QUEUES lists as name=workers pairs, such as
+// "emails=2,reports=1".
+func queueConfigs() []queueConfig {
+	workers := 8
+	if v := os.Getenv("WORKERS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			log.Fatalf("WORKERS: want a positive number, got %q", v)
+		}
+		workers = n
+	}
+	configs := []queueConfig{{name: service.DefaultQueue, workers: workers}}
+	seen := map[string]bool{service.DefaultQueue: true}
+	for _, entry := range strings.Split(os.Getenv("QUEUES"), ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		name, count, _ := strings.Cut(entry, "=")
+		n, err := strconv.Atoi(count)
+		if !queueName.MatchString(name) || seen[name] || err != nil || n < 1 {
+			log.Fatalf("QUEUES: want distinct name=workers pairs with names of a-z, 0-9, _ and -, got %q", entry)
+		}
+		seen[name] = true
+		configs = append(configs, queueConfig{name: name, workers: n})
+	}
+	return configs
+}
+
+// newBroker opens the broker of the named queue. The default queue keeps the
+// keys and file it used before there were named queues.
+func newBroker(backend, redisAddr, name string) service.Broker {
+	switch backend {
+	case "redis":
+		prefix := "taskmgr"
+		if name != service.DefaultQueue {
+			prefix += ":queues:" + name
+		}
+		rb, err := service.NewRedisBroker(redisAddr, prefix)
+		if err != nil {
+			log.Fatalf("redis broker %s: %v", name, err)
+		}
+		return rb
+	case "bolt":
+		file := "queue.db"
+		if name != service.DefaultQueue {
+			file = "queue-" + name + ".db"
+		}
+		bb, err := service.NewBoltBroker(filepath.Join(dataDir(), file))
+		if err != nil {
+			log.Fatalf("bolt broker %s: %v", name, err)
+		}
+		return bb
+	default:
+		return service.NewMemoryBroker()
+	}
+}
+
+// envRates parses a comma-separated list of name=rate pairs, such as
+// "send-sms=50/1m,reports=10/s", from the environment.
+func envRates(env string) map[string]service.Rate {
+	rates := map[string]service.Rate{}
+	for _, entry := range strings.Split(os.Getenv(env), ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		name, v, _ := strings.Cut(entry, "=")
+		rate, err := service.ParseRate(v)
+		if name == "" || err != nil {
+			log.Fatalf("%s: want name=rate pairs, got %q", env, entry)
+		}
+		rates[name] = rate
+	}
+	return rates
+}
+
+// envRetryPolicies parses a comma-separated list of name=policy pairs, such
+// as "emails=5:200ms:1m,reports=1", from the environment.
+func envRetryPolicies(env string) map[string]service.RetryPolicy {
+	policies := map[string]service.RetryPolicy{}
+	for _, entry := range strings.Split(os.Getenv(env), ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		name, v, _ := strings.Cut(entry, "=")
+		p, err := service.ParseRetryPolicy(v)
+		if name == "" || err != nil {
+			log.Fatalf("%s: want name=maxAttempts[:backoff[:maxBackoff]] pairs, got %q", env, entry)
+		}
+		policies[name] = p
+	}
+	return policies
+}
+
+// dataDir returns DATA_DIR (default "data"), creating it if needed.
+func dataDir() string {
+	dir := os.Getenv("DATA_DIR")
+	if dir == "" {
+		dir = "data"
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		log.Fatalf("DATA_DIR: %v", err)
+	}
+	return dir
+}
+
+// envDuration parses a duration such as "72h" from the environment, falling
+// back to def when unset. A zero duration disables expiry.
+func envDuration(name string, def time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Fatalf("%s: %v", name, err)
+	}
+	return d
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/husainaj20/task-manager-api/internal/models"
+)
+
+func (h *Handler) listPauses(c *gin.Context) {
+	pauses, err := h.store.ListPauses(c.Request.Context())
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"pauses": pauses})
+}
+
+func (h *Handler) pauseQueue(c *gin.Context) {
+	h.setPaused(c, models.PauseQueue, c.Param("name"), true)
+}
+
+func (h *Handler) resumeQueue(c *gin.Context) {
+	h.setPaused(c, models.PauseQueue, c.Param("name"), false)
+}
+
+func (h *Handler) pauseType(c *gin.Context) {
+	h.setPaused(c, models.PauseTaskType, c.Param("type"), true)
+}
+
+func (h *Handler) resumeType(c *gin.Context) {
+	h.setPaused(c, models.PauseTaskType, c.Param("type"), false)
+}
+
+// setPaused records a pause of a queue or task type, or removes it, and
+// applies the stored pauses to this replica's queues right away. Other
+// replicas pick the change up from the store.
+func (h *Handler) setPaused(c *gin.Context, scope models.PauseScope, name string, paused bool) {
+	if scope == models.PauseQueue && !h.q.HasQueue(name) {
+		problem(c, http.StatusNotFound, codeNotFound, "unknown queue: "+name)
+		return
+	}
+	if scope == models.PauseTaskType && !h.reg.Has(name) {
+		problem(c, http.StatusNotFound, codeNotFound, "unknown task type: "+name)
+		return
+	}
+	ctx := c.Request.Context()
+	var err error
+	if paused {
+		err = h.store.AddPause(ctx, models.Pause{Scope: scope, Name: name, PausedAt: time.Now().UTC()})
+	} else {
+		err = h.store.RemovePause(ctx, scope, name)
+	}
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	pauses, err := h.store.ListPauses(ctx)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	h.q.ApplyPauses(pauses)
+	c.JSON(http.StatusOK, gin.H{"scope": scope, "name": name, "paused": paused})
+}
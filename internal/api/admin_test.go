@@ -0,0 +1,70 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/husainaj20/task-manager-api/internal/models"
+	"github.com/husainaj20/task-manager-api/internal/service"
+	"github.com/husainaj20/task-manager-api/internal/store"
+)
+
+func TestAdmin_PauseResume(t *testing.T) {
+	mem := store.NewMemoryStore()
+	q := service.NewQueue(1)
+	defer q.Stop()
+	q.SetStore(mem)
+	reg := newTestRegistry()
+	q.SetProcessor(reg.Process)
+	r := New(mem, q, reg).Router()
+
+	for _, path := range []string{"/admin/queues/default/pause", "/admin/types/echo/pause", "/admin/types/echo/pause"} {
+		if rec := doJSON(r, http.MethodPost, path, nil); rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", path, rec.Code, rec.Body.String())
+		}
+	}
+	for _, path := range []string{"/admin/queues/missing/pause", "/admin/types/missing/resume"} {
+		if rec := doJSON(r, http.MethodPost, path, nil); rec.Code != http.StatusNotFound {
+			t.Fatalf("%s: expected 404, got %d", path, rec.Code)
+		}
+	}
+	if !q.Paused() {
+		t.Fatal("expected the queue paused right away")
+	}
+
+	rec := doJSON(r, http.MethodGet, "/admin/pauses", nil)
+	var listed struct {
+		Pauses []models.Pause `json:"pauses"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &listed)
+	if len(listed.Pauses) != 2 || listed.Pauses[0].Scope != models.PauseQueue || listed.Pauses[1].Name != "echo" {
+		t.Fatalf("unexpected pauses %s", rec.Body.String())
+	}
+
+	// intake continues while paused
+	rec = doJSON(r, http.MethodPost, "/tasks", []byte(`{"type":"echo"}`))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected 202 while paused, got %d", rec.Code)
+	}
+	var created models.Task
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	time.Sleep(20 * time.Millisecond)
+	if got, _ := mem.Get(context.Background(), created.ID); got.Status != models.StatusQueued {
+		t.Fatalf("expected the task to wait while paused, got %s", got.Status)
+	}
+
+	doJSON(r, http.MethodPost, "/admin/queues/default/resume", nil)
+	doJSON(r, http.MethodPost, "/admin/types/echo/resume", nil)
+	if !q.WaitIdle(3 * time.Second) {
+		t.Fatal("expected the task to run after resume")
+	}
+	if got, _ := mem.Get(context.Background(), created.ID); got.Status != models.StatusSucceeded {
+		t.Fatalf("expected the task to run after resume, got %s", got.Status)
+	}
+	if ps, _ := mem.ListPauses(context.Background()); len(ps) != 0 {
+		t.Fatalf("expected no pauses left, got %v", ps)
+	}
+}
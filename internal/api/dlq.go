@@ -0,0 +1,145 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/husainaj20/task-manager-api/internal/models"
+	"github.com/husainaj20/task-manager-api/internal/service"
+	"github.com/husainaj20/task-manager-api/internal/store"
+)
+
+// dlqFilter selects dead tasks by type and by a substring of their last
+// error. Empty fields match everything.
+type dlqFilter struct {
+	Type  string `json:"type" form:"type"`
+	Error string `json:"error" form:"error"`
+}
+
+func (f dlqFilter) match(t *models.Task) bool {
+	return (f.Type == "" || t.Type == f.Type) && strings.Contains(t.LastError, f.Error)
+}
+
+func (h *Handler) deadTasks(ctx context.Context, f dlqFilter) ([]*models.Task, error) {
+	dead, err := h.store.ListDead(ctx)
+	if err != nil {
+		return nil, err
+	}
+	matched := make([]*models.Task, 0, len(dead))
+	for _, t := range dead {
+		if f.match(t) {
+			matched = append(matched, t)
+		}
+	}
+	return matched, nil
+}
+
+func (h *Handler) listDLQ(c *gin.Context) {
+	var f dlqFilter
+	if err := c.ShouldBindQuery(&f); err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+	tasks, err := h.deadTasks(c.Request.Context(), f)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tasks": tasks})
+}
+
+// replay requeues a dead task with a fresh retry budget. Its history is kept
+// and new runs are numbered on from it.
+// A non-zero version must match the stored task.
+func (h *Handler) replay(ctx context.Context, id string, version int64) (*models.Task, error) {
+	t, err := h.store.Update(ctx, id, version, func(t *models.Task) error {
+		return t.Transition(models.StatusQueued)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return service.EnqueueTask(ctx, h.store, h.q, t)
+}
+
+func (h *Handler) replayDead(c *gin.Context) {
+	version, ok := ifMatch(c)
+	if !ok {
+		return
+	}
+	t, err := h.replay(c.Request.Context(), c.Param("id"), version)
+	switch {
+	case errors.Is(err, models.ErrInvalidTransition):
+		problem(c, http.StatusConflict, codeInvalidState, "task is not dead: "+err.Error())
+	case err != nil && t != nil:
+		enqueueFailed(c, err)
+	case err != nil:
+		writeError(c, err)
+	default:
+		setETag(c, t)
+		c.JSON(http.StatusAccepted, t)
+	}
+}
+
+// replayDLQ replays every dead task matching the filter in the body.
+func (h *Handler) replayDLQ(c *gin.Context) {
+	var f dlqFilter
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&f); err != nil {
+			badRequest(c, err.Error())
+			return
+		}
+	}
+	ctx := c.Request.Context()
+	tasks, err := h.deadTasks(ctx, f)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	replayed := make([]string, 0, len(tasks))
+	for _, t := range tasks {
+		queued, err := h.replay(ctx, t.ID, t.Version)
+		if err != nil {
+			if errors.Is(err, models.ErrInvalidTransition) || errors.Is(err, store.ErrVersionMismatch) ||
+				errors.Is(err, store.ErrNotFound) {
+				// changed since it was listed
+				continue
+			}
+			if queued != nil {
+				enqueueFailed(c, err, gin.H{"replayed": replayed})
+			} else {
+				writeError(c, err)
+			}
+			return
+		}
+		replayed = append(replayed, t.ID)
+	}
+	c.JSON(http.StatusAccepted, gin.H{"replayed": replayed})
+}
+
+// purgeDLQ deletes the dead tasks matching the query filter.
+func (h *Handler) purgeDLQ(c *gin.Context) {
+	var f dlqFilter
+	if err := c.ShouldBindQuery(&f); err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+	ctx := c.Request.Context()
+	tasks, err := h.deadTasks(ctx, f)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	ids := make([]string, len(tasks))
+	for i, t := range tasks {
+		ids[i] = t.ID
+	}
+	n, err := h.store.PurgeDead(ctx, ids)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"purged": n})
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/husainaj20/task-manager-api/internal/models"
+	"github.com/husainaj20/task-manager-api/internal/service"
+	"github.com/husainaj20/task-manager-api/internal/store"
+)
+
+func createDead(t *testing.T, st store.Store, taskType, lastErr string) *models.Task {
+	t.Helper()
+	ctx := context.Background()
+	task, _, _ := st.CreateOrGetByKey(ctx, "", &models.Task{Type: taskType, Status: models.StatusQueued})
+	st.UpdateStatus(ctx, task.ID, models.StatusRunning, nil)
+	st.RecordAttempt(ctx, task.ID, models.Attempt{Number: 1, Error: lastErr})
+	if err := st.UpdateStatus(ctx, task.ID, models.StatusDead, nil); err != nil {
+		t.Fatalf("mark dead: %v", err)
+	}
+	return task
+}
+
+func TestDLQ_ListReplayPurge(t *testing.T) {
+	mem := store.NewMemoryStore()
+	q := service.NewQueue(1)
+	defer q.Stop()
+	r := New(mem, q, newTestRegistry()).Router()
+
+	a := createDead(t, mem, "echo", "connection refused")
+	createDead(t, mem, "echo", "timeout")
+	createDead(t, mem, "other", "connection refused")
+
+	rec := doJSON(r, http.MethodGet, "/dlq?error=refused", nil)
+	var list struct {
+		Tasks []models.Task `json:"tasks"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &list)
+	if rec.Code != http.StatusOK || len(list.Tasks) != 2 || len(list.Tasks[0].History) != 1 {
+		t.Fatalf("expected 2 dead tasks with history, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = doJSON(r, http.MethodPost, "/dlq/"+a.ID+"/replay", nil)
+	var replayed models.Task
+	json.Unmarshal(rec.Body.Bytes(), &replayed)
+	if rec.Code != http.StatusAccepted || replayed.Status != models.StatusQueued {
+		t.Fatalf("expected replayed task, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec = doJSON(r, http.MethodPost, "/dlq/"+a.ID+"/replay", nil); rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 replaying a live task, got %d", rec.Code)
+	}
+
+	rec = doJSON(r, http.MethodPost, "/dlq/replay", []byte(`{"type":"other"}`))
+	var bulk struct {
+		Replayed []string `json:"replayed"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &bulk)
+	if rec.Code != http.StatusAccepted || len(bulk.Replayed) != 1 {
+		t.Fatalf("expected 1 replayed by filter, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = doJSON(r, http.MethodDelete, "/dlq", nil)
+	var purged struct {
+		Purged int `json:"purged"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &purged)
+	if rec.Code != http.StatusOK || purged.Purged != 1 {
+		t.Fatalf("expected 1 purged, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if dead, _ := mem.ListDead(context.Background()); len(dead) != 0 {
+		t.Fatalf("expected empty DLQ, got %d", len(dead))
+	}
+}
+
+func TestDLQ_ReplayContinuesAttemptNumbers(t *testing.T) {
+	mem := store.NewMemoryStore()
+	q := service.NewQueue(1)
+	defer q.Stop()
+	q.SetStore(mem)
+	q.ConfigureRetry(1, time.Millisecond, 2.0, time.Millisecond, false)
+	reg := newTestRegistry()
+	q.SetProcessor(reg.Process)
+	r := New(mem, q, reg).Router()
+
+	dead := createDead(t, mem, "echo", "connection refused")
+	if rec := doJSON(r, http.MethodPost, "/dlq/"+dead.ID+"/replay", nil); rec.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", rec.Code)
+	}
+	if !q.WaitIdle(time.Second) {
+		t.Fatal("expected the replay to run")
+	}
+	got, _ := mem.Get(context.Background(), dead.ID)
+	if got.Status != models.StatusSucceeded || len(got.History) != 2 || got.History[1].Number != 2 {
+		t.Fatalf("expected the replay recorded as attempt 2, got %s %+v", got.Status, got.History)
+	}
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/husainaj20/task-manager-api/internal/models"
+	"github.com/husainaj20/task-manager-api/internal/store"
+)
+
+// Error codes are part of the API: clients branch on them, so they never
+// change once published. Detail messages may.
+const (
+	codeInvalidRequest  = "invalid_request"
+	codeInvalidCursor   = "invalid_cursor"
+	codeInvalidIfMatch  = "invalid_if_match"
+	codeNotFound        = "not_found"
+	codeUnknownType     = "unknown_task_type"
+	codeUnknownQueue    = "unknown_queue"
+	codeUnknownDep      = "unknown_dependency"
+	codeInvalidWorkflow = "invalid_workflow"
+	codeKeyReused       = "idempotency_key_reused"
+	codeVersionMismatch = "version_mismatch"
+	codeInvalidState    = "invalid_transition"
+	codeConflict        = "conflict"
+	codeUnavailable     = "store_unavailable"
+	codeEnqueueFailed   = "enqueue_failed"
+	codeInternal        = "internal"
+)
+
+// problem writes an RFC 7807 problem details body. The problem type is
+// about:blank, so the title is the status text and code tells problems with
+// the same status apart. ext adds extension members.
+func problem(c *gin.Context, status int, code, detail string, ext ...gin.H) {
+	body := gin.H{}
+	for _, e := range ext {
+		for k, v := range e {
+			body[k] = v
+		}
+	}
+	body["type"] = "about:blank"
+	body["title"] = http.StatusText(status)
+	body["status"] = status
+	body["code"] = code
+	if detail != "" {
+		body["detail"] = detail
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		b = []byte(`{"type":"about:blank","status":500,"code":"internal"}`)
+		status = http.StatusInternalServerError
+	}
+	c.Data(status, "application/problem+json", b)
+	c.Abort()
+}
+
+// badRequest answers a request that failed to bind or validate.
+func badRequest(c *gin.Context, detail string) {
+	problem(c, http.StatusBadRequest, codeInvalidRequest, detail)
+}
+
+// writeError answers with the problem err maps to. Errors without a mapping
+// are logged and answered with a bare 500, so internals do not leak.
+func writeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, store.ErrNotFound), errors.Is(err, store.ErrScheduleNotFound),
+		errors.Is(err, store.ErrWorkflowNotFound):
+		problem(c, http.StatusNotFound, codeNotFound, err.Error())
+	case errors.Is(err, store.ErrVersionMismatch):
+		problem(c, http.StatusPreconditionFailed, codeVersionMismatch, "task was modified; fetch it again for the current ETag")
+	case errors.Is(err, models.ErrInvalidTransition):
+		problem(c, http.StatusConflict, codeInvalidState, err.Error())
+	case errors.Is(err, store.ErrConflict):
+		problem(c, http.StatusConflict, codeConflict, "the task is being changed concurrently; try again")
+	case errors.Is(err, store.ErrInvalidCursor):
+		problem(c, http.StatusBadRequest, codeInvalidCursor, err.Error())
+	case errors.Is(err, store.ErrUnavailable):
+		log.Printf("%s %s: %v", c.Request.Method, c.Request.URL.Path, err)
+		c.Header("Retry-After", "1")
+		problem(c, http.StatusServiceUnavailable, codeUnavailable, "the task store is unavailable; try again later")
+	default:
+		log.Printf("%s %s: %v", c.Request.Method, c.Request.URL.Path, err)
+		problem(c, http.StatusInternalServerError, codeInternal, "")
+	}
+}
+
+// enqueueFailed answers for a task that was stored but could not be queued.
+func enqueueFailed(c *gin.Context, err error, ext ...gin.H) {
+	log.Printf("%s %s: enqueue: %v", c.Request.Method, c.Request.URL.Path, err)
+	c.Header("Retry-After", "1")
+	problem(c, http.StatusServiceUnavailable, codeEnqueueFailed, "the task was stored but could not be queued", ext...)
+}
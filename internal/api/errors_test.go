@@ -0,0 +1,106 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/husainaj20/task-manager-api/internal/models"
+	"github.com/husainaj20/task-manager-api/internal/service"
+	"github.com/husainaj20/task-manager-api/internal/store"
+)
+
+// failingStore fails every Get and create with err.
+type failingStore struct {
+	store.Store
+	err error
+}
+
+func (s failingStore) Get(ctx context.Context, id string) (*models.Task, error) {
+	return nil, s.err
+}
+
+func (s failingStore) CreateOrGetByKey(ctx context.Context, key string, t *models.Task) (*models.Task, bool, error) {
+	return nil, false, s.err
+}
+
+func decodeProblem(t *testing.T, body []byte) map[string]any {
+	t.Helper()
+	var p map[string]any
+	if err := json.Unmarshal(body, &p); err != nil {
+		t.Fatalf("decode problem: %v", err)
+	}
+	return p
+}
+
+func TestErrors_ProblemDetails(t *testing.T) {
+	q := service.NewQueue(1)
+	defer q.Stop()
+	r := New(store.NewMemoryStore(), q, newTestRegistry()).Router()
+
+	cases := []struct {
+		method, path, body string
+		status             int
+		code               string
+	}{
+		{http.MethodGet, "/tasks/missing", "", http.StatusNotFound, codeNotFound},
+		{http.MethodGet, "/schedules/missing", "", http.StatusNotFound, codeNotFound},
+		{http.MethodPost, "/tasks/missing/cancel", "", http.StatusNotFound, codeNotFound},
+		{http.MethodGet, "/nope", "", http.StatusNotFound, codeNotFound},
+		{http.MethodPost, "/tasks", `{}`, http.StatusBadRequest, codeInvalidRequest},
+		{http.MethodPost, "/tasks", `{"type":"nope"}`, http.StatusUnprocessableEntity, codeUnknownType},
+		{http.MethodGet, "/tasks?cursor=bogus!", "", http.StatusBadRequest, codeInvalidCursor},
+	}
+	for _, c := range cases {
+		rec := doJSON(r, c.method, c.path, []byte(c.body))
+		if rec.Code != c.status {
+			t.Errorf("%s %s: expected %d, got %d", c.method, c.path, c.status, rec.Code)
+			continue
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/problem+json" {
+			t.Errorf("%s %s: expected problem+json, got %q", c.method, c.path, ct)
+		}
+		p := decodeProblem(t, rec.Body.Bytes())
+		if p["code"] != c.code || p["status"] != float64(c.status) || p["title"] != http.StatusText(c.status) || p["type"] != "about:blank" {
+			t.Errorf("%s %s: unexpected problem %v", c.method, c.path, p)
+		}
+	}
+}
+
+func TestErrors_StoreFailures(t *testing.T) {
+	q := service.NewQueue(1)
+	defer q.Stop()
+
+	cases := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{fmt.Errorf("%w: dial tcp: connection refused", store.ErrUnavailable), http.StatusServiceUnavailable, codeUnavailable},
+		{store.ErrConflict, http.StatusConflict, codeConflict},
+		{errors.New("secret internals"), http.StatusInternalServerError, codeInternal},
+	}
+	for _, c := range cases {
+		r := New(failingStore{Store: store.NewMemoryStore(), err: c.err}, q, newTestRegistry()).Router()
+		for _, rec := range []interface {
+			Result() *http.Response
+		}{
+			doJSON(r, http.MethodGet, "/tasks/any", nil),
+			doJSON(r, http.MethodPost, "/tasks", []byte(`{"type":"echo"}`)),
+		} {
+			res := rec.Result()
+			var p map[string]any
+			json.NewDecoder(res.Body).Decode(&p)
+			if res.StatusCode != c.status || p["code"] != c.code {
+				t.Errorf("%v: expected %d %s, got %d %v", c.err, c.status, c.code, res.StatusCode, p)
+			}
+			if detail, _ := p["detail"].(string); strings.Contains(detail, "secret") || strings.Contains(detail, "refused") {
+				t.Errorf("%v: expected the store error not to leak, got %q", c.err, detail)
+			}
+		}
+	}
+}
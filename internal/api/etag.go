@@ -0,0 +1,35 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/husainaj20/task-manager-api/internal/models"
+)
+
+// setETag tags the response with the task version.
+func setETag(c *gin.Context, t *models.Task) {
+	c.Header("ETag", strconv.Quote(strconv.FormatInt(t.Version, 10)))
+}
+
+// ifMatch returns the task version required by the If-Match header, or 0 when
+// the header is absent or "*". A malformed header is answered with 400 and
+// reported as false.
+func ifMatch(c *gin.Context) (int64, bool) {
+	v := strings.TrimSpace(c.GetHeader("If-Match"))
+	if v == "" || v == "*" {
+		return 0, true
+	}
+	v = strings.TrimPrefix(v, "W/")
+	if unq, err := strconv.Unquote(v); err == nil {
+		v = unq
+	}
+	version, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || version <= 0 {
+		problem(c, http.StatusBadRequest, codeInvalidIfMatch, "If-Match must be an ETag returned by this API")
+		return 0, false
+	}
+	return version, true
+}
@@ -2,7 +2,15 @@ package api
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -12,64 +20,397 @@ import (
 )
 
 type Handler struct {
-	store store.Store
-	q     *service.Queue
+	store   store.Store
+	q       service.TaskQueue
+	reg     *service.Registry
+	janitor *service.Janitor
 }
 
-func New(s store.Store, q *service.Queue) *Handler {
-	return &Handler{store: s, q: q}
+func New(s store.Store, q service.TaskQueue, reg *service.Registry) *Handler {
+	return &Handler{store: s, q: q, reg: reg}
 }
 
+// SetJanitor reports the janitor's eviction counts on /stats.
+func (h *Handler) SetJanitor(j *service.Janitor) { h.janitor = j }
+
 func (h *Handler) Router() http.Handler {
 	r := gin.Default()
 
 	r.GET("/healthz", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"ok": true}) })
 	r.GET("/readiness", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"ready": true}) })
+	r.GET("/stats", h.stats)
 
 	r.POST("/tasks", h.createTask)
+	r.GET("/tasks", h.listTasks)
 	r.GET("/tasks/:id", h.getTask)
+	r.PATCH("/tasks/:id", h.patchTask)
+	r.DELETE("/tasks/:id", h.deleteTask)
+	r.POST("/tasks/:id/cancel", h.cancelTask)
+
+	r.POST("/schedules", h.createSchedule)
+	r.GET("/schedules", h.listSchedules)
+	r.GET("/schedules/:id", h.getSchedule)
+	r.POST("/schedules/:id/pause", h.pauseSchedule)
+	r.POST("/schedules/:id/resume", h.resumeSchedule)
+	r.DELETE("/schedules/:id", h.deleteSchedule)
+
+	r.GET("/dlq", h.listDLQ)
+	r.POST("/dlq/replay", h.replayDLQ)
+	r.POST("/dlq/:id/replay", h.replayDead)
+	r.DELETE("/dlq", h.purgeDLQ)
+
+	r.POST("/workflows", h.createWorkflow)
+	r.GET("/workflows/:id", h.getWorkflow)
+
+	r.GET("/admin/pauses", h.listPauses)
+	r.POST("/admin/queues/:name/pause", h.pauseQueue)
+	r.POST("/admin/queues/:name/resume", h.resumeQueue)
+	r.POST("/admin/types/:type/pause", h.pauseType)
+	r.POST("/admin/types/:type/resume", h.resumeType)
+
+	r.NoRoute(func(c *gin.Context) { problem(c, http.StatusNotFound, codeNotFound, "no such route") })
 
 	return r
 }
 
 type createTaskReq struct {
-	Type    string         `json:"type" binding:"required"`
-	Payload map[string]any `json:"payload"`
+	Type     string            `json:"type" binding:"required"`
+	Payload  map[string]any    `json:"payload"`
+	Metadata map[string]string `json:"metadata"`
+	// RunAt or DelaySeconds defer execution; the task stays scheduled until due.
+	RunAt        *time.Time `json:"runAt"`
+	DelaySeconds int        `json:"delaySeconds" binding:"gte=0"`
+	// TimeoutSeconds overrides the per-type timeout of each run.
+	TimeoutSeconds int `json:"timeoutSeconds" binding:"gte=0"`
+	// Priority orders pending work, 0 (default) to 9; higher runs first.
+	Priority int `json:"priority" binding:"gte=0,lte=9"`
+	// Queue picks the queue that runs the task instead of the one its type
+	// is routed to.
+	Queue string `json:"queue"`
+	// DependsOn lists tasks that must succeed first; the task stays blocked
+	// until they have, and fails if one of them does not.
+	DependsOn []string `json:"dependsOn,omitempty"`
 }
 
 func (h *Handler) createTask(c *gin.Context) {
 	var req createTaskReq
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		badRequest(c, err.Error())
+		return
+	}
+	if !h.reg.Has(req.Type) {
+		problem(c, http.StatusUnprocessableEntity, codeUnknownType, "unknown task type: "+req.Type,
+			gin.H{"knownTypes": h.reg.Types()})
+		return
+	}
+	if req.RunAt != nil && req.DelaySeconds > 0 {
+		badRequest(c, "runAt and delaySeconds are mutually exclusive")
+		return
+	}
+	queue := req.Queue
+	if queue == "" {
+		queue = h.reg.Queue(req.Type)
+	}
+	if queue != "" && !h.q.HasQueue(queue) {
+		problem(c, http.StatusUnprocessableEntity, codeUnknownQueue, "unknown queue: "+queue)
 		return
 	}
-	idemKey := c.GetHeader("Idempotency-Key")
+	fp := fingerprint(req)
 	t := &models.Task{
-		Type:    req.Type,
-		Payload: req.Payload,
-		Status:  "queued",
+		Type:     req.Type,
+		Payload:  req.Payload,
+		Metadata: req.Metadata,
+		Status:   models.StatusQueued,
+		Priority: req.Priority,
+		Queue:    queue,
+
+		TimeoutSeconds:     req.TimeoutSeconds,
+		RequestFingerprint: fp,
+	}
+	runAt := req.RunAt
+	if req.DelaySeconds > 0 {
+		at := time.Now().Add(time.Duration(req.DelaySeconds) * time.Second)
+		runAt = &at
+	}
+	if runAt != nil && runAt.After(time.Now()) {
+		at := runAt.UTC()
+		t.RunAt = &at
+		t.Status = models.StatusScheduled
 	}
 	ctx := context.Background()
-	task, existed, err := h.store.CreateOrGetByKey(ctx, idemKey, t)
+	if len(req.DependsOn) > 0 {
+		awaiting, err := h.awaiting(ctx, req.DependsOn)
+		if errors.Is(err, store.ErrNotFound) {
+			problem(c, http.StatusUnprocessableEntity, codeUnknownDep, err.Error())
+			return
+		}
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		t.DependsOn = req.DependsOn
+		t.Awaiting = awaiting
+		if len(awaiting) > 0 {
+			t.Status = models.StatusBlocked
+		}
+	}
+	task, existed, err := h.store.CreateOrGetByKey(ctx, idempotencyKey(c), t)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeError(c, err)
 		return
 	}
-	if !existed {
-		h.q.Enqueue(&service.TaskWork{
-			ID:     task.ID,
-			Result: map[string]any{"echo": req.Payload, "processedAt": time.Now().UTC()},
-		})
+	if existed && task.RequestFingerprint != "" && task.RequestFingerprint != fp {
+		problem(c, http.StatusUnprocessableEntity, codeKeyReused, "Idempotency-Key was already used with a different request")
+		return
+	}
+	if !existed && task.Status == models.StatusBlocked {
+		if task, err = service.AwaitDependencies(ctx, h.store, h.q, task); err != nil {
+			writeError(c, err)
+			return
+		}
+	}
+	if !existed && task.Status == models.StatusQueued {
+		// a task that cannot be enqueued now is retried by the promoter
+		if task, err = service.EnqueueTask(ctx, h.store, h.q, task); err != nil {
+			enqueueFailed(c, err)
+			return
+		}
 	}
+	setETag(c, task)
 	c.JSON(http.StatusAccepted, task)
 }
 
+// awaiting returns those of the tasks ids that have yet to succeed. It fails
+// with store.ErrNotFound, naming the task, if one does not exist.
+func (h *Handler) awaiting(ctx context.Context, ids []string) ([]string, error) {
+	var pending []string
+	for _, id := range ids {
+		parent, err := h.store.Get(ctx, id)
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, fmt.Errorf("%w: dependency %s", err, id)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if parent.Status != models.StatusSucceeded {
+			pending = append(pending, id)
+		}
+	}
+	return pending, nil
+}
+
+// idempotencyKey scopes the client's Idempotency-Key to the tenant named by
+// X-Tenant-ID, so tenants cannot see each other's tasks through a shared key.
+// The escaped tenant never contains a '/', so client keys cannot collide with
+// the "schedule/..." keys of scheduled runs.
+func idempotencyKey(c *gin.Context) string {
+	key := c.GetHeader("Idempotency-Key")
+	if key == "" {
+		return ""
+	}
+	return url.QueryEscape(c.GetHeader("X-Tenant-ID")) + ":" + key
+}
+
+// fingerprint hashes the parts of a create request that define the task.
+func fingerprint(req createTaskReq) string {
+	b, _ := json.Marshal(req)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+type listTasksReq struct {
+	// Status is a comma-separated list of statuses.
+	Status string `form:"status"`
+	Type   string `form:"type"`
+	// Label filters on metadata, as key:value; repeat for several labels.
+	Label []string `form:"label"`
+
+	CreatedAfter  time.Time `form:"createdAfter" time_format:"2006-01-02T15:04:05Z07:00"`
+	CreatedBefore time.Time `form:"createdBefore" time_format:"2006-01-02T15:04:05Z07:00"`
+	UpdatedAfter  time.Time `form:"updatedAfter" time_format:"2006-01-02T15:04:05Z07:00"`
+	UpdatedBefore time.Time `form:"updatedBefore" time_format:"2006-01-02T15:04:05Z07:00"`
+
+	Sort   string `form:"sort" binding:"omitempty,oneof=createdAt updatedAt"`
+	Order  string `form:"order" binding:"omitempty,oneof=asc desc"`
+	Limit  int    `form:"limit" binding:"omitempty,min=1,max=500"`
+	Cursor string `form:"cursor"`
+}
+
+func (h *Handler) listTasks(c *gin.Context) {
+	var req listTasksReq
+	if err := c.ShouldBindQuery(&req); err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+	opts := store.ListOptions{
+		Type:          req.Type,
+		CreatedAfter:  req.CreatedAfter,
+		CreatedBefore: req.CreatedBefore,
+		UpdatedAfter:  req.UpdatedAfter,
+		UpdatedBefore: req.UpdatedBefore,
+		SortBy:        store.SortField(req.Sort),
+		Desc:          req.Order == "desc",
+		Limit:         req.Limit,
+		Cursor:        req.Cursor,
+	}
+	if opts.Limit == 0 {
+		opts.Limit = 50
+	}
+	if req.Status != "" {
+		for _, s := range strings.Split(req.Status, ",") {
+			status := models.Status(strings.TrimSpace(s))
+			if !status.Valid() {
+				badRequest(c, "unknown status: "+string(status))
+				return
+			}
+			opts.Statuses = append(opts.Statuses, status)
+		}
+	}
+	for _, l := range req.Label {
+		k, v, ok := strings.Cut(l, ":")
+		if !ok || k == "" {
+			badRequest(c, "label must be key:value, got "+l)
+			return
+		}
+		if opts.Labels == nil {
+			opts.Labels = make(map[string]string)
+		}
+		opts.Labels[k] = v
+	}
+
+	page, err := h.store.ListTasks(c.Request.Context(), opts)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
 func (h *Handler) getTask(c *gin.Context) {
 	id := c.Param("id")
 	t, err := h.store.Get(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		writeError(c, err)
 		return
 	}
+	setETag(c, t)
 	c.JSON(http.StatusOK, t)
 }
+
+type patchTaskReq struct {
+	// Metadata is merged into the task metadata; a null value removes a key.
+	Metadata map[string]*string `json:"metadata"`
+}
+
+// patchTask edits task metadata. With If-Match the edit only applies to the
+// version the client has seen.
+func (h *Handler) patchTask(c *gin.Context) {
+	version, ok := ifMatch(c)
+	if !ok {
+		return
+	}
+	var req patchTaskReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+	t, err := h.store.Update(c.Request.Context(), c.Param("id"), version, func(t *models.Task) error {
+		for k, v := range req.Metadata {
+			if v == nil {
+				delete(t.Metadata, k)
+				continue
+			}
+			if t.Metadata == nil {
+				t.Metadata = make(map[string]string)
+			}
+			t.Metadata[k] = *v
+		}
+		return nil
+	})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	setETag(c, t)
+	c.JSON(http.StatusOK, t)
+}
+
+// cancelTask marks the task cancelled, then removes its queued work and stops
+// a running processor. Cancelling a finished task is a conflict.
+func (h *Handler) cancelTask(c *gin.Context) {
+	version, ok := ifMatch(c)
+	if !ok {
+		return
+	}
+	id := c.Param("id")
+	ctx := c.Request.Context()
+	t, err := h.store.Update(ctx, id, version, func(t *models.Task) error {
+		return t.Transition(models.StatusCancelled)
+	})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	if err := h.q.Cancel(ctx, id); err != nil {
+		// the worker that picks the work up skips it anyway
+		log.Printf("cancel task %s: %v", id, err)
+	}
+	service.SettleDependents(ctx, h.store, h.q, t)
+	setETag(c, t)
+	c.JSON(http.StatusOK, t)
+}
+
+// deleteTask removes a finished task. Unfinished tasks must be cancelled first.
+func (h *Handler) deleteTask(c *gin.Context) {
+	version, ok := ifMatch(c)
+	if !ok {
+		return
+	}
+	id := c.Param("id")
+	ctx := c.Request.Context()
+	t, err := h.store.Get(ctx, id)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	if version != 0 && version != t.Version {
+		writeError(c, store.ErrVersionMismatch)
+		return
+	}
+	if !t.Status.Finished() {
+		problem(c, http.StatusConflict, codeInvalidState, "task is "+string(t.Status)+"; cancel it before deleting")
+		return
+	}
+	// delete the version checked above, so a task replayed meanwhile survives
+	err = h.store.Delete(ctx, id, t.Version)
+	if errors.Is(err, store.ErrVersionMismatch) {
+		problem(c, http.StatusConflict, codeConflict, "task changed while deleting; try again")
+		return
+	}
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) stats(c *gin.Context) {
+	queued, inflight, processed, failed, dlq := h.q.Stats()
+	evicted := map[models.Status]int64{}
+	if h.janitor != nil {
+		evicted = h.janitor.Evicted()
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"queue": gin.H{
+			"queued":    queued,
+			"inflight":  inflight,
+			"processed": processed,
+			"failed":    failed,
+			"dlq":       dlq,
+
+			"byPriority": h.q.PendingByPriority(),
+		},
+		"queues":  h.q.QueueStats(),
+		"evicted": evicted,
+	})
+}
@@ -2,21 +2,34 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/husainaj20/task-manager-api/internal/models"
 	"github.com/husainaj20/task-manager-api/internal/service"
 	"github.com/husainaj20/task-manager-api/internal/store"
 )
 
+func newTestRegistry() *service.Registry {
+	reg := service.NewRegistry()
+	reg.Register("echo", func(ctx context.Context, t *service.TaskWork) (map[string]any, error) {
+		return map[string]any{"echo": t.Payload}, nil
+	})
+	return reg
+}
+
 func TestHealthz(t *testing.T) {
 	mem := store.NewMemoryStore()
 	q := service.NewQueue(1)
 	defer q.Stop()
-	h := New(mem, q)
+	h := New(mem, q, newTestRegistry())
 
 	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
 	rec := httptest.NewRecorder()
@@ -31,7 +44,7 @@ func TestCreateTask_Success(t *testing.T) {
 	mem := store.NewMemoryStore()
 	q := service.NewQueue(1)
 	defer q.Stop()
-	h := New(mem, q)
+	h := New(mem, q, newTestRegistry())
 
 	payload := map[string]interface{}{
 		"type":    "echo",
@@ -69,7 +82,7 @@ func TestCreateTask_ValidationError(t *testing.T) {
 	mem := store.NewMemoryStore()
 	q := service.NewQueue(1)
 	defer q.Stop()
-	h := New(mem, q)
+	h := New(mem, q, newTestRegistry())
 
 	// Missing required 'type' field
 	payload := map[string]interface{}{
@@ -87,9 +100,9 @@ func TestCreateTask_ValidationError(t *testing.T) {
 		t.Fatalf("expected 400, got %d", rec.Code)
 	}
 
-	// Check error message
-	if !strings.Contains(rec.Body.String(), "error") {
-		t.Error("expected error message in response")
+	// Check error code
+	if !strings.Contains(rec.Body.String(), `"code":"invalid_request"`) {
+		t.Error("expected error code in response")
 	}
 }
 
@@ -97,7 +110,7 @@ func TestCreateTask_Idempotency(t *testing.T) {
 	mem := store.NewMemoryStore()
 	q := service.NewQueue(1)
 	defer q.Stop()
-	h := New(mem, q)
+	h := New(mem, q, newTestRegistry())
 
 	payload := map[string]interface{}{
 		"type":    "echo",
@@ -141,7 +154,7 @@ func TestGetTask_Success(t *testing.T) {
 	mem := store.NewMemoryStore()
 	q := service.NewQueue(1)
 	defer q.Stop()
-	h := New(mem, q)
+	h := New(mem, q, newTestRegistry())
 
 	// First create a task
 	payload := map[string]interface{}{
@@ -176,13 +189,18 @@ func TestGetTask_Success(t *testing.T) {
 	if retrievedTask["id"] != taskID {
 		t.Errorf("expected task ID %s, got %v", taskID, retrievedTask["id"])
 	}
+	for _, field := range []string{"idempotencyKey", "requestFingerprint"} {
+		if _, ok := retrievedTask[field]; ok {
+			t.Errorf("expected %s kept out of the response, got %s", field, getRec.Body.String())
+		}
+	}
 }
 
 func TestGetTask_NotFound(t *testing.T) {
 	mem := store.NewMemoryStore()
 	q := service.NewQueue(1)
 	defer q.Stop()
-	h := New(mem, q)
+	h := New(mem, q, newTestRegistry())
 
 	req := httptest.NewRequest(http.MethodGet, "/tasks/nonexistent-id", nil)
 	rec := httptest.NewRecorder()
@@ -196,3 +214,408 @@ func TestGetTask_NotFound(t *testing.T) {
 		t.Error("expected 'not found' message in response")
 	}
 }
+
+func TestCreateTask_UnknownType(t *testing.T) {
+	mem := store.NewMemoryStore()
+	q := service.NewQueue(1)
+	defer q.Stop()
+	h := New(mem, q, newTestRegistry())
+
+	payload := map[string]interface{}{
+		"type":    "does-not-exist",
+		"payload": map[string]interface{}{"msg": "hello"},
+	}
+	body, _ := json.Marshal(payload)
+
+	req := httptest.NewRequest(http.MethodPost, "/tasks", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	rec := httptest.NewRecorder()
+	h.Router().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "unknown task type") {
+		t.Errorf("expected unknown task type message, got %s", rec.Body.String())
+	}
+}
+
+func TestCreateTask_Delayed(t *testing.T) {
+	mem := store.NewMemoryStore()
+	q := service.NewQueue(1)
+	defer q.Stop()
+	h := New(mem, q, newTestRegistry())
+
+	body := []byte(`{"type":"echo","payload":{"msg":"later"},"delaySeconds":60}`)
+	req := httptest.NewRequest(http.MethodPost, "/tasks", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	h.Router().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", rec.Code)
+	}
+	var result map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &result)
+	if result["status"] != "scheduled" {
+		t.Errorf("expected status 'scheduled', got %v", result["status"])
+	}
+	if result["runAt"] == nil {
+		t.Errorf("expected runAt in response")
+	}
+	if queued, _, _, _, _ := q.Stats(); queued != 0 {
+		t.Errorf("expected nothing enqueued for a scheduled task, got %d", queued)
+	}
+}
+
+func TestCreateTask_DelayAndRunAtConflict(t *testing.T) {
+	mem := store.NewMemoryStore()
+	q := service.NewQueue(1)
+	defer q.Stop()
+	h := New(mem, q, newTestRegistry())
+
+	body := []byte(`{"type":"echo","delaySeconds":5,"runAt":"2030-01-01T00:00:00Z"}`)
+	req := httptest.NewRequest(http.MethodPost, "/tasks", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	h.Router().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+}
+
+func TestCancelTask(t *testing.T) {
+	mem := store.NewMemoryStore()
+	q := service.NewQueue(1)
+	defer q.Stop()
+	r := New(mem, q, newTestRegistry()).Router()
+
+	rec := doJSON(r, http.MethodPost, "/tasks", []byte(`{"type":"echo","delaySeconds":60}`))
+	var created map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	id, _ := created["id"].(string)
+
+	rec = doJSON(r, http.MethodPost, "/tasks/"+id+"/cancel", nil)
+	var cancelled map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &cancelled)
+	if rec.Code != http.StatusOK || cancelled["status"] != "cancelled" {
+		t.Fatalf("expected cancelled task, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if rec = doJSON(r, http.MethodPost, "/tasks/"+id+"/cancel", nil); rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 cancelling a cancelled task, got %d", rec.Code)
+	}
+	if rec = doJSON(r, http.MethodPost, "/tasks/missing/cancel", nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rec.Code)
+	}
+}
+
+func TestCreateTask_Timeout(t *testing.T) {
+	mem := store.NewMemoryStore()
+	q := service.NewQueue(1)
+	defer q.Stop()
+	r := New(mem, q, newTestRegistry()).Router()
+
+	rec := doJSON(r, http.MethodPost, "/tasks", []byte(`{"type":"echo","timeoutSeconds":30}`))
+	var created map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	if rec.Code != http.StatusAccepted || created["timeoutSeconds"] != float64(30) {
+		t.Fatalf("expected timeout stored, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec = doJSON(r, http.MethodPost, "/tasks", []byte(`{"type":"echo","timeoutSeconds":-1}`)); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for negative timeout, got %d", rec.Code)
+	}
+}
+
+func TestCreateTask_Priority(t *testing.T) {
+	mem := store.NewMemoryStore()
+	q := service.NewQueue(1)
+	defer q.Stop()
+	r := New(mem, q, newTestRegistry()).Router()
+
+	rec := doJSON(r, http.MethodPost, "/tasks", []byte(`{"type":"echo","priority":9}`))
+	var created map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	if rec.Code != http.StatusAccepted || created["priority"] != float64(9) {
+		t.Fatalf("expected priority stored, got %d: %s", rec.Code, rec.Body.String())
+	}
+	for _, p := range []string{"-1", "10"} {
+		if rec = doJSON(r, http.MethodPost, "/tasks", []byte(`{"type":"echo","priority":`+p+`}`)); rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400 for priority %s, got %d", p, rec.Code)
+		}
+	}
+}
+
+func TestCreateTask_Queue(t *testing.T) {
+	mem := store.NewMemoryStore()
+	reg := newTestRegistry()
+	reg.Register("report", func(ctx context.Context, t *service.TaskWork) (map[string]any, error) { return nil, nil })
+	reg.SetQueue("report", "reports")
+	set := service.NewQueueSet()
+	defer set.Stop()
+	for _, name := range []string{service.DefaultQueue, "reports"} {
+		q := service.NewQueue(1)
+		q.SetStore(mem)
+		q.SetProcessor(reg.Process)
+		set.Add(name, q)
+	}
+	set.SetRouter(reg.Queue)
+	r := New(mem, set, reg).Router()
+
+	for _, c := range []struct{ body, queue string }{
+		{`{"type":"echo"}`, ""},
+		{`{"type":"report"}`, "reports"},
+		{`{"type":"echo","queue":"reports"}`, "reports"},
+	} {
+		rec := doJSON(r, http.MethodPost, "/tasks", []byte(c.body))
+		var created map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &created)
+		if q, _ := created["queue"].(string); rec.Code != http.StatusAccepted || q != c.queue {
+			t.Fatalf("%s: expected queue %q, got %d: %s", c.body, c.queue, rec.Code, rec.Body.String())
+		}
+	}
+	rec := doJSON(r, http.MethodPost, "/tasks", []byte(`{"type":"echo","queue":"missing"}`))
+	if rec.Code != http.StatusUnprocessableEntity || decodeProblem(t, rec.Body.Bytes())["code"] != codeUnknownQueue {
+		t.Fatalf("expected 422 unknown_queue, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	set.WaitIdle(time.Second)
+	rec = doJSON(r, http.MethodGet, "/stats", nil)
+	var body struct {
+		Queues []service.QueueStats `json:"queues"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	if len(body.Queues) != 2 || body.Queues[1].Name != "reports" || body.Queues[0].Processed != 1 || body.Queues[1].Processed != 2 {
+		t.Fatalf("unexpected queue stats %s", rec.Body.String())
+	}
+}
+
+func TestListTasks(t *testing.T) {
+	mem := store.NewMemoryStore()
+	q := service.NewQueue(1)
+	defer q.Stop()
+	r := New(mem, q, newTestRegistry()).Router()
+
+	for i := 0; i < 3; i++ {
+		doJSON(r, http.MethodPost, "/tasks", []byte(`{"type":"echo","delaySeconds":60,"metadata":{"team":"a"}}`))
+	}
+	doJSON(r, http.MethodPost, "/tasks", []byte(`{"type":"echo","delaySeconds":60,"metadata":{"team":"b"}}`))
+
+	rec := doJSON(r, http.MethodGet, "/tasks?status=scheduled&label=team:a&limit=2&order=desc", nil)
+	var page struct {
+		Tasks      []map[string]interface{} `json:"tasks"`
+		NextCursor string                   `json:"nextCursor"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &page)
+	if rec.Code != http.StatusOK || len(page.Tasks) != 2 || page.NextCursor == "" {
+		t.Fatalf("expected first page of 2, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doJSON(r, http.MethodGet, "/tasks?status=scheduled&label=team:a&limit=2&order=desc&cursor="+page.NextCursor, nil)
+	page.NextCursor = ""
+	json.Unmarshal(rec.Body.Bytes(), &page)
+	if rec.Code != http.StatusOK || len(page.Tasks) != 1 || page.NextCursor != "" {
+		t.Fatalf("expected last page of 1, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	for _, q := range []string{"status=bogus", "sort=name", "limit=0x", "label=nocolon", "cursor=bogus", "createdAfter=yesterday"} {
+		if rec := doJSON(r, http.MethodGet, "/tasks?"+q, nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", q, rec.Code)
+		}
+	}
+}
+
+func TestDeleteTask(t *testing.T) {
+	mem := store.NewMemoryStore()
+	q := service.NewQueue(1)
+	defer q.Stop()
+	r := New(mem, q, newTestRegistry()).Router()
+
+	rec := doJSON(r, http.MethodPost, "/tasks", []byte(`{"type":"echo","delaySeconds":60}`))
+	var created map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	id, _ := created["id"].(string)
+
+	if rec = doJSON(r, http.MethodDelete, "/tasks/"+id, nil); rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409 deleting a scheduled task, got %d", rec.Code)
+	}
+	doJSON(r, http.MethodPost, "/tasks/"+id+"/cancel", nil)
+	if rec = doJSON(r, http.MethodDelete, "/tasks/"+id, nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec = doJSON(r, http.MethodGet, "/tasks/"+id, nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 after delete, got %d", rec.Code)
+	}
+}
+
+func TestStats(t *testing.T) {
+	mem := store.NewMemoryStore()
+	q := service.NewQueue(1)
+	defer q.Stop()
+	h := New(mem, q, newTestRegistry())
+	j := service.NewJanitor(mem, service.Retention{}, time.Hour)
+	defer j.Stop()
+	h.SetJanitor(j)
+
+	rec := doJSON(h.Router(), http.MethodGet, "/stats", nil)
+	var body struct {
+		Queue   map[string]any   `json:"queue"`
+		Evicted map[string]int64 `json:"evicted"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("unexpected stats %d: %s", rec.Code, rec.Body.String())
+	}
+	if _, ok := body.Queue["byPriority"].(map[string]any); !ok {
+		t.Fatalf("expected queue depth by priority, got %s", rec.Body.String())
+	}
+	if _, ok := body.Queue["processed"]; !ok || body.Evicted == nil {
+		t.Fatalf("expected queue counters and evictions, got %s", rec.Body.String())
+	}
+}
+
+func TestCreateTask_IdempotencyConflictAndTenants(t *testing.T) {
+	mem := store.NewMemoryStore()
+	q := service.NewQueue(1)
+	defer q.Stop()
+	r := New(mem, q, newTestRegistry()).Router()
+
+	post := func(tenant, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/tasks", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", "same-key")
+		if tenant != "" {
+			req.Header.Set("X-Tenant-ID", tenant)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+	id := func(rec *httptest.ResponseRecorder) string {
+		var body map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &body)
+		s, _ := body["id"].(string)
+		return s
+	}
+
+	first := post("acme", `{"type":"echo","payload":{"n":1}}`)
+	if first.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", first.Code)
+	}
+	if again := post("acme", `{"payload":{"n":1},"type":"echo"}`); id(again) != id(first) {
+		t.Fatalf("expected the same task for an identical request")
+	}
+	if conflict := post("acme", `{"type":"echo","payload":{"n":2}}`); conflict.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for a different body, got %d", conflict.Code)
+	}
+	other := post("globex", `{"type":"echo","payload":{"n":2}}`)
+	if other.Code != http.StatusAccepted || id(other) == id(first) {
+		t.Fatalf("expected keys to be scoped per tenant, got %d", other.Code)
+	}
+}
+
+func TestTaskETags(t *testing.T) {
+	mem := store.NewMemoryStore()
+	q := service.NewQueue(1)
+	defer q.Stop()
+	r := New(mem, q, newTestRegistry()).Router()
+
+	rec := doJSON(r, http.MethodPost, "/tasks", []byte(`{"type":"echo","delaySeconds":60}`))
+	var created map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	id, _ := created["id"].(string)
+	if etag := rec.Header().Get("ETag"); etag != `"1"` {
+		t.Fatalf("expected ETag \"1\" on create, got %q", etag)
+	}
+
+	withIfMatch := func(method, path, etag, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("If-Match", etag)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec = withIfMatch(http.MethodPatch, "/tasks/"+id, `"1"`, `{"metadata":{"owner":"ops"}}`)
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") != `"2"` {
+		t.Fatalf("expected patched task at version 2, got %d %q: %s", rec.Code, rec.Header().Get("ETag"), rec.Body.String())
+	}
+	if rec = withIfMatch(http.MethodPatch, "/tasks/"+id, `"1"`, `{"metadata":{"owner":"dev"}}`); rec.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected 412 for a stale ETag, got %d", rec.Code)
+	}
+	if rec = withIfMatch(http.MethodPost, "/tasks/"+id+"/cancel", `"1"`, ""); rec.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected 412 cancelling with a stale ETag, got %d", rec.Code)
+	}
+	if rec = withIfMatch(http.MethodPost, "/tasks/"+id+"/cancel", "garbage", ""); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a malformed If-Match, got %d", rec.Code)
+	}
+
+	rec = doJSON(r, http.MethodGet, "/tasks/"+id, nil)
+	var got map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &got)
+	if rec.Header().Get("ETag") != `"2"` || got["metadata"].(map[string]interface{})["owner"] != "ops" {
+		t.Fatalf("expected the first patch only, got %q: %s", rec.Header().Get("ETag"), rec.Body.String())
+	}
+	if rec = withIfMatch(http.MethodPost, "/tasks/"+id+"/cancel", `"2"`, ""); rec.Code != http.StatusOK {
+		t.Fatalf("expected cancel with the current ETag to succeed, got %d", rec.Code)
+	}
+}
+
+// failingQueue fails every Enqueue while fail is set.
+type failingQueue struct {
+	service.TaskQueue
+	fail atomic.Bool
+}
+
+func (q *failingQueue) Enqueue(w *service.TaskWork) error {
+	if q.fail.Load() {
+		return errors.New("broker unavailable")
+	}
+	return q.TaskQueue.Enqueue(w)
+}
+
+func TestCreateTask_EnqueueFailureIsRetried(t *testing.T) {
+	mem := store.NewMemoryStore()
+	inner := service.NewQueue(1)
+	defer inner.Stop()
+	inner.SetStore(mem)
+	reg := newTestRegistry()
+	inner.SetProcessor(reg.Process)
+	q := &failingQueue{TaskQueue: inner}
+	q.fail.Store(true)
+	r := New(mem, q, reg).Router()
+
+	post := func() models.Task {
+		req := httptest.NewRequest(http.MethodPost, "/tasks", bytes.NewReader([]byte(`{"type":"echo"}`)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", "flaky-broker")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Code != http.StatusAccepted {
+			t.Fatalf("expected 202, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var task models.Task
+		json.Unmarshal(rec.Body.Bytes(), &task)
+		return task
+	}
+	first := post()
+	if first.Status != models.StatusScheduled || first.RunAt == nil {
+		t.Fatalf("expected the task deferred to the promoter, got %s", first.Status)
+	}
+	if retry := post(); retry.ID != first.ID {
+		t.Fatalf("expected the retry to return the same task, got %s and %s", first.ID, retry.ID)
+	}
+
+	q.fail.Store(false)
+	p := service.NewPromoter(mem, q, 10*time.Millisecond)
+	defer p.Stop()
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		if got, _ := mem.Get(context.Background(), first.ID); got.Status == models.StatusSucceeded {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("expected the deferred task to run once the queue recovered")
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/husainaj20/task-manager-api/internal/models"
+	"github.com/husainaj20/task-manager-api/internal/service"
+)
+
+type createScheduleReq struct {
+	Cron     string            `json:"cron" binding:"required"`
+	Timezone string            `json:"timezone"`
+	TaskType string            `json:"taskType" binding:"required"`
+	Payload  map[string]any    `json:"payload"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+func (h *Handler) createSchedule(c *gin.Context) {
+	var req createScheduleReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+	if !h.reg.Has(req.TaskType) {
+		problem(c, http.StatusUnprocessableEntity, codeUnknownType, "unknown task type: "+req.TaskType,
+			gin.H{"knownTypes": h.reg.Types()})
+		return
+	}
+	if req.Timezone == "" {
+		req.Timezone = "UTC"
+	}
+	next, err := service.NextRun(req.Cron, req.Timezone, time.Now())
+	if err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+	s, err := h.store.CreateSchedule(c.Request.Context(), &models.Schedule{
+		Cron:      req.Cron,
+		Timezone:  req.Timezone,
+		TaskType:  req.TaskType,
+		Payload:   req.Payload,
+		Metadata:  req.Metadata,
+		NextRunAt: next,
+	})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, s)
+}
+
+func (h *Handler) listSchedules(c *gin.Context) {
+	schedules, err := h.store.ListSchedules(c.Request.Context())
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"schedules": schedules})
+}
+
+func (h *Handler) getSchedule(c *gin.Context) {
+	s, err := h.store.GetSchedule(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, s)
+}
+
+func (h *Handler) pauseSchedule(c *gin.Context) {
+	h.setSchedulePaused(c, true)
+}
+
+func (h *Handler) resumeSchedule(c *gin.Context) {
+	h.setSchedulePaused(c, false)
+}
+
+// setSchedulePaused pauses or resumes a schedule. Resuming starts from the
+// next run after now; runs missed while paused are skipped.
+func (h *Handler) setSchedulePaused(c *gin.Context, paused bool) {
+	ctx := c.Request.Context()
+	s, err := h.store.GetSchedule(ctx, c.Param("id"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	next := s.NextRunAt
+	if !paused {
+		if next, err = service.NextRun(s.Cron, s.Timezone, time.Now()); err != nil {
+			writeError(c, err)
+			return
+		}
+	}
+	s, err = h.store.SetSchedulePaused(ctx, s.ID, paused, next)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, s)
+}
+
+func (h *Handler) deleteSchedule(c *gin.Context) {
+	if err := h.store.DeleteSchedule(c.Request.Context(), c.Param("id")); err != nil {
+		writeError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
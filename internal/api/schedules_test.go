@@ -0,0 +1,126 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/husainaj20/task-manager-api/internal/models"
+	"github.com/husainaj20/task-manager-api/internal/service"
+	"github.com/husainaj20/task-manager-api/internal/store"
+)
+
+func doJSON(h http.Handler, method, path string, body []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestSchedules_CRUD(t *testing.T) {
+	mem := store.NewMemoryStore()
+	q := service.NewQueue(1)
+	defer q.Stop()
+	r := New(mem, q, newTestRegistry()).Router()
+
+	rec := doJSON(r, http.MethodPost, "/schedules", []byte(`{"cron":"0 9 * * 1-5","timezone":"Europe/London","taskType":"echo","payload":{"msg":"daily"}}`))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var created map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	id, _ := created["id"].(string)
+	if id == "" || created["nextRunAt"] == nil || created["paused"] != false {
+		t.Fatalf("unexpected schedule %v", created)
+	}
+
+	rec = doJSON(r, http.MethodGet, "/schedules", nil)
+	var list struct {
+		Schedules []map[string]interface{} `json:"schedules"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &list)
+	if rec.Code != http.StatusOK || len(list.Schedules) != 1 {
+		t.Fatalf("expected 1 schedule listed, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = doJSON(r, http.MethodPost, "/schedules/"+id+"/pause", nil)
+	var paused map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &paused)
+	if rec.Code != http.StatusOK || paused["paused"] != true {
+		t.Fatalf("expected paused schedule, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = doJSON(r, http.MethodPost, "/schedules/"+id+"/resume", nil)
+	var resumed map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &resumed)
+	if rec.Code != http.StatusOK || resumed["paused"] != false {
+		t.Fatalf("expected resumed schedule, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if rec = doJSON(r, http.MethodDelete, "/schedules/"+id, nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", rec.Code)
+	}
+	if rec = doJSON(r, http.MethodGet, "/schedules/"+id, nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 after delete, got %d", rec.Code)
+	}
+}
+
+func TestSchedules_Validation(t *testing.T) {
+	mem := store.NewMemoryStore()
+	q := service.NewQueue(1)
+	defer q.Stop()
+	r := New(mem, q, newTestRegistry()).Router()
+
+	cases := []struct {
+		body string
+		want int
+	}{
+		{`{"cron":"every day","taskType":"echo"}`, http.StatusBadRequest},
+		{`{"cron":"* * * * *","timezone":"Nowhere/Special","taskType":"echo"}`, http.StatusBadRequest},
+		{`{"cron":"* * * * *","taskType":"unknown"}`, http.StatusUnprocessableEntity},
+		{`{"taskType":"echo"}`, http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		if rec := doJSON(r, http.MethodPost, "/schedules", []byte(c.body)); rec.Code != c.want {
+			t.Errorf("%s: expected %d, got %d", c.body, c.want, rec.Code)
+		}
+	}
+}
+
+func TestSchedules_RunKeysDoNotCollideWithClientKeys(t *testing.T) {
+	mem := store.NewMemoryStore()
+	q := service.NewQueue(1)
+	defer q.Stop()
+	r := New(mem, q, newTestRegistry()).Router()
+	ctx := context.Background()
+
+	due := time.Now().UTC().Add(-time.Minute).Truncate(time.Second)
+	sc, _ := mem.CreateSchedule(ctx, &models.Schedule{Cron: "* * * * *", Timezone: "UTC", TaskType: "echo", NextRunAt: due})
+
+	// a client posing as the "schedule" tenant, with the run's old key
+	req := httptest.NewRequest(http.MethodPost, "/tasks", bytes.NewReader([]byte(`{"type":"echo"}`)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Tenant-ID", "schedule")
+	req.Header.Set("Idempotency-Key", fmt.Sprintf("%s:%d", sc.ID, due.Unix()))
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", rec.Code)
+	}
+
+	s := service.NewScheduler(mem, q, time.Hour)
+	defer s.Stop()
+	if fired, err := s.FireDue(ctx, time.Now()); err != nil || fired != 1 {
+		t.Fatalf("expected the scheduled run to fire despite the client's key, got %d (%v)", fired, err)
+	}
+	page, _ := mem.ListTasks(ctx, store.ListOptions{})
+	if len(page.Tasks) != 2 {
+		t.Fatalf("expected separate client and scheduled tasks, got %d", len(page.Tasks))
+	}
+}
@@ -0,0 +1,206 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/husainaj20/task-manager-api/internal/models"
+	"github.com/husainaj20/task-manager-api/internal/service"
+	"github.com/husainaj20/task-manager-api/internal/store"
+)
+
+type workflowTaskReq struct {
+	// Key names the task within the workflow; DependsOn refers to keys.
+	Key            string            `json:"key" binding:"required"`
+	Type           string            `json:"type" binding:"required"`
+	Payload        map[string]any    `json:"payload"`
+	Metadata       map[string]string `json:"metadata"`
+	DependsOn      []string          `json:"dependsOn"`
+	TimeoutSeconds int               `json:"timeoutSeconds" binding:"gte=0"`
+	Priority       int               `json:"priority" binding:"gte=0,lte=9"`
+	Queue          string            `json:"queue"`
+}
+
+type createWorkflowReq struct {
+	Name  string            `json:"name"`
+	Tasks []workflowTaskReq `json:"tasks" binding:"required,min=1,dive"`
+}
+
+// workflowResp is a workflow with its tasks by key and their aggregate status.
+type workflowResp struct {
+	ID        string                  `json:"id"`
+	Name      string                  `json:"name,omitempty"`
+	Status    models.Status           `json:"status"`
+	Counts    map[models.Status]int   `json:"counts"`
+	Tasks     map[string]*models.Task `json:"tasks"`
+	CreatedAt time.Time               `json:"createdAt"`
+}
+
+func newWorkflowResp(w *models.Workflow, tasks map[string]*models.Task) workflowResp {
+	counts := map[models.Status]int{}
+	statuses := make([]models.Status, 0, len(tasks))
+	for _, t := range tasks {
+		counts[t.Status]++
+		statuses = append(statuses, t.Status)
+	}
+	return workflowResp{
+		ID:        w.ID,
+		Name:      w.Name,
+		Status:    models.WorkflowStatus(statuses),
+		Counts:    counts,
+		Tasks:     tasks,
+		CreatedAt: w.CreatedAt,
+	}
+}
+
+// createWorkflow stores a DAG of tasks in one transaction and queues its
+// roots; the DependencyResolver releases the rest as their parents succeed.
+func (h *Handler) createWorkflow(c *gin.Context) {
+	var req createWorkflowReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		badRequest(c, err.Error())
+		return
+	}
+	if err := checkDAG(req.Tasks); err != nil {
+		problem(c, http.StatusUnprocessableEntity, codeInvalidWorkflow, err.Error())
+		return
+	}
+	ids := make(map[string]string, len(req.Tasks))
+	for _, rt := range req.Tasks {
+		ids[rt.Key] = uuid.NewString()
+	}
+	w := &models.Workflow{ID: uuid.NewString(), Name: req.Name, Tasks: ids}
+	tasks := make([]*models.Task, 0, len(req.Tasks))
+	for _, rt := range req.Tasks {
+		if !h.reg.Has(rt.Type) {
+			problem(c, http.StatusUnprocessableEntity, codeUnknownType, "unknown task type: "+rt.Type,
+				gin.H{"knownTypes": h.reg.Types(), "key": rt.Key})
+			return
+		}
+		queue := rt.Queue
+		if queue == "" {
+			queue = h.reg.Queue(rt.Type)
+		}
+		if queue != "" && !h.q.HasQueue(queue) {
+			problem(c, http.StatusUnprocessableEntity, codeUnknownQueue, "unknown queue: "+queue, gin.H{"key": rt.Key})
+			return
+		}
+		t := &models.Task{
+			ID:             ids[rt.Key],
+			Type:           rt.Type,
+			Payload:        rt.Payload,
+			Metadata:       rt.Metadata,
+			Status:         models.StatusQueued,
+			Priority:       rt.Priority,
+			Queue:          queue,
+			TimeoutSeconds: rt.TimeoutSeconds,
+			WorkflowID:     w.ID,
+		}
+		for _, key := range rt.DependsOn {
+			t.DependsOn = append(t.DependsOn, ids[key])
+		}
+		if len(t.DependsOn) > 0 {
+			t.Awaiting = append([]string(nil), t.DependsOn...)
+			t.Status = models.StatusBlocked
+		}
+		tasks = append(tasks, t)
+	}
+	byID := make(map[string]*models.Task, len(tasks))
+	for _, t := range tasks {
+		byID[t.ID] = t
+	}
+	for _, t := range tasks {
+		for _, id := range t.DependsOn {
+			byID[id].Dependents = append(byID[id].Dependents, t.ID)
+		}
+	}
+
+	w, err := h.store.CreateWorkflow(context.Background(), w, tasks)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	byKey := make(map[string]*models.Task, len(tasks))
+	for i, t := range tasks {
+		if t.Status == models.StatusQueued {
+			if t, err = service.EnqueueTask(context.Background(), h.store, h.q, t); err != nil {
+				enqueueFailed(c, err, gin.H{"workflowId": w.ID})
+				return
+			}
+		}
+		byKey[req.Tasks[i].Key] = t
+	}
+	c.JSON(http.StatusAccepted, newWorkflowResp(w, byKey))
+}
+
+// checkDAG checks that task keys are unique, that dependencies name tasks of
+// the workflow, and that there are no dependency cycles.
+func checkDAG(tasks []workflowTaskReq) error {
+	pending := make(map[string]int, len(tasks)) // unmet dependencies per key
+	children := make(map[string][]string)
+	for _, t := range tasks {
+		if _, dup := pending[t.Key]; dup {
+			return fmt.Errorf("duplicate task key %q", t.Key)
+		}
+		pending[t.Key] = len(t.DependsOn)
+	}
+	for _, t := range tasks {
+		for _, dep := range t.DependsOn {
+			if _, ok := pending[dep]; !ok {
+				return fmt.Errorf("task %q depends on unknown key %q", t.Key, dep)
+			}
+			children[dep] = append(children[dep], t.Key)
+		}
+	}
+	var ready []string
+	for _, t := range tasks {
+		if pending[t.Key] == 0 {
+			ready = append(ready, t.Key)
+		}
+	}
+	visited := 0
+	for len(ready) > 0 {
+		key := ready[len(ready)-1]
+		ready = ready[:len(ready)-1]
+		visited++
+		for _, child := range children[key] {
+			if pending[child]--; pending[child] == 0 {
+				ready = append(ready, child)
+			}
+		}
+	}
+	if visited < len(tasks) {
+		return errors.New("task dependencies contain a cycle")
+	}
+	return nil
+}
+
+// getWorkflow reports a workflow's tasks and aggregate status. Tasks the
+// janitor has since removed are left out; the store deletes the workflow with
+// its last task.
+func (h *Handler) getWorkflow(c *gin.Context) {
+	ctx := c.Request.Context()
+	w, err := h.store.GetWorkflow(ctx, c.Param("id"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	tasks := make(map[string]*models.Task, len(w.Tasks))
+	for key, id := range w.Tasks {
+		t, err := h.store.Get(ctx, id)
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		tasks[key] = t
+	}
+	c.JSON(http.StatusOK, newWorkflowResp(w, tasks))
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/husainaj20/task-manager-api/internal/models"
+	"github.com/husainaj20/task-manager-api/internal/service"
+	"github.com/husainaj20/task-manager-api/internal/store"
+)
+
+func TestWorkflows_RunInDependencyOrder(t *testing.T) {
+	mem := store.NewMemoryStore()
+	q := service.NewQueue(1)
+	defer q.Stop()
+	q.SetStore(mem)
+	reg := newTestRegistry()
+	resolver := service.NewDependencyResolver(mem, q, time.Hour)
+	defer resolver.Stop()
+	q.SetFinishHandler(resolver.Settle)
+	q.SetProcessor(reg.Process)
+	r := New(mem, q, reg).Router()
+
+	rec := doJSON(r, http.MethodPost, "/workflows", []byte(`{"name":"etl","tasks":[
+		{"key":"load","type":"echo","dependsOn":["a","b"]},
+		{"key":"a","type":"echo"},
+		{"key":"b","type":"echo","dependsOn":["a"]}]}`))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var wf workflowResp
+	json.Unmarshal(rec.Body.Bytes(), &wf)
+	if wf.Status != models.StatusRunning || wf.Tasks["a"].Status != models.StatusQueued || wf.Tasks["load"].Status != models.StatusBlocked {
+		t.Fatalf("unexpected workflow %s", rec.Body.String())
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for wf.Status != models.StatusSucceeded && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		rec = doJSON(r, http.MethodGet, "/workflows/"+wf.ID, nil)
+		json.Unmarshal(rec.Body.Bytes(), &wf)
+	}
+	if wf.Status != models.StatusSucceeded || wf.Counts[models.StatusSucceeded] != 3 {
+		t.Fatalf("expected the workflow to succeed, got %s", rec.Body.String())
+	}
+	a, b, load := wf.Tasks["a"], wf.Tasks["b"], wf.Tasks["load"]
+	if load.History[0].StartedAt.Before(b.History[0].FinishedAt) || b.History[0].StartedAt.Before(a.History[0].FinishedAt) {
+		t.Fatal("expected each task to start after its dependencies finished")
+	}
+
+	if rec := doJSON(r, http.MethodGet, "/workflows/missing", nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rec.Code)
+	}
+}
+
+func TestWorkflows_RejectsInvalidGraphs(t *testing.T) {
+	mem := store.NewMemoryStore()
+	q := service.NewQueue(1)
+	defer q.Stop()
+	r := New(mem, q, newTestRegistry()).Router()
+
+	for name, body := range map[string]string{
+		"cycle":       `{"tasks":[{"key":"a","type":"echo","dependsOn":["b"]},{"key":"b","type":"echo","dependsOn":["a"]}]}`,
+		"self":        `{"tasks":[{"key":"a","type":"echo","dependsOn":["a"]}]}`,
+		"unknown key": `{"tasks":[{"key":"a","type":"echo","dependsOn":["x"]}]}`,
+		"duplicate":   `{"tasks":[{"key":"a","type":"echo"},{"key":"a","type":"echo"}]}`,
+	} {
+		rec := doJSON(r, http.MethodPost, "/workflows", []byte(body))
+		var p map[string]any
+		json.Unmarshal(rec.Body.Bytes(), &p)
+		if rec.Code != http.StatusUnprocessableEntity || p["code"] != codeInvalidWorkflow {
+			t.Fatalf("%s: expected 422 %s, got %d: %s", name, codeInvalidWorkflow, rec.Code, rec.Body.String())
+		}
+	}
+	rec := doJSON(r, http.MethodPost, "/workflows", []byte(`{"tasks":[{"key":"a","type":"echo"},{"key":"b","type":"nope","dependsOn":["a"]}]}`))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for an unknown type, got %d", rec.Code)
+	}
+	// nothing is stored when any task is rejected
+	if page, _ := mem.ListTasks(context.Background(), store.ListOptions{}); len(page.Tasks) != 0 {
+		t.Fatalf("expected no tasks stored, got %d", len(page.Tasks))
+	}
+}
+
+func TestCreateTask_DependsOn(t *testing.T) {
+	mem := store.NewMemoryStore()
+	q := service.NewQueue(1)
+	defer q.Stop()
+	q.SetStore(mem)
+	r := New(mem, q, newTestRegistry()).Router()
+	ctx := context.Background()
+
+	parent, _, _ := mem.CreateOrGetByKey(ctx, "", &models.Task{Type: "echo", Status: models.StatusScheduled})
+	rec := doJSON(r, http.MethodPost, "/tasks", []byte(`{"type":"echo","dependsOn":["`+parent.ID+`"]}`))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var child models.Task
+	json.Unmarshal(rec.Body.Bytes(), &child)
+	if child.Status != models.StatusBlocked || len(child.DependsOn) != 1 || len(child.Awaiting) != 1 {
+		t.Fatalf("expected a blocked task, got %s", rec.Body.String())
+	}
+	if got, _ := mem.Get(ctx, parent.ID); len(got.Dependents) != 1 || got.Dependents[0] != child.ID {
+		t.Fatalf("expected the parent to list its dependent, got %v", got.Dependents)
+	}
+
+	// cancelling the parent fails the child straight away
+	if rec := doJSON(r, http.MethodPost, "/tasks/"+parent.ID+"/cancel", nil); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got, _ := mem.Get(ctx, child.ID); got.Status != models.StatusFailed {
+		t.Fatalf("expected the task failed with its cancelled dependency, got %s", got.Status)
+	}
+
+	// a dependency that already failed fails the task on creation
+	rec = doJSON(r, http.MethodPost, "/tasks", []byte(`{"type":"echo","dependsOn":["`+parent.ID+`"]}`))
+	json.Unmarshal(rec.Body.Bytes(), &child)
+	if rec.Code != http.StatusAccepted || child.Status != models.StatusFailed {
+		t.Fatalf("expected 202 with a failed task, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = doJSON(r, http.MethodPost, "/tasks", []byte(`{"type":"echo","dependsOn":["missing"]}`))
+	var p map[string]any
+	json.Unmarshal(rec.Body.Bytes(), &p)
+	if rec.Code != http.StatusUnprocessableEntity || p["code"] != codeUnknownDep {
+		t.Fatalf("expected 422 %s, got %d: %s", codeUnknownDep, rec.Code, rec.Body.String())
+	}
+}
+
+func TestWorkflows_DeletedWithTheirLastTask(t *testing.T) {
+	mem := store.NewMemoryStore()
+	q := service.NewQueue(1)
+	defer q.Stop()
+	r := New(mem, q, newTestRegistry()).Router()
+	ctx := context.Background()
+
+	task := &models.Task{Type: "echo", Status: models.StatusSucceeded}
+	wf, _ := mem.CreateWorkflow(ctx, &models.Workflow{}, []*models.Task{task})
+	if rec := doJSON(r, http.MethodGet, "/workflows/"+wf.ID, nil); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	janitor := service.NewJanitor(mem, service.Retention{models.StatusSucceeded: time.Minute}, time.Hour)
+	defer janitor.Stop()
+	if n, _ := janitor.Sweep(ctx, time.Now().Add(time.Hour)); n != 1 {
+		t.Fatalf("expected the task reaped, got %d", n)
+	}
+	if _, err := mem.GetWorkflow(ctx, wf.ID); !errors.Is(err, store.ErrWorkflowNotFound) {
+		t.Fatalf("expected the workflow deleted with its last task, got %v", err)
+	}
+	rec := doJSON(r, http.MethodGet, "/workflows/"+wf.ID, nil)
+	if rec.Code != http.StatusNotFound || strings.Contains(rec.Body.String(), string(models.StatusSucceeded)) {
+		t.Fatalf("expected a reaped workflow to be gone, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
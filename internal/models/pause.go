@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// PauseScope says what a Pause applies to.
+type PauseScope string
+
+const (
+	PauseQueue    PauseScope = "queue"
+	PauseTaskType PauseScope = "type"
+)
+
+// Pause stops workers from taking work of the queue or task type Name.
+// Tasks are still accepted and queued while paused.
+type Pause struct {
+	Scope    PauseScope `json:"scope"`
+	Name     string     `json:"name"`
+	PausedAt time.Time  `json:"pausedAt"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Schedule materializes a task of TaskType from its payload template every
+// time the cron expression fires in Timezone.
+type Schedule struct {
+	ID        string            `json:"id"`
+	Cron      string            `json:"cron"`
+	Timezone  string            `json:"timezone"`
+	TaskType  string            `json:"taskType"`
+	Payload   map[string]any    `json:"payload,omitempty"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	Paused    bool              `json:"paused"`
+	NextRunAt time.Time         `json:"nextRunAt"`
+	LastRunAt *time.Time        `json:"lastRunAt,omitempty"`
+	CreatedAt time.Time         `json:"createdAt"`
+	UpdatedAt time.Time         `json:"updatedAt"`
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Status is the lifecycle state of a task.
+type Status string
+
+const (
+	StatusBlocked   Status = "blocked"
+	StatusScheduled Status = "scheduled"
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusRetrying  Status = "retrying"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	StatusDead      Status = "dead"
+	StatusCancelled Status = "cancelled"
+)
+
+var statuses = []Status{
+	StatusBlocked, StatusScheduled, StatusQueued, StatusRunning, StatusRetrying,
+	StatusSucceeded, StatusFailed, StatusDead, StatusCancelled,
+}
+
+// Statuses returns every task status.
+func Statuses() []Status {
+	return append([]Status(nil), statuses...)
+}
+
+// Valid reports whether s is a known status.
+func (s Status) Valid() bool {
+	for _, v := range statuses {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrInvalidTransition is returned when a status change is not allowed by the
+// task state machine.
+var ErrInvalidTransition = errors.New("invalid status transition")
+
+// transitions lists the states reachable from each state. Terminal states
+// have no entry. running -> queued is used when work held by a dead worker is
+// reclaimed, dead -> queued when a task is replayed from the dead-letter queue,
+// queued -> scheduled when its work could not be enqueued and is retried later.
+// A blocked task waits for its dependencies and fails when one of them does.
+var transitions = map[Status][]Status{
+	StatusBlocked:   {StatusQueued, StatusScheduled, StatusFailed, StatusCancelled},
+	StatusScheduled: {StatusQueued, StatusCancelled},
+	StatusQueued:    {StatusRunning, StatusCancelled, StatusScheduled},
+	StatusRunning:   {StatusSucceeded, StatusRetrying, StatusFailed, StatusDead, StatusCancelled, StatusQueued},
+	StatusRetrying:  {StatusRunning, StatusCancelled},
+	StatusDead:      {StatusQueued},
+}
+
+// CanTransitionTo reports whether the state machine allows s -> next.
+func (s Status) CanTransitionTo(next Status) bool {
+	for _, to := range transitions[s] {
+		if to == next {
+			return true
+		}
+	}
+	return false
+}
+
+// Terminal reports whether no further transitions are possible from s.
+func (s Status) Terminal() bool {
+	return len(transitions[s]) == 0
+}
+
+// Finished reports whether no more work is pending for a task in s: it is
+// terminal, or dead and only waiting to be replayed.
+func (s Status) Finished() bool {
+	return s.Terminal() || s == StatusDead
+}
+
+// Transition moves t to next, rejecting changes the state machine does not allow.
+func (t *Task) Transition(next Status) error {
+	if !t.Status.CanTransitionTo(next) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, t.Status, next)
+	}
+	t.Status = next
+	return nil
+}
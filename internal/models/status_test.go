@@ -0,0 +1,70 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTransition_HappyPath(t *testing.T) {
+	task := &Task{Status: StatusQueued}
+	for _, next := range []Status{StatusRunning, StatusRetrying, StatusRunning, StatusSucceeded} {
+		if err := task.Transition(next); err != nil {
+			t.Fatalf("transition to %s: %v", next, err)
+		}
+	}
+	if !task.Status.Terminal() {
+		t.Fatalf("expected %s to be terminal", task.Status)
+	}
+}
+
+func TestTransition_ReplayFromDead(t *testing.T) {
+	task := &Task{Status: StatusDead}
+	if err := task.Transition(StatusQueued); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if StatusDead.CanTransitionTo(StatusCancelled) {
+		t.Fatalf("dead tasks can only be replayed")
+	}
+}
+
+func TestTransition_RejectsIllegal(t *testing.T) {
+	cases := []struct {
+		from, to Status
+	}{
+		{StatusQueued, StatusSucceeded},
+		{StatusSucceeded, StatusRunning},
+		{StatusCancelled, StatusQueued},
+		{StatusDead, StatusRunning},
+		{StatusRunning, StatusRunning},
+		{StatusBlocked, StatusRunning},
+	}
+	for _, c := range cases {
+		task := &Task{Status: c.from}
+		err := task.Transition(c.to)
+		if !errors.Is(err, ErrInvalidTransition) {
+			t.Errorf("%s -> %s: expected ErrInvalidTransition, got %v", c.from, c.to, err)
+		}
+		if task.Status != c.from {
+			t.Errorf("%s -> %s: status changed to %s on rejected transition", c.from, c.to, task.Status)
+		}
+	}
+}
+
+func TestWorkflowStatus(t *testing.T) {
+	cases := []struct {
+		statuses []Status
+		want     Status
+	}{
+		{[]Status{StatusSucceeded, StatusBlocked}, StatusRunning},
+		{[]Status{StatusFailed, StatusQueued}, StatusRunning},
+		{[]Status{StatusSucceeded, StatusSucceeded}, StatusSucceeded},
+		{[]Status{StatusSucceeded, StatusDead, StatusCancelled}, StatusFailed},
+		{[]Status{StatusSucceeded, StatusCancelled}, StatusCancelled},
+		{nil, ""},
+	}
+	for _, c := range cases {
+		if got := WorkflowStatus(c.statuses); got != c.want {
+			t.Errorf("WorkflowStatus(%v) = %s, want %s", c.statuses, got, c.want)
+		}
+	}
+}
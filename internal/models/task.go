@@ -3,11 +3,74 @@ package models
 import "time"
 
 type Task struct {
-	ID        string         `json:"id"`
-	Type      string         `json:"type"`
-	Payload   map[string]any `json:"payload,omitempty"`
-	Status    string         `json:"status"`
-	Result    map[string]any `json:"result,omitempty"`
-	CreatedAt time.Time      `json:"createdAt"`
-	UpdatedAt time.Time      `json:"updatedAt"`
+	ID        string            `json:"id"`
+	Type      string            `json:"type"`
+	Payload   map[string]any    `json:"payload,omitempty"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	Status    Status            `json:"status"`
+	Result    map[string]any    `json:"result,omitempty"`
+	RunAt     *time.Time        `json:"runAt,omitempty"`
+	Attempts  int               `json:"attempts"`
+	LastError string            `json:"lastError,omitempty"`
+	History   []Attempt         `json:"history,omitempty"`
+	CreatedAt time.Time         `json:"createdAt"`
+	UpdatedAt time.Time         `json:"updatedAt"`
+
+	// TimeoutSeconds limits each run of the task; 0 uses the queue default.
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"`
+	// IdempotencyKey is the key the task was created under, released when
+	// the task is deleted. It is internal: stores persist it alongside the
+	// task, but it is never rendered.
+	IdempotencyKey string `json:"-"`
+	// Version is incremented on every write; clients pass it back in
+	// If-Match to update only the state they have seen.
+	Version int64 `json:"version"`
+	// RequestFingerprint identifies the request that created the task, so a
+	// reused idempotency key with a different request can be rejected. Like
+	// IdempotencyKey, it is never rendered.
+	RequestFingerprint string `json:"-"`
+
+	// Priority orders queued work, from MinPriority to MaxPriority; higher
+	// runs first.
+	Priority int `json:"priority"`
+	// Queue names the queue whose workers run the task; empty means the
+	// default queue.
+	Queue string `json:"queue,omitempty"`
+
+	// DependsOn lists the tasks that must succeed before this one runs; the
+	// task is blocked until then.
+	DependsOn []string `json:"dependsOn,omitempty"`
+	// Awaiting lists the dependencies that have yet to succeed. Each success
+	// is recorded here, so a dependency reaped afterwards is not missed.
+	Awaiting []string `json:"awaiting,omitempty"`
+	// Dependents lists the tasks that depend on this one; they are settled
+	// when it finishes.
+	Dependents []string `json:"dependents,omitempty"`
+	// WorkflowID is the workflow the task was submitted with, if any.
+	WorkflowID string `json:"workflowId,omitempty"`
+}
+
+// MinPriority, the default, and MaxPriority bound Task.Priority.
+const (
+	MinPriority = 0
+	MaxPriority = 9
+)
+
+// Attempt records a single execution of a task by a worker.
+type Attempt struct {
+	Number     int       `json:"number"`
+	WorkerID   string    `json:"workerId"`
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// AddAttempt appends a to the task history and updates the attempt count and
+// last error.
+func (t *Task) AddAttempt(a Attempt) {
+	t.History = append(t.History, a)
+	t.Attempts = len(t.History)
+	if a.Error != "" {
+		t.LastError = a.Error
+	}
 }
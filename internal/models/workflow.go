@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Workflow is a set of tasks submitted together, whose dependencies form a
+// DAG. Tasks maps the keys the client gave its tasks to their IDs.
+type Workflow struct {
+	ID        string            `json:"id"`
+	Name      string            `json:"name,omitempty"`
+	Tasks     map[string]string `json:"tasks"`
+	CreatedAt time.Time         `json:"createdAt"`
+}
+
+// WorkflowStatus sums up the statuses of a workflow's tasks: running while
+// any task is unfinished, then succeeded if all succeeded, failed if any
+// failed or is dead, and cancelled otherwise. It is empty without statuses,
+// since nothing is known of a workflow whose tasks are gone.
+func WorkflowStatus(statuses []Status) Status {
+	if len(statuses) == 0 {
+		return ""
+	}
+	failed := false
+	succeeded := true
+	for _, s := range statuses {
+		if !s.Finished() {
+			return StatusRunning
+		}
+		failed = failed || s == StatusFailed || s == StatusDead
+		succeeded = succeeded && s == StatusSucceeded
+	}
+	switch {
+	case succeeded:
+		return StatusSucceeded
+	case failed:
+		return StatusFailed
+	}
+	return StatusCancelled
+}
@@ -0,0 +1,418 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/husainaj20/task-manager-api/internal/models"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	boltPending   = []byte("pending")   // MaxPriority-priority (1 byte) + sequence (big endian) -> delivery id
+	boltItems     = []byte("items")     // delivery id -> TaskWork JSON
+	boltDelayed   = []byte("delayed")   // due time (unix ns, big endian) + delivery id -> nil
+	boltInflight  = []byte("inflight")  // delivery id -> nil, while handed out
+	boltReclaimed = []byte("reclaimed") // delivery id -> nil, for work taken back on open
+)
+
+// BoltBroker is a durable Broker in a bbolt file, for single-process
+// deployments without Redis. Every change is synced before it returns, so
+// work survives a crash: deliveries handed out and not settled when the
+// process stopped are put back on the pending list, marked redelivered, the
+// next time the file is opened. The file is locked while open, so only one
+// process consumes from it.
+type BoltBroker struct {
+	db           *bolt.DB
+	pollInterval time.Duration
+	wake         chan struct{}
+
+	mu          sync.Mutex
+	closed      bool
+	outstanding int
+	settled     *sync.Cond
+
+	// taken counts deliveries handed out, for oldestTurn; it is only used
+	// in write transactions, which bolt runs one at a time.
+	taken uint64
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewBoltBroker opens, or creates, the queue file at path and reclaims work
+// left in flight by the previous process.
+func NewBoltBroker(path string) (*BoltBroker, error) {
+	return newBoltBroker(path, 100*time.Millisecond)
+}
+
+func newBoltBroker(path string, poll time.Duration) (*BoltBroker, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	b := &BoltBroker{
+		db:           db,
+		pollInterval: poll,
+		wake:         make(chan struct{}, 1),
+		done:         make(chan struct{}),
+	}
+	b.settled = sync.NewCond(&b.mu)
+	if err := db.Update(b.reclaim); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return b, nil
+}
+
+func seqKey(n uint64) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, n)
+	return k
+}
+
+func dueKey(due time.Time, id string) []byte {
+	return append(seqKey(uint64(due.UnixNano())), id...)
+}
+
+// pendingKey orders the pending bucket by priority, highest first, then by
+// arrival.
+func pendingKey(priority int, seq uint64) []byte {
+	return append([]byte{byte(models.MaxPriority - priority)}, seqKey(seq)...)
+}
+
+func pushPending(tx *bolt.Tx, id []byte, priority int) error {
+	pending := tx.Bucket(boltPending)
+	seq, err := pending.NextSequence()
+	if err != nil {
+		return err
+	}
+	return pending.Put(pendingKey(priority, seq), id)
+}
+
+// itemPriority returns the priority of the stored work item id.
+func itemPriority(tx *bolt.Tx, id []byte) int {
+	var w TaskWork
+	json.Unmarshal(tx.Bucket(boltItems).Get(id), &w)
+	return priorityOf(&w)
+}
+
+// reclaim creates the buckets and moves deliveries left in flight back to the
+// pending list.
+func (b *BoltBroker) reclaim(tx *bolt.Tx) error {
+	for _, name := range [][]byte{boltPending, boltItems, boltDelayed, boltInflight, boltReclaimed} {
+		if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+			return err
+		}
+	}
+	// files written before priorities keyed pending work by sequence only
+	var legacy [][]byte
+	tx.Bucket(boltPending).ForEach(func(k, _ []byte) error {
+		if len(k) == 8 {
+			legacy = append(legacy, append([]byte(nil), k...))
+		}
+		return nil
+	})
+	for _, k := range legacy {
+		id := append([]byte(nil), tx.Bucket(boltPending).Get(k)...)
+		if err := tx.Bucket(boltPending).Delete(k); err != nil {
+			return err
+		}
+		if err := pushPending(tx, id, itemPriority(tx, id)); err != nil {
+			return err
+		}
+	}
+
+	var held [][]byte
+	tx.Bucket(boltInflight).ForEach(func(id, _ []byte) error {
+		held = append(held, append([]byte(nil), id...))
+		return nil
+	})
+	for _, id := range held {
+		if err := pushPending(tx, id, itemPriority(tx, id)); err != nil {
+			return err
+		}
+		if err := tx.Bucket(boltReclaimed).Put(id, nil); err != nil {
+			return err
+		}
+		if err := tx.Bucket(boltInflight).Delete(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *BoltBroker) isClosed() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.closed
+}
+
+func (b *BoltBroker) Enqueue(ctx context.Context, w *TaskWork) error {
+	if b.isClosed() {
+		return ErrBrokerClosed
+	}
+	data, err := json.Marshal(w)
+	if err != nil {
+		return err
+	}
+	id := []byte(uuid.NewString())
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(boltItems).Put(id, data); err != nil {
+			return err
+		}
+		return pushPending(tx, id, priorityOf(w))
+	})
+	if err != nil {
+		return err
+	}
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Consume takes the next pending delivery by priority, as described at
+// oldestEvery, waiting for an Enqueue or for the next poll, which also
+// promotes due retries.
+func (b *BoltBroker) Consume(ctx context.Context) (*Delivery, error) {
+	for {
+		b.mu.Lock()
+		if b.closed {
+			b.mu.Unlock()
+			return nil, ErrBrokerClosed
+		}
+		b.outstanding++
+		b.mu.Unlock()
+
+		d, err := b.take()
+		if d != nil {
+			return d, nil
+		}
+		b.settle()
+		if err != nil {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-b.done:
+			return nil, ErrBrokerClosed
+		case <-b.wake:
+		case <-time.After(b.pollInterval):
+		}
+	}
+}
+
+// hasWork reports whether any delivery is pending or due. It lets idle polls
+// look in a read transaction instead of committing, and syncing, a write.
+func (b *BoltBroker) hasWork() (bool, error) {
+	found := false
+	err := b.db.View(func(tx *bolt.Tx) error {
+		if k, _ := tx.Bucket(boltPending).Cursor().First(); k != nil {
+			found = true
+			return nil
+		}
+		k, _ := tx.Bucket(boltDelayed).Cursor().First()
+		found = k != nil && bytes.Compare(k[:8], seqKey(uint64(time.Now().UnixNano()))) <= 0
+		return nil
+	})
+	return found, err
+}
+
+// take promotes due retries and marks at most one pending delivery in flight.
+// The pending bucket is ordered by priority, so the next delivery is either
+// its first key or, on an oldest turn, the lowest sequence among the first
+// keys of each priority.
+func (b *BoltBroker) take() (*Delivery, error) {
+	if ok, err := b.hasWork(); !ok || err != nil {
+		return nil, err
+	}
+	var d *Delivery
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		now := seqKey(uint64(time.Now().UnixNano()))
+		var due [][]byte
+		c := tx.Bucket(boltDelayed).Cursor()
+		for k, _ := c.First(); k != nil && bytes.Compare(k[:8], now) <= 0; k, _ = c.Next() {
+			due = append(due, append([]byte(nil), k...))
+		}
+		for _, k := range due {
+			if err := pushPending(tx, k[8:], itemPriority(tx, k[8:])); err != nil {
+				return err
+			}
+			if err := tx.Bucket(boltDelayed).Delete(k); err != nil {
+				return err
+			}
+		}
+
+		c = tx.Bucket(boltPending).Cursor()
+		k, v := c.First()
+		if k == nil {
+			return nil
+		}
+		b.taken++
+		if oldestTurn(b.taken) {
+			for level := k[0] + 1; level <= models.MaxPriority; level++ {
+				lk, lv := c.Seek([]byte{level})
+				if lk == nil {
+					break
+				}
+				if lk[0] == level && bytes.Compare(lk[1:], k[1:]) < 0 {
+					k, v = lk, lv
+				}
+			}
+		}
+		k = append([]byte(nil), k...)
+		id := append([]byte(nil), v...)
+		if err := tx.Bucket(boltPending).Delete(k); err != nil {
+			return err
+		}
+		item := tx.Bucket(boltItems).Get(id)
+		if item == nil {
+			// item vanished; drop the dangling id
+			return nil
+		}
+		var w TaskWork
+		if err := json.Unmarshal(item, &w); err != nil {
+			return err
+		}
+		if err := tx.Bucket(boltInflight).Put(id, nil); err != nil {
+			return err
+		}
+		d = &Delivery{ID: string(id), Work: &w, Redelivered: tx.Bucket(boltReclaimed).Get(id) != nil}
+		return nil
+	})
+	return d, err
+}
+
+func (b *BoltBroker) settle() {
+	b.mu.Lock()
+	b.outstanding--
+	b.settled.Broadcast()
+	b.mu.Unlock()
+}
+
+func (b *BoltBroker) Ack(ctx context.Context, d *Delivery) error {
+	defer b.settle()
+	id := []byte(d.ID)
+	return b.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltInflight, boltItems, boltReclaimed} {
+			if err := tx.Bucket(name).Delete(id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (b *BoltBroker) Nack(ctx context.Context, d *Delivery, delay time.Duration) error {
+	defer b.settle()
+	data, err := json.Marshal(d.Work)
+	if err != nil {
+		return err
+	}
+	id := []byte(d.ID)
+	return b.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltInflight, boltReclaimed} {
+			if err := tx.Bucket(name).Delete(id); err != nil {
+				return err
+			}
+		}
+		if err := tx.Bucket(boltItems).Put(id, data); err != nil {
+			return err
+		}
+		return tx.Bucket(boltDelayed).Put(dueKey(time.Now().Add(delay), d.ID), nil)
+	})
+}
+
+// Cancel removes pending and delayed deliveries of task id, leaving those in
+// flight to their worker.
+func (b *BoltBroker) Cancel(ctx context.Context, id string) (int, error) {
+	removed := 0
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		inflight := tx.Bucket(boltInflight)
+		match := map[string]bool{}
+		tx.Bucket(boltItems).ForEach(func(did, data []byte) error {
+			var w TaskWork
+			if inflight.Get(did) == nil && json.Unmarshal(data, &w) == nil && w.ID == id {
+				match[string(did)] = true
+			}
+			return nil
+		})
+		if len(match) == 0 {
+			return nil
+		}
+		var drop [][]byte
+		tx.Bucket(boltPending).ForEach(func(k, did []byte) error {
+			if match[string(did)] {
+				drop = append(drop, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		for _, k := range drop {
+			if err := tx.Bucket(boltPending).Delete(k); err != nil {
+				return err
+			}
+		}
+		drop = drop[:0]
+		tx.Bucket(boltDelayed).ForEach(func(k, _ []byte) error {
+			if match[string(k[8:])] {
+				drop = append(drop, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		for _, k := range drop {
+			if err := tx.Bucket(boltDelayed).Delete(k); err != nil {
+				return err
+			}
+		}
+		for did := range match {
+			if err := tx.Bucket(boltItems).Delete([]byte(did)); err != nil {
+				return err
+			}
+		}
+		removed = len(match)
+		return nil
+	})
+	return removed, err
+}
+
+func (b *BoltBroker) Stats(ctx context.Context) (BrokerStats, error) {
+	var s BrokerStats
+	err := b.db.View(func(tx *bolt.Tx) error {
+		s.ByPriority = map[int]int64{}
+		tx.Bucket(boltPending).ForEach(func(k, _ []byte) error {
+			s.ByPriority[models.MaxPriority-int(k[0])]++
+			s.Pending++
+			return nil
+		})
+		s.Delayed = int64(tx.Bucket(boltDelayed).Stats().KeyN)
+		s.Inflight = int64(tx.Bucket(boltInflight).Stats().KeyN)
+		return nil
+	})
+	return s, err
+}
+
+// Close stops handing out work, waits for outstanding deliveries to be
+// settled and releases the file. Pending work stays in it for the next start.
+func (b *BoltBroker) Close() error {
+	var err error
+	b.closeOnce.Do(func() {
+		b.mu.Lock()
+		b.closed = true
+		close(b.done)
+		for b.outstanding > 0 {
+			b.settled.Wait()
+		}
+		b.mu.Unlock()
+		err = b.db.Close()
+	})
+	return err
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestBoltBroker(t *testing.T, path string) *BoltBroker {
+	t.Helper()
+	b, err := newBoltBroker(path, 2*time.Millisecond)
+	if err != nil {
+		t.Fatalf("bolt broker: %v", err)
+	}
+	return b
+}
+
+func TestBoltBroker_RecoversAfterRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.db")
+	ctx := context.Background()
+	b := newTestBoltBroker(t, path)
+	for _, id := range []string{"held", "retry", "pending"} {
+		if err := b.Enqueue(ctx, &TaskWork{ID: id}); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+	}
+	held, _ := b.Consume(ctx)
+	retry, _ := b.Consume(ctx)
+	retry.Work.Attempts = 1
+	if err := b.Nack(ctx, retry, 0); err != nil {
+		t.Fatalf("nack: %v", err)
+	}
+	// the process dies holding the first delivery
+	b.settle()
+	b.Close()
+
+	b = newTestBoltBroker(t, path)
+	defer b.Close()
+	s, _ := b.Stats(ctx)
+	if s.Pending != 2 || s.Delayed != 1 || s.Inflight != 0 {
+		t.Fatalf("unexpected stats after restart %+v", s)
+	}
+	got := map[string]*Delivery{}
+	for i := 0; i < 3; i++ {
+		d, err := b.Consume(ctx)
+		if err != nil {
+			t.Fatalf("consume: %v", err)
+		}
+		got[d.Work.ID] = d
+		b.Ack(ctx, d)
+	}
+	if d := got[held.Work.ID]; d == nil || !d.Redelivered {
+		t.Fatalf("expected held work redelivered, got %+v", d)
+	}
+	if d := got["pending"]; d == nil || d.Redelivered {
+		t.Fatalf("expected pending work delivered normally, got %+v", d)
+	}
+	if d := got["retry"]; d == nil || d.Work.Attempts != 1 {
+		t.Fatalf("expected the retry to keep its attempt count, got %+v", d)
+	}
+	if s, _ := b.Stats(ctx); s.Pending+s.Delayed+s.Inflight != 0 || len(s.ByPriority) != 0 {
+		t.Fatalf("expected an empty queue, got %+v", s)
+	}
+}
+
+func TestBoltBroker_CloseKeepsPendingWork(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.db")
+	ctx := context.Background()
+	b := newTestBoltBroker(t, path)
+	for i := 0; i < 3; i++ {
+		b.Enqueue(ctx, &TaskWork{ID: "t"})
+	}
+	b.Close()
+	if _, err := b.Consume(ctx); !errors.Is(err, ErrBrokerClosed) {
+		t.Fatalf("expected ErrBrokerClosed, got %v", err)
+	}
+	if err := b.Enqueue(ctx, &TaskWork{ID: "t"}); !errors.Is(err, ErrBrokerClosed) {
+		t.Fatalf("expected ErrBrokerClosed on enqueue, got %v", err)
+	}
+
+	next := newTestBoltBroker(t, path)
+	defer next.Close()
+	if s, _ := next.Stats(ctx); s.Pending != 3 {
+		t.Fatalf("expected 3 pending after restart, got %+v", s)
+	}
+}
+
+func TestBoltBroker_IdlePollsDoNotWrite(t *testing.T) {
+	b := newTestBoltBroker(t, filepath.Join(t.TempDir(), "queue.db"))
+	defer b.Close()
+
+	writes := func() int64 {
+		s := b.db.Stats()
+		return s.TxStats.GetWrite()
+	}
+	before := writes()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	if _, err := b.Consume(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected nothing to consume, got %v", err)
+	}
+	if n := writes() - before; n != 0 {
+		t.Fatalf("expected idle polls not to write, got %d writes", n)
+	}
+
+	b.Enqueue(context.Background(), &TaskWork{ID: "1"})
+	d, err := b.Consume(context.Background())
+	if err != nil || d.Work.ID != "1" {
+		t.Fatalf("expected the enqueued work, got %v, %v", d, err)
+	}
+	b.Nack(context.Background(), d, 5*time.Millisecond)
+	if d, err = b.Consume(context.Background()); err != nil || d.Work.ID != "1" {
+		t.Fatalf("expected the due retry, got %v, %v", d, err)
+	}
+	b.Ack(context.Background(), d)
+}
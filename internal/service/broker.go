@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrBrokerClosed is returned by a Broker once it has been closed.
+var ErrBrokerClosed = errors.New("broker closed")
+
+// Delivery is a unit of work handed out by a Broker. Every delivery must be
+// settled with exactly one Ack or Nack.
+type Delivery struct {
+	ID   string
+	Work *TaskWork
+	// Redelivered is set when the work was reclaimed from a consumer that
+	// died while holding it.
+	Redelivered bool
+}
+
+// BrokerStats reports how much work a broker holds.
+type BrokerStats struct {
+	Pending  int64 // ready to be consumed
+	Delayed  int64 // waiting for a retry delay to pass
+	Inflight int64 // handed out by this broker and not yet settled
+
+	// ByPriority splits Pending by task priority; priorities without
+	// pending work are left out.
+	ByPriority map[int]int64
+}
+
+// Broker stores work between enqueue and processing. Queue runs workers on
+// top of any Broker; implementations decide how durable the work is.
+type Broker interface {
+	Enqueue(ctx context.Context, w *TaskWork) error
+	// Consume blocks until a delivery is available, ctx is done or the
+	// broker is closed.
+	Consume(ctx context.Context) (*Delivery, error)
+	// Ack removes a settled delivery from the broker.
+	Ack(ctx context.Context, d *Delivery) error
+	// Nack returns a delivery for redelivery after delay, persisting any
+	// changes made to d.Work.
+	Nack(ctx context.Context, d *Delivery, delay time.Duration) error
+	// Cancel drops pending and delayed deliveries of task id and reports how
+	// many were removed. Deliveries already handed out are left to their
+	// consumer.
+	Cancel(ctx context.Context, id string) (int, error)
+	Stats(ctx context.Context) (BrokerStats, error)
+	// Close stops accepting work. Consume returns ErrBrokerClosed once there
+	// is nothing more to hand out to this process; deliveries already handed
+	// out may still be settled, and Close waits for them.
+	Close() error
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/husainaj20/task-manager-api/internal/models"
+	"github.com/husainaj20/task-manager-api/internal/store"
+)
+
+func TestCancel_StopsRunningProcessor(t *testing.T) {
+	forEachBroker(t, func(t *testing.T, newBroker func() Broker) {
+		st := store.NewMemoryStore()
+		q := NewQueueWithBroker(newBroker(), 1)
+		defer q.Stop()
+		q.SetStore(st)
+		started := make(chan struct{})
+		stopped := make(chan error, 1)
+		q.SetProcessor(func(ctx context.Context, tw *TaskWork) error {
+			close(started)
+			<-ctx.Done()
+			stopped <- ctx.Err()
+			return ctx.Err()
+		})
+
+		task := createQueued(t, st, "echo")
+		q.Enqueue(NewTaskWork(task))
+		<-started
+		ctx := context.Background()
+		if err := st.UpdateStatus(ctx, task.ID, models.StatusCancelled, nil); err != nil {
+			t.Fatalf("cancel: %v", err)
+		}
+		if err := q.Cancel(ctx, task.ID); err != nil {
+			t.Fatalf("queue cancel: %v", err)
+		}
+
+		select {
+		case err := <-stopped:
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("expected context.Canceled, got %v", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("processor context was not cancelled")
+		}
+		if !q.WaitIdle(time.Second) {
+			t.Fatalf("queue did not become idle")
+		}
+		got, _ := st.Get(ctx, task.ID)
+		if got.Status != models.StatusCancelled || len(got.History) != 1 {
+			t.Fatalf("expected cancelled task with one attempt, got %+v", got)
+		}
+	})
+}
+
+func TestCancel_DropsPendingRetry(t *testing.T) {
+	forEachBroker(t, func(t *testing.T, newBroker func() Broker) {
+		st := store.NewMemoryStore()
+		q := NewQueueWithBroker(newBroker(), 1)
+		q.ConfigureRetry(5, time.Hour, 2.0, time.Hour, false)
+		defer q.Stop()
+		q.SetStore(st)
+		var calls int32
+		q.SetProcessor(func(ctx context.Context, tw *TaskWork) error {
+			atomic.AddInt32(&calls, 1)
+			return errors.New("transient")
+		})
+
+		task := createQueued(t, st, "echo")
+		q.Enqueue(NewTaskWork(task))
+		waitStatus(t, st, task.ID, models.StatusRetrying)
+
+		ctx := context.Background()
+		if err := st.UpdateStatus(ctx, task.ID, models.StatusCancelled, nil); err != nil {
+			t.Fatalf("cancel: %v", err)
+		}
+		if err := q.Cancel(ctx, task.ID); err != nil {
+			t.Fatalf("queue cancel: %v", err)
+		}
+		if !q.WaitIdle(time.Second) {
+			t.Fatalf("expected the delayed retry to be dropped")
+		}
+		if n := atomic.LoadInt32(&calls); n != 1 {
+			t.Fatalf("expected a single attempt, got %d", n)
+		}
+	})
+}
+
+func TestBroker_CancelRemovesPendingWork(t *testing.T) {
+	forEachBroker(t, func(t *testing.T, newBroker func() Broker) {
+		b := newBroker()
+		defer b.Close()
+		ctx := context.Background()
+		for _, id := range []string{"a", "b", "a"} {
+			if err := b.Enqueue(ctx, &TaskWork{ID: id}); err != nil {
+				t.Fatalf("enqueue: %v", err)
+			}
+		}
+		n, err := b.Cancel(ctx, "a")
+		if err != nil || n != 2 {
+			t.Fatalf("expected 2 removed, got %d (%v)", n, err)
+		}
+		d, err := b.Consume(ctx)
+		if err != nil || d.Work.ID != "b" {
+			t.Fatalf("expected remaining work b, got %+v (%v)", d, err)
+		}
+		b.Ack(ctx, d)
+		s, _ := b.Stats(ctx)
+		if s.Pending != 0 {
+			t.Fatalf("expected nothing pending, got %+v", s)
+		}
+	})
+}
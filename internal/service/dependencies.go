@@ -0,0 +1,242 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/husainaj20/task-manager-api/internal/models"
+	"github.com/husainaj20/task-manager-api/internal/store"
+)
+
+// DependencyResolver settles blocked tasks. Each task lists the dependencies
+// it is still awaiting, and each dependency lists its dependents, so when a
+// task finishes Settle records the outcome on its dependents: a success is
+// struck off their awaiting list, releasing those left with nothing to await
+// to the queue, or scheduling them if their run time is still ahead; a
+// failure, dead task or cancellation fails them, which in turn fails the
+// tasks that depend on them. As a safety net for outcomes that were never
+// recorded, e.g. after a crash, the resolver also polls the blocked tasks.
+// Like the Promoter, any number of replicas may run one: only the replica
+// whose store update wins enqueues a released task.
+type DependencyResolver struct {
+	store store.Store
+	q     Enqueuer
+	batch int
+
+	wg       sync.WaitGroup
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewDependencyResolver(st store.Store, q Enqueuer, interval time.Duration) *DependencyResolver {
+	r := &DependencyResolver{store: st, q: q, batch: 100, stop: make(chan struct{})}
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+				if _, err := r.ResolveBlocked(context.Background()); err != nil {
+					log.Printf("dependencies: %v", err)
+				}
+			}
+		}
+	}()
+	return r
+}
+
+// Settle records the outcome of task id, once finished, on its dependents.
+// It is meant as a queue's FinishHandler.
+func (r *DependencyResolver) Settle(id string) {
+	ctx := context.Background()
+	t, err := r.store.Get(ctx, id)
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			log.Printf("dependencies: task %s: %v", id, err)
+		}
+		return
+	}
+	SettleDependents(ctx, r.store, r.q, t)
+}
+
+// ResolveBlocked settles every blocked task whose awaited dependencies have
+// finished or no longer exist, and returns how many it changed. Tasks are
+// visited oldest first, so a failure usually reaches all of its descendants
+// in one pass.
+func (r *DependencyResolver) ResolveBlocked(ctx context.Context) (int, error) {
+	total := 0
+	opts := store.ListOptions{Statuses: []models.Status{models.StatusBlocked}, Limit: r.batch}
+	for {
+		page, err := r.store.ListTasks(ctx, opts)
+		if err != nil {
+			return total, err
+		}
+		for _, t := range page.Tasks {
+			changed, err := r.resolve(ctx, t)
+			if err != nil {
+				log.Printf("dependencies: task %s: %v", t.ID, err)
+			}
+			if changed {
+				total++
+			}
+		}
+		if page.NextCursor == "" {
+			return total, nil
+		}
+		opts.Cursor = page.NextCursor
+	}
+}
+
+// resolve records on t the outcome of each awaited dependency that has one.
+// A dependency that no longer exists fails t: its success, had there been
+// one, would have been struck off before it could be reaped.
+func (r *DependencyResolver) resolve(ctx context.Context, t *models.Task) (bool, error) {
+	changed := false
+	for _, id := range t.Awaiting {
+		parent, err := r.store.Get(ctx, id)
+		var reason string
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			reason = fmt.Sprintf("dependency %s not found", id)
+		case err != nil:
+			return changed, err
+		case !parent.Status.Finished():
+			continue
+		default:
+			reason = failureReason(parent)
+		}
+		updated, err := settle(ctx, r.store, r.q, t.ID, id, reason)
+		if err != nil {
+			return changed, err
+		}
+		if updated != nil {
+			changed = true
+			if updated.Status != models.StatusBlocked {
+				return true, nil
+			}
+		}
+	}
+	return changed, nil
+}
+
+func (r *DependencyResolver) Stop() {
+	r.stopOnce.Do(func() {
+		close(r.stop)
+		r.wg.Wait()
+	})
+}
+
+// SettleDependents records the outcome of parent, if it has finished, on
+// each of its dependents still awaiting it. Failures are logged.
+func SettleDependents(ctx context.Context, st store.Store, q Enqueuer, parent *models.Task) {
+	if !parent.Status.Finished() {
+		return
+	}
+	reason := failureReason(parent)
+	for _, id := range parent.Dependents {
+		if _, err := settle(ctx, st, q, id, parent.ID, reason); err != nil {
+			log.Printf("dependencies: task %s: %v", id, err)
+		}
+	}
+}
+
+// AwaitDependencies registers t, just created, as a dependent of each task it
+// awaits. A dependency that finished before it could list t is settled on t
+// straight away. It returns t as last updated.
+func AwaitDependencies(ctx context.Context, st store.Store, q Enqueuer, t *models.Task) (*models.Task, error) {
+	for _, id := range t.Awaiting {
+		parent, err := st.Update(ctx, id, 0, func(p *models.Task) error {
+			p.Dependents = append(p.Dependents, t.ID)
+			return nil
+		})
+		var reason string
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			reason = fmt.Sprintf("dependency %s not found", id)
+		case err != nil:
+			return t, err
+		case !parent.Status.Finished():
+			continue
+		default:
+			reason = failureReason(parent)
+		}
+		updated, err := settle(ctx, st, q, t.ID, id, reason)
+		if err != nil {
+			return t, err
+		}
+		if updated != nil {
+			t = updated
+		}
+	}
+	return t, nil
+}
+
+// failureReason describes why the finished task parent fails its
+// dependents, or returns "" if it succeeded.
+func failureReason(parent *models.Task) string {
+	if parent.Status == models.StatusSucceeded {
+		return ""
+	}
+	return fmt.Sprintf("dependency %s is %s", parent.ID, parent.Status)
+}
+
+// errSettled aborts the update of a task that no longer awaits a dependency.
+var errSettled = errors.New("dependency already settled")
+
+// settle records on task id that its dependency parentID succeeded, or, if
+// reason is set, failed it for that reason. A task left awaiting nothing is
+// released; a failed one fails its own dependents in turn. It returns the
+// updated task, or nil if id no longer awaited parentID.
+func settle(ctx context.Context, st store.Store, q Enqueuer, id, parentID, reason string) (*models.Task, error) {
+	updated, err := st.Update(ctx, id, 0, func(t *models.Task) error {
+		awaiting := without(t.Awaiting, parentID)
+		if t.Status != models.StatusBlocked || len(awaiting) == len(t.Awaiting) {
+			return errSettled
+		}
+		t.Awaiting = awaiting
+		switch {
+		case reason != "":
+			t.LastError = reason
+			return t.Transition(models.StatusFailed)
+		case len(t.Awaiting) > 0:
+			return nil
+		case t.RunAt != nil && t.RunAt.After(time.Now()):
+			return t.Transition(models.StatusScheduled)
+		default:
+			return t.Transition(models.StatusQueued)
+		}
+	})
+	if errors.Is(err, errSettled) || errors.Is(err, store.ErrNotFound) {
+		// deleted, or settled by another replica meanwhile
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch updated.Status {
+	case models.StatusQueued:
+		return EnqueueTask(ctx, st, q, updated)
+	case models.StatusFailed:
+		SettleDependents(ctx, st, q, updated)
+	}
+	return updated, nil
+}
+
+// without returns ids less every occurrence of id, leaving ids as they are.
+func without(ids []string, id string) []string {
+	var out []string
+	for _, v := range ids {
+		if v != id {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/husainaj20/task-manager-api/internal/models"
+	"github.com/husainaj20/task-manager-api/internal/store"
+)
+
+// createBlocked stores a task blocked on parents and registers it with them.
+func createBlocked(t *testing.T, st store.Store, q Enqueuer, parents ...string) *models.Task {
+	t.Helper()
+	ctx := context.Background()
+	task, _, err := st.CreateOrGetByKey(ctx, "", &models.Task{
+		Type: "echo", Status: models.StatusBlocked, DependsOn: parents, Awaiting: parents,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task, err = AwaitDependencies(ctx, st, q, task); err != nil {
+		t.Fatal(err)
+	}
+	return task
+}
+
+func TestDependencyResolver_ReleasesWhenParentsFinish(t *testing.T) {
+	st := store.NewMemoryStore()
+	q := NewQueue(1)
+	defer q.Stop()
+	q.SetStore(st)
+	// the poll never runs: release is driven by the parents finishing
+	r := NewDependencyResolver(st, q, time.Hour)
+	defer r.Stop()
+	q.SetFinishHandler(r.Settle)
+	q.SetProcessor(func(ctx context.Context, tw *TaskWork) error { return nil })
+
+	a := createQueued(t, st, "echo")
+	b := createQueued(t, st, "echo")
+	child := createBlocked(t, st, q, a.ID, b.ID)
+
+	q.Enqueue(NewTaskWork(a))
+	waitStatus(t, st, a.ID, models.StatusSucceeded)
+	got, _ := st.Get(context.Background(), child.ID)
+	if got.Status != models.StatusBlocked || len(got.Awaiting) != 1 || got.Awaiting[0] != b.ID {
+		t.Fatalf("expected the child to await only %s, got %s %v", b.ID, got.Status, got.Awaiting)
+	}
+
+	q.Enqueue(NewTaskWork(b))
+	waitStatus(t, st, child.ID, models.StatusSucceeded)
+}
+
+func TestDependencyResolver_RecordedSuccessOutlivesParent(t *testing.T) {
+	st := store.NewMemoryStore()
+	ctx := context.Background()
+	q := &recordingEnqueuer{}
+	done, _, _ := st.CreateOrGetByKey(ctx, "", &models.Task{Type: "echo", Status: models.StatusSucceeded})
+	pending := createQueued(t, st, "echo")
+	child := createBlocked(t, st, q, done.ID, pending.ID)
+
+	// the janitor reaps the succeeded parent while its sibling is pending
+	if err := st.Delete(ctx, done.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	r := NewDependencyResolver(st, q, time.Hour)
+	defer r.Stop()
+	if n, err := r.ResolveBlocked(ctx); err != nil || n != 0 {
+		t.Fatalf("expected nothing settled while a parent is pending, got %d, %v", n, err)
+	}
+
+	st.UpdateStatus(ctx, pending.ID, models.StatusRunning, nil)
+	st.UpdateStatus(ctx, pending.ID, models.StatusSucceeded, nil)
+	if n, err := r.ResolveBlocked(ctx); err != nil || n != 1 {
+		t.Fatalf("expected the child released by the safety net, got %d, %v", n, err)
+	}
+	if got, _ := st.Get(ctx, child.ID); got.Status != models.StatusQueued || len(q.work) != 1 {
+		t.Fatalf("expected the child queued, got %s with %d enqueued", got.Status, len(q.work))
+	}
+}
+
+func TestDependencyResolver_FailureCascades(t *testing.T) {
+	st := store.NewMemoryStore()
+	ctx := context.Background()
+	q := &recordingEnqueuer{}
+	parent := createQueued(t, st, "echo")
+	ok, _, _ := st.CreateOrGetByKey(ctx, "", &models.Task{Type: "echo", Status: models.StatusSucceeded})
+	child := createBlocked(t, st, q, ok.ID, parent.ID)
+	grandchild := createBlocked(t, st, q, child.ID)
+
+	st.UpdateStatus(ctx, parent.ID, models.StatusRunning, nil)
+	st.UpdateStatus(ctx, parent.ID, models.StatusDead, nil)
+	r := NewDependencyResolver(st, q, time.Hour)
+	defer r.Stop()
+	r.Settle(parent.ID)
+
+	for _, id := range []string{child.ID, grandchild.ID} {
+		if got, _ := st.Get(ctx, id); got.Status != models.StatusFailed || got.LastError == "" {
+			t.Fatalf("expected %s failed with a reason, got %s %q", id, got.Status, got.LastError)
+		}
+	}
+	if n, err := r.ResolveBlocked(ctx); err != nil || n != 0 {
+		t.Fatalf("expected nothing left for the safety net, got %d, %v", n, err)
+	}
+}
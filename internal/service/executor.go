@@ -0,0 +1,364 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/husainaj20/task-manager-api/internal/models"
+	"github.com/husainaj20/task-manager-api/internal/store"
+)
+
+// TaskWork is the unit of work handed to a Processor. Result is filled in by
+// the handler while processing, not at enqueue time.
+type TaskWork struct {
+	ID       string            `json:"id"`
+	Type     string            `json:"type"`
+	Payload  map[string]any    `json:"payload,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Result   map[string]any    `json:"result,omitempty"`
+	Attempts int               `json:"attempts"`
+	// PriorAttempts counts runs from before the task was replayed; they are
+	// numbered in its history but do not count against the retry budget.
+	PriorAttempts int `json:"priorAttempts,omitempty"`
+	// Timeout overrides the queue's timeout for each run of the task.
+	Timeout time.Duration `json:"timeout,omitempty"`
+	// Priority is the task's priority; brokers hand out higher first.
+	Priority int `json:"priority,omitempty"`
+	// Queue names the queue the work is routed to; empty routes by type.
+	Queue string `json:"queue,omitempty"`
+}
+
+// NewTaskWork builds the work item for a stored task.
+func NewTaskWork(t *models.Task) *TaskWork {
+	return &TaskWork{
+		ID:       t.ID,
+		Type:     t.Type,
+		Payload:  t.Payload,
+		Metadata: t.Metadata,
+		Timeout:  time.Duration(t.TimeoutSeconds) * time.Second,
+		Priority: t.Priority,
+		Queue:    t.Queue,
+
+		PriorAttempts: t.Attempts,
+	}
+}
+
+type Processor func(ctx context.Context, t *TaskWork) error
+
+// ErrTimeout is recorded for a run that exceeded its timeout. It is retried
+// like any other error.
+var ErrTimeout = errors.New("task timed out")
+
+// DLQHandler is called when a task exceeds max attempts
+type DLQHandler func(id string)
+
+// FinishHandler is called once a task has been recorded as succeeded, failed
+// or dead.
+type FinishHandler func(id string)
+
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as non-retryable: the task is failed immediately
+// instead of being retried.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err was marked with Permanent.
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+// executor runs work items and applies the retry policy. It is shared by the
+// queue implementations, which only differ in how work is stored and
+// redelivered.
+type executor struct {
+	// stats
+	inflight  int64
+	processed int64
+	failed    int64
+	dlq       int64
+	// abandoned counts handlers still running after their worker gave up
+	// on them.
+	abandoned int64
+
+	processor Processor
+	store     store.Store
+
+	// config
+	maxAttempts int
+	baseBackoff time.Duration
+	factor      float64
+	maxBackoff  time.Duration
+	jitter      bool
+	dlqHandler  DLQHandler
+	onFinish    FinishHandler
+
+	defaultTimeout time.Duration
+	typeTimeout    func(taskType string) time.Duration
+	grace          time.Duration
+}
+
+func newExecutor() executor {
+	return executor{
+		maxAttempts: 3,
+		baseBackoff: 50 * time.Millisecond,
+		factor:      2.0,
+		maxBackoff:  5 * time.Second,
+		jitter:      true,
+		grace:       10 * time.Second,
+	}
+}
+
+func (e *executor) SetProcessor(p Processor) { e.processor = p }
+
+// SetStore attaches a store in which the queue records each status
+// transition of the tasks it processes.
+func (e *executor) SetStore(s store.Store) { e.store = s }
+
+// ConfigureRetry sets retry/backoff parameters
+func (e *executor) ConfigureRetry(maxAttempts int, base time.Duration, factor float64, max time.Duration, jitter bool) {
+	e.maxAttempts = maxAttempts
+	e.baseBackoff = base
+	e.factor = factor
+	e.maxBackoff = max
+	e.jitter = jitter
+}
+
+// RetryPolicy sets how many times a queue runs work and how long it waits
+// between runs. Zero fields keep the queue's current setting.
+type RetryPolicy struct {
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+}
+
+// ParseRetryPolicy parses "<maxAttempts>[:<backoff>[:<maxBackoff>]]", such as
+// "5" or "5:200ms:1m".
+func ParseRetryPolicy(s string) (RetryPolicy, error) {
+	invalid := fmt.Errorf("invalid retry policy %q: want <maxAttempts>[:<backoff>[:<maxBackoff>]], e.g. 5:200ms:1m", s)
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 {
+		return RetryPolicy{}, invalid
+	}
+	var p RetryPolicy
+	var err error
+	if p.MaxAttempts, err = strconv.Atoi(parts[0]); err != nil || p.MaxAttempts < 1 {
+		return RetryPolicy{}, invalid
+	}
+	for i, d := range []*time.Duration{&p.Backoff, &p.MaxBackoff} {
+		if i+1 >= len(parts) {
+			break
+		}
+		if *d, err = time.ParseDuration(parts[i+1]); err != nil || *d <= 0 {
+			return RetryPolicy{}, invalid
+		}
+	}
+	if p.MaxBackoff > 0 && p.MaxBackoff < p.Backoff {
+		return RetryPolicy{}, invalid
+	}
+	return p, nil
+}
+
+// SetRetryPolicy applies the non-zero fields of p; the backoff factor and
+// jitter are kept.
+func (e *executor) SetRetryPolicy(p RetryPolicy) {
+	if p.MaxAttempts > 0 {
+		e.maxAttempts = p.MaxAttempts
+	}
+	if p.Backoff > 0 {
+		e.baseBackoff = p.Backoff
+	}
+	if p.MaxBackoff > 0 {
+		e.maxBackoff = p.MaxBackoff
+	}
+	if e.maxBackoff < e.baseBackoff {
+		e.maxBackoff = e.baseBackoff
+	}
+}
+
+func (e *executor) SetDLQHandler(h DLQHandler) { e.dlqHandler = h }
+
+// SetFinishHandler sets the handler called when a task finishes; it must be
+// set before the queue takes work.
+func (e *executor) SetFinishHandler(h FinishHandler) { e.onFinish = h }
+
+func (e *executor) finished(id string) {
+	if e.onFinish != nil {
+		e.onFinish(id)
+	}
+}
+
+// SetTimeouts limits each run to the task's own timeout, else the one
+// perType returns for its type, else def. A zero duration means no limit;
+// perType may be nil.
+func (e *executor) SetTimeouts(def time.Duration, perType func(taskType string) time.Duration) {
+	e.defaultTimeout = def
+	e.typeTimeout = perType
+}
+
+func (e *executor) timeoutFor(w *TaskWork) time.Duration {
+	if w.Timeout > 0 {
+		return w.Timeout
+	}
+	if e.typeTimeout != nil {
+		if d := e.typeTimeout(w.Type); d > 0 {
+			return d
+		}
+	}
+	return e.defaultTimeout
+}
+
+// SetGracePeriod sets how long a handler may run on after its context ends.
+// A handler still running after that is abandoned: the run is recorded as
+// ErrTimeout and the worker moves on while the handler's goroutine lingers.
+func (e *executor) SetGracePeriod(d time.Duration) { e.grace = d }
+
+// run calls the processor under the task's timeout. When the timeout passes
+// or the task is cancelled, the processor's context is cancelled and run
+// waits up to the grace period for it to return, so a retry does not overlap
+// a handler that honours ctx. A handler that outlives the grace period is
+// abandoned and counted in the queue's stats; its retry may then overlap it.
+func (e *executor) run(ctx context.Context, w *TaskWork) error {
+	timeout := e.timeoutFor(w)
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	work := *w
+	done := make(chan error, 1)
+	go func() { done <- e.processor(ctx, &work) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		grace := time.NewTimer(e.grace)
+		defer grace.Stop()
+		select {
+		case err = <-done:
+		case <-grace.C:
+			e.abandon(w.ID, ctx.Err(), done)
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return fmt.Errorf("%w after %s; handler abandoned", ErrTimeout, timeout)
+			}
+			return ctx.Err()
+		}
+	}
+	w.Result = work.Result
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w after %s", ErrTimeout, timeout)
+	}
+	return err
+}
+
+// abandon counts the handler of task id as abandoned until it returns.
+func (e *executor) abandon(id string, cause error, done <-chan error) {
+	atomic.AddInt64(&e.abandoned, 1)
+	log.Printf("queue: abandoning task %s: handler still running %s after %v; handlers must return once ctx is done",
+		id, e.grace, cause)
+	go func() {
+		<-done
+		atomic.AddInt64(&e.abandoned, -1)
+	}()
+}
+
+// execute processes w once and records the outcome. It reports whether w
+// should be redelivered, and after what delay.
+func (e *executor) execute(ctx context.Context, workerID string, w *TaskWork) (time.Duration, bool) {
+	if e.processor == nil {
+		return 0, false
+	}
+	atomic.AddInt64(&e.inflight, 1)
+	defer atomic.AddInt64(&e.inflight, -1)
+
+	if err := e.record(ctx, w.ID, models.StatusRunning, nil); err != nil {
+		// e.g. the task was cancelled while it sat in the queue
+		log.Printf("queue: skipping task %s: %v", w.ID, err)
+		return 0, false
+	}
+	attempt := models.Attempt{Number: w.PriorAttempts + w.Attempts + 1, WorkerID: workerID, StartedAt: time.Now().UTC()}
+	err := e.run(ctx, w)
+	attempt.FinishedAt = time.Now().UTC()
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+	if e.store != nil {
+		if rerr := e.store.RecordAttempt(ctx, w.ID, attempt); rerr != nil {
+			log.Printf("queue: recording attempt of task %s: %v", w.ID, rerr)
+		}
+	}
+	if err != nil && errors.Is(ctx.Err(), context.Canceled) {
+		// the task was cancelled while running; its status is already final
+		log.Printf("queue: task %s cancelled while running", w.ID)
+		return 0, false
+	}
+	if err != nil {
+		// handle retry
+		d, retry := e.handleRetry(ctx, w, err)
+		atomic.AddInt64(&e.failed, 1)
+		return d, retry
+	}
+	if err := e.record(ctx, w.ID, models.StatusSucceeded, w.Result); err != nil {
+		log.Printf("queue: recording success of task %s: %v", w.ID, err)
+	} else {
+		e.finished(w.ID)
+	}
+	atomic.AddInt64(&e.processed, 1)
+	return 0, false
+}
+
+// record persists a status transition when a store is attached.
+func (e *executor) record(ctx context.Context, id string, status models.Status, result map[string]any) error {
+	if e.store == nil {
+		return nil
+	}
+	return e.store.UpdateStatus(ctx, id, status, result)
+}
+
+func (e *executor) recordOrLog(ctx context.Context, id string, status models.Status) {
+	if err := e.record(ctx, id, status, nil); err != nil {
+		log.Printf("queue: recording %s for task %s: %v", status, id, err)
+	}
+}
+
+func (e *executor) handleRetry(ctx context.Context, t *TaskWork, err error) (time.Duration, bool) {
+	t.Attempts++
+	if IsPermanent(err) {
+		e.recordOrLog(ctx, t.ID, models.StatusFailed)
+		e.finished(t.ID)
+		return 0, false
+	}
+	if t.Attempts >= e.maxAttempts {
+		atomic.AddInt64(&e.dlq, 1)
+		e.recordOrLog(ctx, t.ID, models.StatusDead)
+		if e.dlqHandler != nil {
+			// call synchronously so caller can observe DLQ handling completion
+			e.dlqHandler(t.ID)
+		}
+		e.finished(t.ID)
+		return 0, false
+	}
+	e.recordOrLog(ctx, t.ID, models.StatusRetrying)
+	// compute backoff
+	backoff := float64(e.baseBackoff) * math.Pow(e.factor, float64(t.Attempts-1))
+	if backoff > float64(e.maxBackoff) {
+		backoff = float64(e.maxBackoff)
+	}
+	return time.Duration(backoff), true
+}
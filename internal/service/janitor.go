@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/husainaj20/task-manager-api/internal/models"
+	"github.com/husainaj20/task-manager-api/internal/store"
+)
+
+// Retention is how long finished tasks are kept after their last update, per
+// status. Statuses without an entry, or with a zero duration, are kept
+// forever; unfinished statuses are ignored.
+type Retention map[models.Status]time.Duration
+
+// Janitor deletes finished tasks once their retention has passed. Deleting a
+// task also releases its idempotency key.
+type Janitor struct {
+	store     store.Store
+	retention Retention
+	batch     int
+
+	mu      sync.Mutex
+	evicted map[models.Status]int64
+
+	wg       sync.WaitGroup
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewJanitor(st store.Store, retention Retention, interval time.Duration) *Janitor {
+	j := &Janitor{
+		store:     st,
+		retention: retention,
+		batch:     500,
+		evicted:   make(map[models.Status]int64),
+		stop:      make(chan struct{}),
+	}
+	j.wg.Add(1)
+	go func() {
+		defer j.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-j.stop:
+				return
+			case <-ticker.C:
+				if _, err := j.Sweep(context.Background(), time.Now().UTC()); err != nil {
+					log.Printf("janitor: %v", err)
+				}
+			}
+		}
+	}()
+	return j
+}
+
+// Sweep deletes every task whose retention ended before now and returns how
+// many were deleted.
+func (j *Janitor) Sweep(ctx context.Context, now time.Time) (int, error) {
+	total := 0
+	for status, keep := range j.retention {
+		if keep <= 0 || !status.Finished() {
+			continue
+		}
+		for {
+			n, err := j.store.Expire(ctx, status, now.Add(-keep), j.batch)
+			total += n
+			j.mu.Lock()
+			j.evicted[status] += int64(n)
+			j.mu.Unlock()
+			if err != nil {
+				return total, err
+			}
+			if n < j.batch {
+				break
+			}
+		}
+	}
+	return total, nil
+}
+
+// Evicted returns how many tasks of each status this janitor has deleted.
+func (j *Janitor) Evicted() map[models.Status]int64 {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	out := make(map[models.Status]int64, len(j.evicted))
+	for s, n := range j.evicted {
+		out[s] = n
+	}
+	return out
+}
+
+func (j *Janitor) Stop() {
+	j.stopOnce.Do(func() {
+		close(j.stop)
+		j.wg.Wait()
+	})
+}
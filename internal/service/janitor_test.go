@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/husainaj20/task-manager-api/internal/models"
+	"github.com/husainaj20/task-manager-api/internal/store"
+)
+
+func TestJanitor_SweepsExpiredTasks(t *testing.T) {
+	st := store.NewMemoryStore()
+	ctx := context.Background()
+	for _, status := range []models.Status{models.StatusSucceeded, models.StatusSucceeded, models.StatusFailed, models.StatusRunning} {
+		task := createQueued(t, st, "echo")
+		st.UpdateStatus(ctx, task.ID, models.StatusRunning, nil)
+		if status != models.StatusRunning {
+			st.UpdateStatus(ctx, task.ID, status, nil)
+		}
+	}
+
+	j := &Janitor{
+		store: st,
+		retention: Retention{
+			models.StatusSucceeded: time.Minute,
+			models.StatusFailed:    0,
+			models.StatusRunning:   time.Minute, // unfinished: ignored
+		},
+		batch:   1,
+		evicted: make(map[models.Status]int64),
+	}
+	if n, _ := j.Sweep(ctx, time.Now()); n != 0 {
+		t.Fatalf("expected nothing swept within retention, got %d", n)
+	}
+	n, err := j.Sweep(ctx, time.Now().Add(2*time.Minute))
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 swept, got %d (%v)", n, err)
+	}
+	if ev := j.Evicted(); ev[models.StatusSucceeded] != 2 || len(ev) != 1 {
+		t.Fatalf("unexpected eviction counts %v", ev)
+	}
+	page, _ := st.ListTasks(ctx, store.ListOptions{})
+	if len(page.Tasks) != 2 {
+		t.Fatalf("expected failed and running tasks kept, got %d", len(page.Tasks))
+	}
+}
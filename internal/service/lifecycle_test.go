@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/husainaj20/task-manager-api/internal/models"
+	"github.com/husainaj20/task-manager-api/internal/store"
+)
+
+func createQueued(t *testing.T, st store.Store, taskType string) *models.Task {
+	t.Helper()
+	task, _, err := st.CreateOrGetByKey(context.Background(), "", &models.Task{Type: taskType, Status: models.StatusQueued})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	return task
+}
+
+func waitStatus(t *testing.T, st store.Store, id string, want models.Status) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	var got *models.Task
+	for time.Now().Before(deadline) {
+		got, _ = st.Get(context.Background(), id)
+		if got != nil && got.Status == want {
+			return
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+	t.Fatalf("task %s: expected status %s, got %+v", id, want, got)
+}
+
+func TestLifecycle_RecordsSuccess(t *testing.T) {
+	st := store.NewMemoryStore()
+	q := NewQueue(1)
+	defer q.Stop()
+	q.SetStore(st)
+	q.SetProcessor(func(ctx context.Context, tw *TaskWork) error {
+		tw.Result = map[string]any{"ok": true}
+		return nil
+	})
+
+	task := createQueued(t, st, "echo")
+	q.Enqueue(NewTaskWork(task))
+	waitStatus(t, st, task.ID, models.StatusSucceeded)
+
+	got, _ := st.Get(context.Background(), task.ID)
+	if got.Result["ok"] != true {
+		t.Fatalf("expected result recorded, got %v", got.Result)
+	}
+}
+
+func TestLifecycle_RetriesThenDead(t *testing.T) {
+	st := store.NewMemoryStore()
+	q := NewQueue(1)
+	q.ConfigureRetry(2, 5*time.Millisecond, 2.0, 50*time.Millisecond, false)
+	defer q.Stop()
+	q.SetStore(st)
+	q.SetProcessor(func(ctx context.Context, tw *TaskWork) error {
+		return errors.New("boom")
+	})
+
+	task := createQueued(t, st, "echo")
+	q.Enqueue(NewTaskWork(task))
+	waitStatus(t, st, task.ID, models.StatusDead)
+
+	got, _ := st.Get(context.Background(), task.ID)
+	if got.Attempts != 2 || len(got.History) != 2 {
+		t.Fatalf("expected 2 recorded attempts, got %d (%d in history)", got.Attempts, len(got.History))
+	}
+	if got.LastError != "boom" {
+		t.Fatalf("expected last error boom, got %q", got.LastError)
+	}
+	for i, a := range got.History {
+		if a.Number != i+1 || a.WorkerID == "" || a.Error != "boom" {
+			t.Fatalf("unexpected attempt %d: %+v", i, a)
+		}
+		if a.FinishedAt.Before(a.StartedAt) {
+			t.Fatalf("attempt %d finished before it started: %+v", i, a)
+		}
+	}
+}
+
+func TestLifecycle_PermanentErrorFailsWithoutRetry(t *testing.T) {
+	st := store.NewMemoryStore()
+	q := NewQueue(1)
+	q.ConfigureRetry(5, 5*time.Millisecond, 2.0, 50*time.Millisecond, false)
+	defer q.Stop()
+	q.SetStore(st)
+	q.SetProcessor(NewRegistry().Process)
+
+	task := createQueued(t, st, "unregistered")
+	q.Enqueue(NewTaskWork(task))
+	waitStatus(t, st, task.ID, models.StatusFailed)
+	if !q.WaitIdle(time.Second) {
+		t.Fatalf("queue did not become idle")
+	}
+}
+
+func TestLifecycle_SkipsCancelledWork(t *testing.T) {
+	st := store.NewMemoryStore()
+	q := NewQueue(1)
+	defer q.Stop()
+	q.SetStore(st)
+	ran := make(chan struct{}, 1)
+	q.SetProcessor(func(ctx context.Context, tw *TaskWork) error {
+		ran <- struct{}{}
+		return nil
+	})
+
+	task := createQueued(t, st, "echo")
+	if err := st.UpdateStatus(context.Background(), task.ID, models.StatusCancelled, nil); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	q.Enqueue(NewTaskWork(task))
+	if !q.WaitIdle(time.Second) {
+		t.Fatalf("queue did not become idle")
+	}
+	select {
+	case <-ran:
+		t.Fatalf("cancelled task should not be processed")
+	default:
+	}
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// memoryCapacity bounds the pending work a MemoryBroker holds; Enqueue waits
+// for room beyond it.
+const memoryCapacity = 1024
+
+// MemoryBroker is an in-process Broker. Work is lost when the process exits.
+// After Close, work already pending is still handed out so workers can drain
+// it; pending retries are dropped.
+type MemoryBroker struct {
+	// avail holds a token while there may be pending work; consumers that
+	// take work pass it on to the next one.
+	avail chan struct{}
+	done  chan struct{}
+
+	mu       sync.Mutex
+	closed   bool
+	pending  pendingQueue
+	timers   map[string]*time.Timer
+	delayed  map[string]*Delivery
+	inflight map[string]*Delivery
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{
+		avail:    make(chan struct{}, 1),
+		done:     make(chan struct{}),
+		timers:   make(map[string]*time.Timer),
+		delayed:  make(map[string]*Delivery),
+		inflight: make(map[string]*Delivery),
+	}
+}
+
+func (b *MemoryBroker) Enqueue(ctx context.Context, w *TaskWork) error {
+	return b.push(ctx, &Delivery{ID: uuid.NewString(), Work: w})
+}
+
+// signal wakes a consumer; the caller holds b.mu.
+func (b *MemoryBroker) signal() {
+	select {
+	case b.avail <- struct{}{}:
+	default:
+	}
+}
+
+// tryPush adds d if there is room; the caller holds b.mu.
+func (b *MemoryBroker) tryPush(d *Delivery) (bool, error) {
+	if b.closed {
+		return false, ErrBrokerClosed
+	}
+	if b.pending.len() >= memoryCapacity {
+		return false, nil
+	}
+	b.pending.push(d)
+	b.signal()
+	return true, nil
+}
+
+func (b *MemoryBroker) push(ctx context.Context, d *Delivery) error {
+	for {
+		b.mu.Lock()
+		ok, err := b.tryPush(d)
+		b.mu.Unlock()
+		if ok || err != nil {
+			return err
+		}
+		// full: wait for room without holding the lock
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+// Consume hands out pending work by priority, as described at oldestEvery.
+func (b *MemoryBroker) Consume(ctx context.Context) (*Delivery, error) {
+	for {
+		b.mu.Lock()
+		if d := b.pending.pop(); d != nil {
+			b.inflight[d.ID] = d
+			if b.pending.len() > 0 {
+				b.signal()
+			}
+			b.mu.Unlock()
+			return d, nil
+		}
+		closed := b.closed
+		b.mu.Unlock()
+		if closed {
+			return nil, ErrBrokerClosed
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-b.avail:
+		case <-b.done:
+		}
+	}
+}
+
+func (b *MemoryBroker) Ack(ctx context.Context, d *Delivery) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.inflight, d.ID)
+	return nil
+}
+
+func (b *MemoryBroker) Nack(ctx context.Context, d *Delivery, delay time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.inflight, d.ID)
+	if b.closed {
+		return ErrBrokerClosed
+	}
+	b.delayed[d.ID] = d
+	b.timers[d.ID] = time.AfterFunc(delay, func() {
+		b.mu.Lock()
+		if _, ok := b.timers[d.ID]; !ok {
+			// stopped by Close or Cancel
+			b.mu.Unlock()
+			return
+		}
+		delete(b.timers, d.ID)
+		delete(b.delayed, d.ID)
+		b.mu.Unlock()
+		b.push(context.Background(), d)
+	})
+	return nil
+}
+
+// Cancel stops pending retries of the task and filters it out of the pending
+// work.
+func (b *MemoryBroker) Cancel(ctx context.Context, id string) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	removed := 0
+	for did, d := range b.delayed {
+		if d.Work.ID == id {
+			b.timers[did].Stop()
+			delete(b.timers, did)
+			delete(b.delayed, did)
+			removed++
+		}
+	}
+	removed += b.pending.remove(func(d *Delivery) bool { return d.Work.ID == id })
+	return removed, nil
+}
+
+func (b *MemoryBroker) Stats(ctx context.Context) (BrokerStats, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return BrokerStats{
+		Pending:    int64(b.pending.len()),
+		Delayed:    int64(len(b.timers)),
+		Inflight:   int64(len(b.inflight)),
+		ByPriority: b.pending.byPriority(),
+	}, nil
+}
+
+// Close stops accepting work and drops pending retries. Nothing outside the
+// process holds deliveries, so there is nothing to wait for.
+func (b *MemoryBroker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	// stop all retry timers
+	for id, t := range b.timers {
+		t.Stop()
+		delete(b.timers, id)
+		delete(b.delayed, id)
+	}
+	// wake consumers; they drain the pending work, then stop
+	close(b.done)
+	return nil
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/husainaj20/task-manager-api/internal/store"
+)
+
+// PauseWatcher keeps the pause state of a TaskQueue in line with the pauses
+// recorded in the store, so a pause made through any replica reaches all of
+// them and survives restarts.
+type PauseWatcher struct {
+	store store.PauseStore
+	q     TaskQueue
+
+	wg       sync.WaitGroup
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewPauseWatcher applies the stored pauses to q before it returns, then again
+// every interval. Create it before setting processors, so work that is paused
+// is not taken at startup.
+func NewPauseWatcher(st store.PauseStore, q TaskQueue, interval time.Duration) *PauseWatcher {
+	w := &PauseWatcher{store: st, q: q, stop: make(chan struct{})}
+	if err := w.Sync(context.Background()); err != nil {
+		log.Printf("pauses: %v", err)
+	}
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+				if err := w.Sync(context.Background()); err != nil {
+					log.Printf("pauses: %v", err)
+				}
+			}
+		}
+	}()
+	return w
+}
+
+// Sync applies the stored pauses to the queue.
+func (w *PauseWatcher) Sync(ctx context.Context) error {
+	pauses, err := w.store.ListPauses(ctx)
+	if err != nil {
+		return err
+	}
+	w.q.ApplyPauses(pauses)
+	return nil
+}
+
+func (w *PauseWatcher) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+		w.wg.Wait()
+	})
+}
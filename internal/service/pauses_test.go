@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/husainaj20/task-manager-api/internal/models"
+	"github.com/husainaj20/task-manager-api/internal/store"
+)
+
+func TestPauseWatcher_AppliesStoredPauses(t *testing.T) {
+	st := store.NewMemoryStore()
+	ctx := context.Background()
+	st.AddPause(ctx, models.Pause{Scope: models.PauseQueue, Name: DefaultQueue, PausedAt: time.Now()})
+
+	set := NewQueueSet()
+	defer set.Stop()
+	var queues []*Queue
+	for _, name := range []string{DefaultQueue, "other"} {
+		q := NewQueue(1)
+		q.SetStore(st)
+		set.Add(name, q)
+		queues = append(queues, q)
+	}
+	w := NewPauseWatcher(st, set, 5*time.Millisecond)
+	defer w.Stop()
+	if !queues[0].Paused() || queues[1].Paused() {
+		t.Fatal("expected the stored pause applied before returning")
+	}
+	for _, q := range queues {
+		q.SetProcessor(func(ctx context.Context, t *TaskWork) error { return nil })
+	}
+
+	held, _, _ := st.CreateOrGetByKey(ctx, "", &models.Task{Type: "echo", Status: models.StatusQueued})
+	other, _, _ := st.CreateOrGetByKey(ctx, "", &models.Task{Type: "echo", Status: models.StatusQueued, Queue: "other"})
+	set.Enqueue(NewTaskWork(held))
+	set.Enqueue(NewTaskWork(other))
+	waitStatus(t, st, other.ID, models.StatusSucceeded)
+	if got, _ := st.Get(ctx, held.ID); got.Status != models.StatusQueued {
+		t.Fatalf("expected work on a paused queue to wait, got %s", got.Status)
+	}
+
+	st.RemovePause(ctx, models.PauseQueue, DefaultQueue)
+	waitStatus(t, st, held.ID, models.StatusSucceeded)
+}
+
+func TestQueue_PausedTypeWaits(t *testing.T) {
+	forEachBroker(t, func(t *testing.T, newBroker func() Broker) {
+		var mu sync.Mutex
+		var ran []string
+		q := NewQueueWithBroker(newBroker(), 1)
+		q.pausedDelay = 5 * time.Millisecond
+		q.ApplyPauses([]models.Pause{{Scope: models.PauseTaskType, Name: "held"}})
+		q.SetProcessor(func(ctx context.Context, t *TaskWork) error {
+			mu.Lock()
+			ran = append(ran, t.Type)
+			mu.Unlock()
+			return nil
+		})
+		defer q.Stop()
+
+		q.Enqueue(&TaskWork{ID: "1", Type: "held"})
+		q.Enqueue(&TaskWork{ID: "2", Type: "free"})
+		time.Sleep(50 * time.Millisecond)
+		mu.Lock()
+		if len(ran) != 1 || ran[0] != "free" {
+			t.Fatalf("expected only the unpaused type to run, got %v", ran)
+		}
+		mu.Unlock()
+		// the held work is delayed, pending or briefly taken, but never lost
+		if s, _ := q.broker.Stats(context.Background()); s.Pending+s.Delayed+s.Inflight != 1 || q.Paused() {
+			t.Fatalf("expected the paused type kept on a running queue, got %+v", s)
+		}
+
+		q.ApplyPauses(nil)
+		if !q.WaitIdle(time.Second) {
+			t.Fatal("expected the resumed type to run")
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if len(ran) != 2 || ran[1] != "held" {
+			t.Fatalf("expected the held work to run after resume, got %v", ran)
+		}
+	})
+}
@@ -0,0 +1,104 @@
+package service
+
+import "github.com/husainaj20/task-manager-api/internal/models"
+
+// oldestEvery is the anti-starvation rule shared by all brokers: brokers hand
+// out the highest-priority pending work, except that every oldestEvery-th
+// delivery goes to the work that has been pending longest, whatever its
+// priority. However much urgent work arrives, each delivery is then handed
+// out after at most oldestEvery times the number of deliveries pending
+// before it.
+const oldestEvery = 5
+
+// oldestTurn reports whether the n-th delivery (counting from 1) of a broker
+// goes to the longest-pending work.
+func oldestTurn(n uint64) bool { return n%oldestEvery == 0 }
+
+// priorityOf returns the priority of w within the supported range.
+func priorityOf(w *TaskWork) int {
+	switch {
+	case w.Priority < models.MinPriority:
+		return models.MinPriority
+	case w.Priority > models.MaxPriority:
+		return models.MaxPriority
+	}
+	return w.Priority
+}
+
+// pendingQueue holds pending deliveries in one FIFO per priority. It is not
+// safe for concurrent use.
+type pendingQueue struct {
+	levels [models.MaxPriority + 1][]pendingItem
+	seq    uint64 // arrival counter
+	taken  uint64 // deliveries handed out
+	n      int
+}
+
+type pendingItem struct {
+	seq uint64
+	d   *Delivery
+}
+
+func (p *pendingQueue) len() int { return p.n }
+
+func (p *pendingQueue) push(d *Delivery) {
+	p.seq++
+	level := priorityOf(d.Work)
+	p.levels[level] = append(p.levels[level], pendingItem{seq: p.seq, d: d})
+	p.n++
+}
+
+// pop takes the next delivery according to oldestEvery, or nil when empty.
+func (p *pendingQueue) pop() *Delivery {
+	if p.n == 0 {
+		return nil
+	}
+	p.taken++
+	pick := -1
+	for level := models.MaxPriority; level >= models.MinPriority; level-- {
+		if len(p.levels[level]) == 0 {
+			continue
+		}
+		if !oldestTurn(p.taken) {
+			pick = level
+			break
+		}
+		if pick < 0 || p.levels[level][0].seq < p.levels[pick][0].seq {
+			pick = level
+		}
+	}
+	d := p.levels[pick][0].d
+	p.levels[pick][0] = pendingItem{}
+	p.levels[pick] = p.levels[pick][1:]
+	p.n--
+	return d
+}
+
+// remove drops the deliveries drop matches and reports how many it dropped.
+func (p *pendingQueue) remove(drop func(d *Delivery) bool) int {
+	removed := 0
+	for level, items := range p.levels {
+		kept := items[:0]
+		for _, it := range items {
+			if drop(it.d) {
+				removed++
+			} else {
+				kept = append(kept, it)
+			}
+		}
+		p.levels[level] = kept
+	}
+	p.n -= removed
+	return removed
+}
+
+// byPriority counts pending deliveries per priority, leaving out empty ones.
+func (p *pendingQueue) byPriority() map[int]int64 {
+	out := map[int]int64{}
+	for level, items := range p.levels {
+		if len(items) > 0 {
+			out[level] = int64(len(items))
+		}
+	}
+	return out
+}
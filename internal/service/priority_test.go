@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestBroker_DispatchesByPriority(t *testing.T) {
+	forEachBroker(t, func(t *testing.T, newBroker func() Broker) {
+		b := newBroker()
+		defer b.Close()
+		ctx := context.Background()
+		for i := 0; i < 8; i++ {
+			b.Enqueue(ctx, &TaskWork{ID: fmt.Sprintf("low-%d", i)})
+		}
+		for i := 0; i < 8; i++ {
+			b.Enqueue(ctx, &TaskWork{ID: fmt.Sprintf("high-%d", i), Priority: 9})
+		}
+		s, err := b.Stats(ctx)
+		if err != nil || s.Pending != 16 || s.ByPriority[0] != 8 || s.ByPriority[9] != 8 || len(s.ByPriority) != 2 {
+			t.Fatalf("unexpected stats %+v (%v)", s, err)
+		}
+
+		var order []string
+		for i := 0; i < 16; i++ {
+			d, err := b.Consume(ctx)
+			if err != nil {
+				t.Fatalf("consume: %v", err)
+			}
+			order = append(order, d.Work.ID)
+			b.Ack(ctx, d)
+		}
+		// every fifth delivery goes to the longest-waiting work
+		want := "high-0 high-1 high-2 high-3 low-0 high-4 high-5 high-6 high-7 low-1 low-2 low-3 low-4 low-5 low-6 low-7"
+		if got := strings.Join(order, " "); got != want {
+			t.Fatalf("unexpected order\n got %s\nwant %s", got, want)
+		}
+	})
+}
+
+func TestPendingQueue_Remove(t *testing.T) {
+	var p pendingQueue
+	for i := 0; i < 6; i++ {
+		p.push(&Delivery{ID: fmt.Sprint(i), Work: &TaskWork{ID: fmt.Sprint(i % 2), Priority: i % 3}})
+	}
+	if n := p.remove(func(d *Delivery) bool { return d.Work.ID == "1" }); n != 3 || p.len() != 3 {
+		t.Fatalf("expected 3 removed and 3 left, got %d and %d", n, p.len())
+	}
+	for d := p.pop(); d != nil; d = p.pop() {
+		if d.Work.ID != "0" {
+			t.Fatalf("removed delivery %s handed out", d.ID)
+		}
+	}
+}
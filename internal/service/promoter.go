@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/husainaj20/task-manager-api/internal/models"
+	"github.com/husainaj20/task-manager-api/internal/store"
+)
+
+// EnqueueRetryDelay is how long a task whose work could not be enqueued
+// waits before a Promoter enqueues it again.
+var EnqueueRetryDelay = time.Second
+
+// EnqueueTask enqueues the work of t, which the store already holds as
+// queued. If that fails, t is scheduled to run after EnqueueRetryDelay, so a
+// Promoter retries instead of the task staying queued with no work behind
+// it; EnqueueTask then returns the rescheduled task. It only fails when t
+// could not be rescheduled either.
+func EnqueueTask(ctx context.Context, st store.Store, q Enqueuer, t *models.Task) (*models.Task, error) {
+	err := q.Enqueue(NewTaskWork(t))
+	if err == nil {
+		return t, nil
+	}
+	log.Printf("queue: enqueue task %s: %v; retrying in %s", t.ID, err, EnqueueRetryDelay)
+	at := time.Now().UTC().Add(EnqueueRetryDelay)
+	deferred, uerr := st.Update(ctx, t.ID, 0, func(t *models.Task) error {
+		t.RunAt = &at
+		return t.Transition(models.StatusScheduled)
+	})
+	if errors.Is(uerr, models.ErrInvalidTransition) {
+		// the work got through after all, or the task was cancelled
+		return st.Get(ctx, t.ID)
+	}
+	if uerr != nil {
+		return t, fmt.Errorf("%w; rescheduling: %v", err, uerr)
+	}
+	return deferred, nil
+}
+
+// Promoter polls the store for scheduled tasks whose run time has come and
+// enqueues them. Schedules live in the store, not in timers, so they survive
+// restarts and any number of replicas may run a Promoter.
+type Promoter struct {
+	store    store.Store
+	q        Enqueuer
+	interval time.Duration
+	batch    int
+
+	wg       sync.WaitGroup
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewPromoter(st store.Store, q Enqueuer, interval time.Duration) *Promoter {
+	p := &Promoter{
+		store:    st,
+		q:        q,
+		interval: interval,
+		batch:    100,
+		stop:     make(chan struct{}),
+	}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+				if _, err := p.PromoteDue(context.Background()); err != nil {
+					log.Printf("promoter: %v", err)
+				}
+			}
+		}
+	}()
+	return p
+}
+
+// PromoteDue claims every due scheduled task and enqueues it, returning how
+// many were promoted.
+func (p *Promoter) PromoteDue(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		tasks, err := p.store.ClaimDue(ctx, time.Now().UTC(), p.batch)
+		for _, t := range tasks {
+			if t, err := EnqueueTask(ctx, p.store, p.q, t); err != nil || t.Status != models.StatusQueued {
+				continue
+			}
+			total++
+		}
+		if err != nil || len(tasks) < p.batch {
+			return total, err
+		}
+	}
+}
+
+func (p *Promoter) Stop() {
+	p.stopOnce.Do(func() {
+		close(p.stop)
+		p.wg.Wait()
+	})
+}
@@ -0,0 +1,34 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/husainaj20/task-manager-api/internal/models"
+	"github.com/husainaj20/task-manager-api/internal/store"
+)
+
+func TestPromoter_EnqueuesDueTasks(t *testing.T) {
+	st := store.NewMemoryStore()
+	q := NewQueue(1)
+	defer q.Stop()
+	q.SetStore(st)
+	q.SetProcessor(func(ctx context.Context, tw *TaskWork) error { return nil })
+
+	runAt := time.Now().Add(30 * time.Millisecond)
+	task, _, _ := st.CreateOrGetByKey(context.Background(), "", &models.Task{Type: "echo", Status: models.StatusScheduled, RunAt: &runAt})
+
+	p := NewPromoter(st, q, 5*time.Millisecond)
+	defer p.Stop()
+
+	got, _ := st.Get(context.Background(), task.ID)
+	if got.Status != models.StatusScheduled {
+		t.Fatalf("expected task to wait until due, got %s", got.Status)
+	}
+	waitStatus(t, st, task.ID, models.StatusSucceeded)
+	got, _ = st.Get(context.Background(), task.ID)
+	if got.History[0].StartedAt.Before(runAt) {
+		t.Fatalf("task ran at %v, before its run time %v", got.History[0].StartedAt, runAt)
+	}
+}
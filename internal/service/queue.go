@@ -2,160 +2,353 @@ package service
 
 import (
 	"context"
-	"math"
+	"errors"
+	"fmt"
+	"log"
+	"os"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/husainaj20/task-manager-api/internal/models"
 )
 
-type TaskWork struct {
-	ID       string
-	Result   map[string]any
-	Attempts int
-}
+// ErrQueueStopped is returned when enqueueing on a stopped queue.
+var ErrQueueStopped = errors.New("queue stopped")
 
-type Processor func(ctx context.Context, t *TaskWork) error
+// Enqueuer accepts work for asynchronous processing.
+type Enqueuer interface {
+	Enqueue(t *TaskWork) error
+}
 
-// DLQHandler is called when a task exceeds max attempts
-type DLQHandler func(id string)
+// TaskQueue is an Enqueuer that can also stop work it has accepted and
+// report its counters. A Queue is a TaskQueue with only DefaultQueue; a
+// QueueSet one with several named queues.
+type TaskQueue interface {
+	Enqueuer
+	Cancel(ctx context.Context, id string) error
+	Stats() (queued, inflight, processed, failed, dlq int64)
+	PendingByPriority() map[int]int64
+	HasQueue(name string) bool
+	QueueStats() []QueueStats
+	// ApplyPauses pauses exactly the queues and task types pauses name.
+	ApplyPauses(pauses []models.Pause)
+}
 
+// Queue runs a pool of workers that consume work from a Broker, process it
+// and settle each delivery according to the retry policy.
 type Queue struct {
-	wg        sync.WaitGroup
-	work      chan *TaskWork
-	stopOnce  sync.Once
-	cancel    context.CancelFunc
-	processor Processor
-
-	// retry/scheduling
-	retryMu sync.Mutex
-	timers  map[string]*time.Timer
-
-	// config
-	maxAttempts int
-	baseBackoff time.Duration
-	factor      float64
-	maxBackoff  time.Duration
-	jitter      bool
-	dlqHandler  DLQHandler
-
-	// stats
-	// stats
-	inflight  int64
-	processed int64
-	failed    int64
-	dlq       int64
+	executor
+
+	broker   Broker
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+	cancel   context.CancelFunc
+
+	// running holds the context cancel func of each delivery being processed;
+	// name is the queue's name in a QueueSet
+	mu      sync.Mutex
+	running map[*Delivery]context.CancelFunc
+	name    string
+
+	// ready is closed by SetProcessor; workers only start consuming then so
+	// durable brokers never hand out work before there is a processor.
+	ready     chan struct{}
+	readyOnce sync.Once
+	done      chan struct{}
+
+	workers int
+
+	// consumeCtx is cancelled by Pause, which sets it to nil and makes
+	// resumed, closed by Resume.
+	pauseMu       sync.Mutex
+	base          context.Context
+	consumeCtx    context.Context
+	stopConsuming context.CancelFunc
+	resumed       chan struct{}
+	// work of a paused type is put back for pausedDelay instead of run
+	pausedTypes map[string]bool
+	pausedDelay time.Duration
+
+	// work over a rate limit is put back until a token is available
+	limiter   Limiter
+	queueRate Rate
+	typeRate  func(taskType string) Rate
+	throttled int64
 }
 
+// NewQueue starts workers on an in-memory broker.
 func NewQueue(workers int) *Queue {
+	return NewQueueWithBroker(NewMemoryBroker(), workers)
+}
+
+// NewQueueWithBroker starts workers on b. Workers begin consuming once a
+// processor is set; configure the store and retry policy before that.
+func NewQueueWithBroker(b Broker, workers int) *Queue {
 	q := &Queue{
-		work:        make(chan *TaskWork, 1024),
-		timers:      make(map[string]*time.Timer),
-		maxAttempts: 3,
-		baseBackoff: 50 * time.Millisecond,
-		factor:      2.0,
-		maxBackoff:  5 * time.Second,
-		jitter:      true,
+		executor: newExecutor(),
+		broker:   b,
+		running:  make(map[*Delivery]context.CancelFunc),
+		name:     DefaultQueue,
+		ready:    make(chan struct{}),
+		done:     make(chan struct{}),
+		workers:  workers,
+
+		pausedDelay: time.Second,
+		limiter:     NewMemoryLimiter(),
 	}
 	ctx, cancel := context.WithCancel(context.Background())
 	q.cancel = cancel
+	q.base = ctx
+	q.consumeCtx, q.stopConsuming = context.WithCancel(ctx)
 
+	host, _ := os.Hostname()
 	for i := 0; i < workers; i++ {
 		q.wg.Add(1)
 		go func(i int) {
 			defer q.wg.Done()
-			for {
+			workerID := fmt.Sprintf("%s-%d", host, i)
+			select {
+			case <-q.ready:
+			case <-q.done:
+				// stopped before any processor was set
 				select {
-				case <-ctx.Done():
+				case <-q.ready:
+				default:
 					return
-				case w, ok := <-q.work:
-					if !ok {
-						return
-					}
-					if w == nil {
-						continue
-					}
-					atomic.AddInt64(&q.inflight, 1)
-					if q.processor != nil {
-						if err := q.processor(ctx, w); err != nil {
-							// handle retry
-							q.handleRetry(ctx, w)
-							atomic.AddInt64(&q.failed, 1)
-						} else {
-							atomic.AddInt64(&q.processed, 1)
-						}
-					}
-					atomic.AddInt64(&q.inflight, -1)
 				}
 			}
+			for {
+				consumeCtx := q.waitResumed()
+				if consumeCtx == nil {
+					return
+				}
+				d, err := q.broker.Consume(consumeCtx)
+				if errors.Is(err, ErrBrokerClosed) || ctx.Err() != nil {
+					return
+				}
+				if err != nil && consumeCtx.Err() != nil {
+					continue // paused
+				}
+				if err != nil {
+					log.Printf("queue: consume: %v", err)
+					time.Sleep(100 * time.Millisecond)
+					continue
+				}
+				q.handle(ctx, workerID, d)
+			}
 		}(i)
 	}
 	return q
 }
 
-func (q *Queue) SetProcessor(p Processor) { q.processor = p }
-
-// ConfigureRetry sets retry/backoff parameters
-func (q *Queue) ConfigureRetry(maxAttempts int, base time.Duration, factor float64, max time.Duration, jitter bool) {
-	q.maxAttempts = maxAttempts
-	q.baseBackoff = base
-	q.factor = factor
-	q.maxBackoff = max
-	q.jitter = jitter
+func (q *Queue) SetProcessor(p Processor) {
+	q.executor.SetProcessor(p)
+	q.readyOnce.Do(func() { close(q.ready) })
 }
 
-func (q *Queue) SetDLQHandler(h DLQHandler) { q.dlqHandler = h }
-
-func (q *Queue) handleRetry(ctx context.Context, t *TaskWork) {
-	t.Attempts++
-	if t.Attempts >= q.maxAttempts {
-		atomic.AddInt64(&q.dlq, 1)
-		if q.dlqHandler != nil {
-			// call synchronously so caller can observe DLQ handling completion
-			q.dlqHandler(t.ID)
+func (q *Queue) handle(ctx context.Context, workerID string, d *Delivery) {
+	if d.Redelivered {
+		// the previous holder died mid-run; a task that never reached
+		// running is already queued
+		if err := q.record(ctx, d.Work.ID, models.StatusQueued, nil); err != nil && !errors.Is(err, models.ErrInvalidTransition) {
+			log.Printf("queue: requeue task %s: %v", d.Work.ID, err)
+		}
+	}
+	if q.typePaused(d.Work.Type) {
+		if err := q.broker.Nack(ctx, d, q.pausedDelay); err != nil && !errors.Is(err, ErrBrokerClosed) {
+			log.Printf("queue: put back paused task %s: %v", d.Work.ID, err)
+		}
+		return
+	}
+	if wait := q.throttle(ctx, d.Work.Type); wait > 0 {
+		atomic.AddInt64(&q.throttled, 1)
+		if err := q.broker.Nack(ctx, d, wait); err != nil && !errors.Is(err, ErrBrokerClosed) {
+			log.Printf("queue: defer rate limited task %s: %v", d.Work.ID, err)
 		}
 		return
 	}
-	// compute backoff
-	backoff := float64(q.baseBackoff) * math.Pow(q.factor, float64(t.Attempts-1))
-	if backoff > float64(q.maxBackoff) {
-		backoff = float64(q.maxBackoff)
+	taskCtx, cancel := context.WithCancel(ctx)
+	q.mu.Lock()
+	q.running[d] = cancel
+	q.mu.Unlock()
+	delay, retry := q.execute(taskCtx, workerID, d.Work)
+	q.mu.Lock()
+	delete(q.running, d)
+	q.mu.Unlock()
+	cancel()
+
+	var err error
+	if retry {
+		err = q.broker.Nack(ctx, d, delay)
+	} else {
+		err = q.broker.Ack(ctx, d)
+	}
+	if err != nil && !errors.Is(err, ErrBrokerClosed) {
+		log.Printf("queue: settle task %s: %v", d.Work.ID, err)
 	}
-	d := time.Duration(backoff)
+}
 
-	q.retryMu.Lock()
-	timer := time.AfterFunc(d, func() {
-		// enqueue again unless stopped
+// waitResumed waits while the queue is paused and returns the context to
+// consume with, or nil once the queue is stopped while paused.
+func (q *Queue) waitResumed() context.Context {
+	for {
+		q.pauseMu.Lock()
+		ctx, resumed := q.consumeCtx, q.resumed
+		q.pauseMu.Unlock()
+		if ctx != nil {
+			return ctx
+		}
 		select {
-		case <-ctx.Done():
-			// canceled
-		default:
-			q.Enqueue(t)
+		case <-resumed:
+		case <-q.done:
+			return nil
 		}
-		q.retryMu.Lock()
-		delete(q.timers, t.ID)
-		q.retryMu.Unlock()
-	})
-	q.timers[t.ID] = timer
-	q.retryMu.Unlock()
+	}
+}
+
+// Pause stops workers from taking new work; work already running finishes
+// and enqueueing still succeeds. A queue stopped while paused is not drained.
+func (q *Queue) Pause() {
+	q.pauseMu.Lock()
+	defer q.pauseMu.Unlock()
+	if q.consumeCtx == nil {
+		return
+	}
+	q.stopConsuming()
+	q.consumeCtx = nil
+	q.resumed = make(chan struct{})
 }
 
-func (q *Queue) Enqueue(t *TaskWork) {
-	select {
-	case q.work <- t:
-	default:
-		q.work <- t
+// Resume lets workers of a paused queue take work again.
+func (q *Queue) Resume() {
+	q.pauseMu.Lock()
+	defer q.pauseMu.Unlock()
+	if q.consumeCtx != nil {
+		return
 	}
+	q.consumeCtx, q.stopConsuming = context.WithCancel(q.base)
+	close(q.resumed)
+	q.resumed = nil
+}
+
+// Paused reports whether the queue is paused.
+func (q *Queue) Paused() bool {
+	q.pauseMu.Lock()
+	defer q.pauseMu.Unlock()
+	return q.consumeCtx == nil
 }
 
-// WaitIdle waits until queue has no queued items, no inflight, and no timers (retries)
+func (q *Queue) typePaused(taskType string) bool {
+	q.pauseMu.Lock()
+	defer q.pauseMu.Unlock()
+	return q.pausedTypes[taskType]
+}
+
+// ApplyPauses pauses the queue, as DefaultQueue, and the task types that
+// pauses name, and resumes everything else.
+func (q *Queue) ApplyPauses(pauses []models.Pause) { q.applyPauses(DefaultQueue, pauses) }
+
+// applyPauses is ApplyPauses for a queue named name. Work of a paused type is
+// still taken from the broker, but put back until the type is resumed.
+func (q *Queue) applyPauses(name string, pauses []models.Pause) {
+	paused := false
+	types := map[string]bool{}
+	for _, p := range pauses {
+		switch p.Scope {
+		case models.PauseQueue:
+			paused = paused || p.Name == name
+		case models.PauseTaskType:
+			types[p.Name] = true
+		}
+	}
+	if paused {
+		q.Pause()
+	} else {
+		q.Resume()
+	}
+	q.pauseMu.Lock()
+	q.pausedTypes = types
+	q.pauseMu.Unlock()
+}
+
+// Workers returns the size of the worker pool.
+func (q *Queue) Workers() int { return q.workers }
+
+// SetLimiter sets where rate limit tokens are taken from. The default limits
+// this process only; share a RedisLimiter to limit all replicas together.
+func (q *Queue) SetLimiter(l Limiter) { q.limiter = l }
+
+// SetRateLimits limits how often the queue dispatches work, to queue overall
+// and to the rate perType returns for each task type. Zero rates do not
+// limit; perType may be nil. Type limits are shared by all queues with the
+// same limiter. Work over a limit is deferred until a token is available,
+// without counting as an attempt.
+func (q *Queue) SetRateLimits(queue Rate, perType func(taskType string) Rate) {
+	q.queueRate = queue
+	q.typeRate = perType
+}
+
+// throttle takes a token from each limit that applies to work of taskType and
+// returns 0, or how long to defer the work when a limit is used up. The type
+// token is taken first and refunded if the queue has none, so a throttled
+// type never spends the tokens other types in the queue depend on.
+func (q *Queue) throttle(ctx context.Context, taskType string) time.Duration {
+	q.mu.Lock()
+	name := q.name
+	q.mu.Unlock()
+	var typeRate Rate
+	if q.typeRate != nil {
+		typeRate = q.typeRate(taskType)
+	}
+	typeKey := "type:" + taskType
+	wait, err := q.limiter.Take(ctx, typeKey, typeRate)
+	if err == nil && wait == 0 {
+		if wait, err = q.limiter.Take(ctx, "queue:"+name, q.queueRate); err != nil || wait > 0 {
+			if rerr := q.limiter.Refund(ctx, typeKey, typeRate); rerr != nil {
+				log.Printf("queue: rate limit refund: %v", rerr)
+			}
+		}
+	}
+	if err != nil {
+		log.Printf("queue: rate limit: %v", err)
+		return q.pausedDelay
+	}
+	return wait
+}
+
+func (q *Queue) Enqueue(t *TaskWork) error {
+	err := q.broker.Enqueue(context.Background(), t)
+	if errors.Is(err, ErrBrokerClosed) {
+		return ErrQueueStopped
+	}
+	return err
+}
+
+// Cancel drops queued work and pending retries of task id and cancels the
+// context of a processor running it. It does not change the task status;
+// callers mark the task cancelled in the store first so that work already
+// handed out is skipped.
+func (q *Queue) Cancel(ctx context.Context, id string) error {
+	q.mu.Lock()
+	for d, cancel := range q.running {
+		if d.Work.ID == id {
+			cancel()
+		}
+	}
+	q.mu.Unlock()
+	if _, err := q.broker.Cancel(ctx, id); err != nil && !errors.Is(err, ErrBrokerClosed) {
+		return err
+	}
+	return nil
+}
+
+// WaitIdle waits until the broker holds no pending, delayed or in-flight work
 func (q *Queue) WaitIdle(timeout time.Duration) bool {
 	deadline := time.Now().Add(timeout)
 	for time.Now().Before(deadline) {
-		q.retryMu.Lock()
-		timersEmpty := len(q.timers) == 0
-		q.retryMu.Unlock()
-		queued := int64(len(q.work))
-		if queued == 0 && atomic.LoadInt64(&q.inflight) == 0 && timersEmpty {
+		s, err := q.broker.Stats(context.Background())
+		if err == nil && s.Pending == 0 && s.Delayed == 0 && s.Inflight == 0 && atomic.LoadInt64(&q.inflight) == 0 {
 			return true
 		}
 		time.Sleep(5 * time.Millisecond)
@@ -163,17 +356,14 @@ func (q *Queue) WaitIdle(timeout time.Duration) bool {
 	return false
 }
 
+// Stop closes the broker and waits for workers to exit. With the in-memory
+// broker, already queued work is drained first.
 func (q *Queue) Stop() {
 	q.stopOnce.Do(func() {
-		// stop all retry timers
-		q.retryMu.Lock()
-		for _, t := range q.timers {
-			t.Stop()
+		close(q.done)
+		if err := q.broker.Close(); err != nil {
+			log.Printf("queue: close broker: %v", err)
 		}
-		q.timers = map[string]*time.Timer{}
-		q.retryMu.Unlock()
-		// close work channel to let workers drain remaining items
-		close(q.work)
 		q.wg.Wait()
 		// finally cancel any context to free resources
 		q.cancel()
@@ -182,9 +372,23 @@ func (q *Queue) Stop() {
 
 // Stats returns basic counters
 func (q *Queue) Stats() (queued, inflight, processed, failed, dlq int64) {
-	q.retryMu.Lock()
-	timersCount := int64(len(q.timers))
-	q.retryMu.Unlock()
-	queued = int64(len(q.work)) + timersCount
+	s, _ := q.broker.Stats(context.Background())
+	queued = s.Pending + s.Delayed
 	return queued, atomic.LoadInt64(&q.inflight), atomic.LoadInt64(&q.processed), atomic.LoadInt64(&q.failed), atomic.LoadInt64(&q.dlq)
 }
+
+// PendingByPriority returns the number of deliveries ready to run at each
+// priority, leaving out priorities with none.
+func (q *Queue) PendingByPriority() map[int]int64 {
+	s, _ := q.broker.Stats(context.Background())
+	if s.ByPriority == nil {
+		return map[int]int64{}
+	}
+	return s.ByPriority
+}
+
+// HasQueue reports whether name is DefaultQueue, the only queue of a Queue.
+func (q *Queue) HasQueue(name string) bool { return name == DefaultQueue }
+
+// QueueStats returns the counters of the queue as DefaultQueue.
+func (q *Queue) QueueStats() []QueueStats { return []QueueStats{q.stats(DefaultQueue)} }
@@ -2,32 +2,98 @@ package service
 
 import (
 	"context"
+	"path/filepath"
 	"sync/atomic"
 	"testing"
 	"time"
+
+	miniredis "github.com/alicebob/miniredis/v2"
 )
 
-func TestQueue_ProcessesTasks(t *testing.T) {
-	var processed int32
-	q := NewQueue(4)
-	q.SetProcessor(func(ctx context.Context, t *TaskWork) error {
-		atomic.AddInt32(&processed, 1)
-		return nil
+// The queue tests in this package form a conformance suite: every Broker
+// implementation must pass them. forEachBroker runs fn once per backend;
+// newBroker returns brokers sharing the same backing storage, so a second
+// broker sees work left behind by the first.
+func forEachBroker(t *testing.T, fn func(t *testing.T, newBroker func() Broker)) {
+	t.Run("memory", func(t *testing.T) {
+		fn(t, func() Broker { return NewMemoryBroker() })
 	})
+	t.Run("redis", func(t *testing.T) {
+		mr, err := miniredis.Run()
+		if err != nil {
+			t.Fatalf("failed to start miniredis: %v", err)
+		}
+		t.Cleanup(mr.Close)
+		fn(t, func() Broker { return newTestRedisBroker(t, mr.Addr()) })
+	})
+	t.Run("bolt", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "queue.db")
+		fn(t, func() Broker { return newTestBoltBroker(t, path) })
+	})
+}
 
-	for i := 0; i < 10; i++ {
-		q.Enqueue(&TaskWork{ID: "task"})
+func newTestRedisBroker(t *testing.T, addr string) *RedisBroker {
+	t.Helper()
+	b, err := newRedisBroker(addr, "test", 2*time.Millisecond, 20*time.Millisecond, 60*time.Millisecond)
+	if err != nil {
+		t.Fatalf("redis broker: %v", err)
 	}
+	return b
+}
 
-	time.Sleep(100 * time.Millisecond)
-	q.Stop()
+func TestQueue_ProcessesTasks(t *testing.T) {
+	forEachBroker(t, func(t *testing.T, newBroker func() Broker) {
+		var processed int32
+		q := NewQueueWithBroker(newBroker(), 4)
+		q.SetProcessor(func(ctx context.Context, t *TaskWork) error {
+			atomic.AddInt32(&processed, 1)
+			return nil
+		})
 
-	if processed != 10 {
-		t.Errorf("expected 10 tasks processed, got %d", processed)
-	}
+		for i := 0; i < 10; i++ {
+			q.Enqueue(&TaskWork{ID: "task"})
+		}
+
+		q.WaitIdle(time.Second)
+		q.Stop()
+
+		if processed != 10 {
+			t.Errorf("expected 10 tasks processed, got %d", processed)
+		}
+	})
 }
 
 func TestQueue_StopDrainsSafely(t *testing.T) {
+	forEachBroker(t, func(t *testing.T, newBroker func() Broker) {
+		var processed int32
+		proc := func(ctx context.Context, t *TaskWork) error {
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt32(&processed, 1)
+			return nil
+		}
+		q := NewQueueWithBroker(newBroker(), 2)
+		q.SetProcessor(proc)
+
+		for i := 0; i < 5; i++ {
+			q.Enqueue(&TaskWork{ID: "t"})
+		}
+
+		q.Stop()
+
+		// brokers either drain on stop or keep the remainder for the next
+		// consumer; either way nothing is lost
+		next := NewQueueWithBroker(newBroker(), 2)
+		next.SetProcessor(proc)
+		next.WaitIdle(time.Second)
+		next.Stop()
+
+		if processed != 5 {
+			t.Errorf("expected 5 tasks processed after Stop, got %d", processed)
+		}
+	})
+}
+
+func TestQueue_MemoryStopDrains(t *testing.T) {
 	var processed int32
 	q := NewQueue(2)
 	q.SetProcessor(func(ctx context.Context, t *TaskWork) error {
@@ -45,4 +111,7 @@ func TestQueue_StopDrainsSafely(t *testing.T) {
 	if processed != 5 {
 		t.Errorf("expected 5 tasks processed after Stop, got %d", processed)
 	}
+	if err := q.Enqueue(&TaskWork{ID: "late"}); err != ErrQueueStopped {
+		t.Errorf("expected ErrQueueStopped after Stop, got %v", err)
+	}
 }
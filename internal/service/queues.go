@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/husainaj20/task-manager-api/internal/models"
+)
+
+// DefaultQueue runs work that neither its task nor its type routes elsewhere.
+const DefaultQueue = "default"
+
+// ErrUnknownQueue is returned when work is routed to a queue that does not
+// exist.
+var ErrUnknownQueue = errors.New("unknown queue")
+
+// QueueStats reports the counters of one named queue.
+type QueueStats struct {
+	Name      string `json:"name"`
+	Workers   int    `json:"workers"`
+	Paused    bool   `json:"paused"`
+	Queued    int64  `json:"queued"`
+	Inflight  int64  `json:"inflight"`
+	Processed int64  `json:"processed"`
+	Failed    int64  `json:"failed"`
+	DLQ       int64  `json:"dlq"`
+	// Throttled counts dispatches deferred by a rate limit.
+	Throttled int64 `json:"throttled"`
+	// Abandoned counts handlers still running after their grace period.
+	Abandoned int64 `json:"abandoned"`
+}
+
+func (q *Queue) stats(name string) QueueStats {
+	queued, inflight, processed, failed, dlq := q.Stats()
+	return QueueStats{
+		Name:      name,
+		Workers:   q.Workers(),
+		Paused:    q.Paused(),
+		Queued:    queued,
+		Inflight:  inflight,
+		Processed: processed,
+		Failed:    failed,
+		DLQ:       dlq,
+		Throttled: atomic.LoadInt64(&q.throttled),
+		Abandoned: atomic.LoadInt64(&q.abandoned),
+	}
+}
+
+// QueueSet routes work to named queues, each with its own broker, workers,
+// retry policy and pause state, so a slow task type only holds up the
+// workers of its own queue. Work goes to the queue it names, else to the one
+// the router returns for its type, else to DefaultQueue.
+type QueueSet struct {
+	mu     sync.RWMutex
+	names  []string
+	queues map[string]*Queue
+	route  func(taskType string) string
+}
+
+func NewQueueSet() *QueueSet {
+	return &QueueSet{queues: make(map[string]*Queue)}
+}
+
+// Add registers q under name, replacing any queue of that name; q's queue
+// rate limit is kept under that name. The set stops q when it is stopped.
+func (s *QueueSet) Add(name string, q *Queue) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.queues[name]; !ok {
+		s.names = append(s.names, name)
+	}
+	s.queues[name] = q
+	q.mu.Lock()
+	q.name = name
+	q.mu.Unlock()
+}
+
+// SetRouter picks the queue for work that does not name one; route returns
+// "" to use DefaultQueue. Registry.Queue is a router.
+func (s *QueueSet) SetRouter(route func(taskType string) string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.route = route
+}
+
+// Lookup returns the queue registered under name.
+func (s *QueueSet) Lookup(name string) (*Queue, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	q, ok := s.queues[name]
+	return q, ok
+}
+
+// HasQueue reports whether a queue is registered under name.
+func (s *QueueSet) HasQueue(name string) bool {
+	_, ok := s.Lookup(name)
+	return ok
+}
+
+// Names returns the queue names in the order they were added.
+func (s *QueueSet) Names() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]string(nil), s.names...)
+}
+
+func (s *QueueSet) all() []*Queue {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	qs := make([]*Queue, 0, len(s.names))
+	for _, name := range s.names {
+		qs = append(qs, s.queues[name])
+	}
+	return qs
+}
+
+// Enqueue hands w to the queue it is routed to.
+func (s *QueueSet) Enqueue(w *TaskWork) error {
+	s.mu.RLock()
+	name := w.Queue
+	if name == "" && s.route != nil {
+		name = s.route(w.Type)
+	}
+	if name == "" {
+		name = DefaultQueue
+	}
+	q, ok := s.queues[name]
+	s.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownQueue, name)
+	}
+	return q.Enqueue(w)
+}
+
+// Cancel cancels task id on every queue, since its work may have been routed
+// before the routes changed.
+func (s *QueueSet) Cancel(ctx context.Context, id string) error {
+	var errs []error
+	for _, q := range s.all() {
+		errs = append(errs, q.Cancel(ctx, id))
+	}
+	return errors.Join(errs...)
+}
+
+// ApplyPauses pauses exactly the queues and task types pauses name.
+func (s *QueueSet) ApplyPauses(pauses []models.Pause) {
+	for _, name := range s.Names() {
+		if q, ok := s.Lookup(name); ok {
+			q.applyPauses(name, pauses)
+		}
+	}
+}
+
+// Stats sums the counters of all queues.
+func (s *QueueSet) Stats() (queued, inflight, processed, failed, dlq int64) {
+	for _, q := range s.all() {
+		qd, in, p, f, d := q.Stats()
+		queued += qd
+		inflight += in
+		processed += p
+		failed += f
+		dlq += d
+	}
+	return queued, inflight, processed, failed, dlq
+}
+
+// PendingByPriority sums the pending work of all queues per priority.
+func (s *QueueSet) PendingByPriority() map[int]int64 {
+	out := map[int]int64{}
+	for _, q := range s.all() {
+		for p, n := range q.PendingByPriority() {
+			out[p] += n
+		}
+	}
+	return out
+}
+
+// QueueStats returns the counters of each queue, in the order they were added.
+func (s *QueueSet) QueueStats() []QueueStats {
+	out := []QueueStats{}
+	for _, name := range s.Names() {
+		if q, ok := s.Lookup(name); ok {
+			out = append(out, q.stats(name))
+		}
+	}
+	return out
+}
+
+// WaitIdle waits until every queue is idle.
+func (s *QueueSet) WaitIdle(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for _, q := range s.all() {
+		if !q.WaitIdle(time.Until(deadline)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Stop stops every queue.
+func (s *QueueSet) Stop() {
+	for _, q := range s.all() {
+		q.Stop()
+	}
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestQueueSet_RoutesAndIsolates(t *testing.T) {
+	release := make(chan struct{})
+	ran := make(chan string, 10)
+	reg := NewRegistry()
+	reg.Register("slow", func(ctx context.Context, t *TaskWork) (map[string]any, error) {
+		<-release
+		ran <- t.ID
+		return nil, nil
+	})
+	reg.Register("fast", func(ctx context.Context, t *TaskWork) (map[string]any, error) {
+		ran <- t.ID
+		return nil, nil
+	})
+	reg.SetQueue("slow", "reports")
+
+	set := NewQueueSet()
+	for _, name := range []string{DefaultQueue, "reports"} {
+		q := NewQueue(1)
+		q.SetProcessor(reg.Process)
+		set.Add(name, q)
+	}
+	set.SetRouter(reg.Queue)
+	defer set.Stop()
+
+	set.Enqueue(&TaskWork{ID: "slow-1", Type: "slow"})
+	set.Enqueue(&TaskWork{ID: "slow-2", Type: "slow"})
+	set.Enqueue(&TaskWork{ID: "pinned", Type: "fast", Queue: "reports"})
+	set.Enqueue(&TaskWork{ID: "fast", Type: "fast"})
+	select {
+	case id := <-ran:
+		if id != "fast" {
+			t.Fatalf("expected the default queue to run first, got %s", id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("default queue held up by slow work on another queue")
+	}
+
+	if err := set.Enqueue(&TaskWork{ID: "x", Type: "fast", Queue: "missing"}); !errors.Is(err, ErrUnknownQueue) {
+		t.Fatalf("expected ErrUnknownQueue, got %v", err)
+	}
+	stats := set.QueueStats()
+	for deadline := time.Now().Add(time.Second); stats[1].Inflight == 0 && time.Now().Before(deadline); {
+		time.Sleep(5 * time.Millisecond)
+		stats = set.QueueStats()
+	}
+	if len(stats) != 2 || stats[1].Name != "reports" || stats[1].Workers != 1 || stats[1].Queued != 2 || stats[1].Inflight != 1 {
+		t.Fatalf("unexpected queue stats %+v", stats)
+	}
+
+	close(release)
+	for _, want := range []string{"slow-1", "slow-2", "pinned"} {
+		if id := <-ran; id != want {
+			t.Fatalf("expected %s next on the reports queue, got %s", want, id)
+		}
+	}
+}
+
+func TestQueue_PauseResume(t *testing.T) {
+	forEachBroker(t, func(t *testing.T, newBroker func() Broker) {
+		var processed int32
+		q := NewQueueWithBroker(newBroker(), 2)
+		q.SetProcessor(func(ctx context.Context, t *TaskWork) error {
+			atomic.AddInt32(&processed, 1)
+			return nil
+		})
+		defer q.Stop()
+
+		q.Pause()
+		q.Pause()
+		if !q.Paused() {
+			t.Fatal("expected the queue to report paused")
+		}
+		for i := 0; i < 3; i++ {
+			if err := q.Enqueue(&TaskWork{ID: "t"}); err != nil {
+				t.Fatalf("enqueue while paused: %v", err)
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+		if queued, _, _, _, _ := q.Stats(); atomic.LoadInt32(&processed) != 0 || queued != 3 {
+			t.Fatalf("expected work to wait while paused, processed %d, queued %d", processed, queued)
+		}
+
+		q.Resume()
+		if !q.WaitIdle(time.Second) || atomic.LoadInt32(&processed) != 3 || q.Paused() {
+			t.Fatalf("expected work to run after resume, processed %d", processed)
+		}
+	})
+}
+
+func TestQueue_StopWhilePaused(t *testing.T) {
+	q := NewQueue(2)
+	q.SetProcessor(func(ctx context.Context, t *TaskWork) error { return nil })
+	q.Pause()
+	stopped := make(chan struct{})
+	go func() {
+		q.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Stop hung on a paused queue")
+	}
+}
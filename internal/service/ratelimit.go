@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Rate allows Limit runs per Per, in bursts of up to Burst runs (Limit when
+// zero). A Rate with no Limit is unlimited.
+type Rate struct {
+	Limit int
+	Per   time.Duration
+	Burst int
+}
+
+// ParseRate parses a rate such as "50/1m", "50/m" or "10/s".
+func ParseRate(s string) (Rate, error) {
+	n, per, ok := strings.Cut(s, "/")
+	limit, err := strconv.Atoi(n)
+	if !ok || err != nil || limit < 1 {
+		return Rate{}, fmt.Errorf("invalid rate %q: want <count>/<duration>, e.g. 50/1m", s)
+	}
+	if per != "" && (per[0] < '0' || per[0] > '9') {
+		per = "1" + per
+	}
+	d, err := time.ParseDuration(per)
+	if err != nil || d <= 0 {
+		return Rate{}, fmt.Errorf("invalid rate %q: want <count>/<duration>, e.g. 50/1m", s)
+	}
+	return Rate{Limit: limit, Per: d}, nil
+}
+
+func (r Rate) unlimited() bool { return r.Limit <= 0 || r.Per <= 0 }
+
+func (r Rate) burst() float64 {
+	if r.Burst > 0 {
+		return float64(r.Burst)
+	}
+	return float64(r.Limit)
+}
+
+// perMilli is the refill rate in tokens per millisecond.
+func (r Rate) perMilli() float64 {
+	return float64(r.Limit) * float64(time.Millisecond) / float64(r.Per)
+}
+
+// Limiter hands out tokens from named token buckets.
+type Limiter interface {
+	// Take takes a token from the bucket key, which refills at r. It returns
+	// 0 on success, or, when the bucket is empty, how long until a token is
+	// available; no token is taken then.
+	Take(ctx context.Context, key string, r Rate) (time.Duration, error)
+	// Refund puts back a token taken from the bucket key, up to its burst.
+	Refund(ctx context.Context, key string, r Rate) error
+}
+
+// MemoryLimiter keeps token buckets in process, limiting a single replica.
+type MemoryLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{buckets: make(map[string]*bucket)}
+}
+
+func (l *MemoryLimiter) Take(ctx context.Context, key string, r Rate) (time.Duration, error) {
+	if r.unlimited() {
+		return 0, nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: r.burst(), last: now}
+		l.buckets[key] = b
+	}
+	if now.After(b.last) {
+		elapsed := float64(now.Sub(b.last)) / float64(time.Millisecond)
+		b.tokens = math.Min(r.burst(), b.tokens+elapsed*r.perMilli())
+		b.last = now
+	}
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, nil
+	}
+	return time.Duration(math.Ceil((1-b.tokens)/r.perMilli())) * time.Millisecond, nil
+}
+
+func (l *MemoryLimiter) Refund(ctx context.Context, key string, r Rate) error {
+	if r.unlimited() {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if b, ok := l.buckets[key]; ok {
+		b.tokens = math.Min(r.burst(), b.tokens+1)
+	}
+	return nil
+}
+
+// RedisLimiter keeps token buckets in Redis, so the limits hold across all
+// replicas sharing it. Buckets are refilled by the callers' clocks.
+//
+// Keys (all under prefix):
+//
+//	ratelimit:<key>  hash of tokens left and the time they were counted (unix ms)
+type RedisLimiter struct {
+	rdb    *redis.Client
+	prefix string
+}
+
+func NewRedisLimiter(addr, prefix string) *RedisLimiter {
+	return &RedisLimiter{rdb: redis.NewClient(&redis.Options{Addr: addr}), prefix: prefix}
+}
+
+// takeToken refills the bucket KEYS[1] to ARGV[1] (unix ms) at ARGV[2]
+// tokens per ms up to ARGV[3] tokens, then takes one token and returns 0, or
+// returns the ms until one is available.
+var takeToken = redis.NewScript(`
+local now, rate, burst = tonumber(ARGV[1]), tonumber(ARGV[2]), tonumber(ARGV[3])
+local b = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens, ts = tonumber(b[1]) or burst, tonumber(b[2]) or now
+if now > ts then
+  tokens = math.min(burst, tokens + (now - ts) * rate)
+  ts = now
+end
+local wait = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+else
+  wait = math.ceil((1 - tokens) / rate)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(ts))
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate) + 1000)
+return wait
+`)
+
+func (l *RedisLimiter) Take(ctx context.Context, key string, r Rate) (time.Duration, error) {
+	if r.unlimited() {
+		return 0, nil
+	}
+	now := time.Now().UnixMilli()
+	wait, err := takeToken.Run(ctx, l.rdb, []string{l.prefix + ":ratelimit:" + key},
+		now, strconv.FormatFloat(r.perMilli(), 'g', -1, 64), r.burst()).Int64()
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(wait) * time.Millisecond, nil
+}
+
+// refundToken puts a token back into the bucket KEYS[1], up to ARGV[1]
+// tokens. A bucket that has expired is full already.
+var refundToken = redis.NewScript(`
+local tokens = tonumber(redis.call('HGET', KEYS[1], 'tokens'))
+if tokens then
+  redis.call('HSET', KEYS[1], 'tokens', tostring(math.min(tonumber(ARGV[1]), tokens + 1)))
+end
+return 0
+`)
+
+func (l *RedisLimiter) Refund(ctx context.Context, key string, r Rate) error {
+	if r.unlimited() {
+		return nil
+	}
+	return refundToken.Run(ctx, l.rdb, []string{l.prefix + ":ratelimit:" + key}, r.burst()).Err()
+}
+
+func (l *RedisLimiter) Close() error { return l.rdb.Close() }
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	miniredis "github.com/alicebob/miniredis/v2"
+)
+
+func TestParseRate(t *testing.T) {
+	for in, want := range map[string]Rate{
+		"50/1m":   {Limit: 50, Per: time.Minute},
+		"50/m":    {Limit: 50, Per: time.Minute},
+		"10/s":    {Limit: 10, Per: time.Second},
+		"3/500ms": {Limit: 3, Per: 500 * time.Millisecond},
+	} {
+		if got, err := ParseRate(in); err != nil || got != want {
+			t.Errorf("ParseRate(%q) = %+v, %v; want %+v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "50", "0/m", "x/m", "5/0s", "5/-1s", "5/fortnight"} {
+		if _, err := ParseRate(in); err == nil {
+			t.Errorf("ParseRate(%q): expected an error", in)
+		}
+	}
+}
+
+func TestLimiter_TokenBucket(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	defer mr.Close()
+	redisLimiter := NewRedisLimiter(mr.Addr(), "test")
+	defer redisLimiter.Close()
+
+	ctx := context.Background()
+	for name, l := range map[string]Limiter{"memory": NewMemoryLimiter(), "redis": redisLimiter} {
+		t.Run(name, func(t *testing.T) {
+			hourly := Rate{Limit: 2, Per: time.Hour}
+			for i := 0; i < 2; i++ {
+				if wait, err := l.Take(ctx, "hourly", hourly); wait != 0 || err != nil {
+					t.Fatalf("take %d: expected a token, got wait %v (%v)", i, wait, err)
+				}
+			}
+			if wait, _ := l.Take(ctx, "hourly", hourly); wait < 29*time.Minute || wait > 30*time.Minute {
+				t.Fatalf("expected to wait about half an hour, got %v", wait)
+			}
+			if err := l.Refund(ctx, "hourly", hourly); err != nil {
+				t.Fatal(err)
+			}
+			if wait, _ := l.Take(ctx, "hourly", hourly); wait != 0 {
+				t.Fatalf("expected the refunded token, got wait %v", wait)
+			}
+			if wait, _ := l.Take(ctx, "other", hourly); wait != 0 {
+				t.Fatalf("expected buckets to be separate, got wait %v", wait)
+			}
+			if wait, _ := l.Take(ctx, "unlimited", Rate{}); wait != 0 {
+				t.Fatalf("expected the zero rate not to limit, got wait %v", wait)
+			}
+
+			fast := Rate{Limit: 1, Per: 30 * time.Millisecond}
+			l.Take(ctx, "fast", fast)
+			wait, _ := l.Take(ctx, "fast", fast)
+			if wait <= 0 {
+				t.Fatal("expected the bucket to be empty")
+			}
+			time.Sleep(wait)
+			if wait, _ := l.Take(ctx, "fast", fast); wait != 0 {
+				t.Fatalf("expected the bucket refilled, got wait %v", wait)
+			}
+		})
+	}
+}
+
+func TestRedisLimiter_SharedAcrossReplicas(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	var granted int32
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		l := NewRedisLimiter(mr.Addr(), "test")
+		defer l.Close()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 5; j++ {
+				if wait, err := l.Take(context.Background(), "type:send-sms", Rate{Limit: 4, Per: time.Hour}); err == nil && wait == 0 {
+					atomic.AddInt32(&granted, 1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if granted != 4 {
+		t.Fatalf("expected 4 tokens granted across replicas, got %d", granted)
+	}
+}
+
+func TestQueue_RateLimitDefers(t *testing.T) {
+	var mu sync.Mutex
+	var started []time.Time
+	q := NewQueue(4)
+	defer q.Stop()
+	q.SetRateLimits(Rate{}, func(taskType string) Rate {
+		if taskType == "sms" {
+			return Rate{Limit: 2, Per: 200 * time.Millisecond}
+		}
+		return Rate{}
+	})
+	q.SetProcessor(func(ctx context.Context, t *TaskWork) error {
+		if t.Attempts != 0 {
+			return Permanent(ErrTimeout)
+		}
+		mu.Lock()
+		started = append(started, time.Now())
+		mu.Unlock()
+		return nil
+	})
+
+	begin := time.Now()
+	for i := 0; i < 4; i++ {
+		q.Enqueue(&TaskWork{ID: "sms", Type: "sms"})
+	}
+	if !q.WaitIdle(2 * time.Second) {
+		t.Fatal("expected deferred work to run")
+	}
+	_, _, processed, failed, _ := q.Stats()
+	if processed != 4 || failed != 0 {
+		t.Fatalf("expected all work processed without failures, got %d processed, %d failed", processed, failed)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	// a burst of 2, then one every 100ms
+	if last := started[len(started)-1].Sub(begin); last < 190*time.Millisecond {
+		t.Fatalf("expected the rate limit to spread out runs, last started after %v", last)
+	}
+	if s := q.QueueStats()[0]; s.Throttled == 0 {
+		t.Fatalf("expected throttled dispatches counted, got %+v", s)
+	}
+}
+
+func TestQueue_ThrottledTypeKeepsQueueTokens(t *testing.T) {
+	q := NewQueue(1)
+	defer q.Stop()
+	q.SetRateLimits(Rate{Limit: 2, Per: time.Hour}, func(taskType string) Rate {
+		if taskType == "slow" {
+			return Rate{Limit: 1, Per: time.Hour}
+		}
+		return Rate{}
+	})
+	ctx := context.Background()
+	if wait := q.throttle(ctx, "slow"); wait != 0 {
+		t.Fatalf("expected the first slow task to run, got wait %v", wait)
+	}
+	for i := 0; i < 5; i++ {
+		if wait := q.throttle(ctx, "slow"); wait == 0 {
+			t.Fatal("expected the slow type to be throttled")
+		}
+	}
+	if wait := q.throttle(ctx, "fast"); wait != 0 {
+		t.Fatalf("expected the throttled type to leave the queue's token to others, got wait %v", wait)
+	}
+	if wait := q.throttle(ctx, "fast"); wait == 0 {
+		t.Fatal("expected the queue limit to hold")
+	}
+}
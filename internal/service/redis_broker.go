@@ -0,0 +1,441 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/husainaj20/task-manager-api/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisBroker is a durable Broker backed by Redis. Work survives a process
+// restart: every delivery is moved atomically from its ready set to a
+// per-consumer processing list and only removed once it is settled, so
+// deliveries held by a consumer that died are made ready again by the next
+// consumer to start.
+//
+// Keys (all under prefix):
+//
+//	queue:ready:<priority>      zset delivery id -> arrival sequence, waiting for a worker
+//	queue:seq                   arrival sequence counter
+//	queue:taken                 deliveries handed out, for oldestTurn
+//	queue:items                 hash delivery id -> TaskWork JSON
+//	queue:deliveries:<task>     set of the delivery ids of a task, for Cancel
+//	queue:priority              hash delivery id -> priority
+//	queue:delayed               zset delivery id -> due time (unix ms), for retries
+//	queue:reclaimed             set of delivery ids taken back from dead consumers
+//	queue:processing:<consumer> list of delivery ids held by a consumer
+//	queue:consumers             set of consumer ids
+//	queue:heartbeat:<consumer>  expiring key refreshed while a consumer is alive
+type RedisBroker struct {
+	rdb        *redis.Client
+	prefix     string
+	consumerID string
+
+	pollInterval      time.Duration
+	heartbeatInterval time.Duration
+	heartbeatTTL      time.Duration
+
+	mu          sync.Mutex
+	closed      bool
+	outstanding int
+	settled     *sync.Cond
+
+	done      chan struct{}
+	maintWG   sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// NewRedisBroker connects to Redis at addr, registers a consumer and reclaims
+// work abandoned by dead consumers.
+func NewRedisBroker(addr string, prefix string) (*RedisBroker, error) {
+	return newRedisBroker(addr, prefix, 100*time.Millisecond, 5*time.Second, 15*time.Second)
+}
+
+func newRedisBroker(addr, prefix string, poll, beat, ttl time.Duration) (*RedisBroker, error) {
+	host, _ := os.Hostname()
+	b := &RedisBroker{
+		rdb:               redis.NewClient(&redis.Options{Addr: addr}),
+		prefix:            prefix,
+		consumerID:        fmt.Sprintf("%s-%d-%s", host, os.Getpid(), uuid.NewString()[:8]),
+		pollInterval:      poll,
+		heartbeatInterval: beat,
+		heartbeatTTL:      ttl,
+		done:              make(chan struct{}),
+	}
+	b.settled = sync.NewCond(&b.mu)
+
+	ctx := context.Background()
+	if err := b.heartbeat(ctx); err != nil {
+		b.rdb.Close()
+		return nil, err
+	}
+	if _, err := b.reclaim(ctx); err != nil {
+		b.rdb.Close()
+		return nil, err
+	}
+	// work enqueued before priorities sits on a plain pending list
+	if err := requeueList.Run(ctx, b.rdb, b.readyKeys(b.k("pending"), b.k("reclaimed")), 0).Err(); err != nil {
+		b.rdb.Close()
+		return nil, err
+	}
+	b.maintWG.Add(1)
+	go b.maintain()
+	return b, nil
+}
+
+func (b *RedisBroker) k(parts ...string) string {
+	s := b.prefix + ":queue"
+	for _, p := range parts {
+		s += ":" + p
+	}
+	return s
+}
+
+// The scripts below take the keys from readyKeys first: KEYS[1] is the
+// priority hash, KEYS[2] the sequence counter and KEYS[3+p] the ready set of
+// priority p. Their own keys follow from KEYS[13].
+
+// readyLua defines ready(id), which appends a delivery to the ready set of its
+// priority.
+const readyLua = `
+local function ready(id)
+  local p = tonumber(redis.call('HGET', KEYS[1], id) or '0')
+  redis.call('ZADD', KEYS[3 + p], redis.call('INCR', KEYS[2]), id)
+end
+`
+
+// enqueueItem stores a new delivery, records it in the delivery set KEYS[14]
+// of its task and makes it ready.
+var enqueueItem = redis.NewScript(readyLua + `
+redis.call('HSET', KEYS[13], ARGV[1], ARGV[2])
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[3])
+redis.call('SADD', KEYS[14], ARGV[1])
+ready(ARGV[1])
+return 1
+`)
+
+// promoteDue makes delayed deliveries whose due time has passed ready in one
+// step, so concurrent consumers never promote twice.
+var promoteDue = redis.NewScript(readyLua + `
+local ids = redis.call('ZRANGEBYSCORE', KEYS[13], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+for _, id in ipairs(ids) do
+  redis.call('ZREM', KEYS[13], id)
+  ready(id)
+end
+return #ids
+`)
+
+// requeueList makes every delivery in the list KEYS[13] ready, adding them to
+// the reclaimed set KEYS[14] if ARGV[1] is 1.
+var requeueList = redis.NewScript(readyLua + `
+local n = 0
+while true do
+  local id = redis.call('RPOP', KEYS[13])
+  if not id then break end
+  if ARGV[1] == '1' then redis.call('SADD', KEYS[14], id) end
+  ready(id)
+  n = n + 1
+end
+return n
+`)
+
+// takeReady moves the next ready delivery onto the processing list KEYS[14]
+// and returns its id. It applies oldestTurn, with ARGV[1] as oldestEvery and
+// KEYS[13] counting deliveries handed out.
+var takeReady = redis.NewScript(`
+local n = tonumber(redis.call('GET', KEYS[13]) or '0') + 1
+local pick, key, seq
+for i = #KEYS - 2, 3, -1 do
+  local head = redis.call('ZRANGE', KEYS[i], 0, 0, 'WITHSCORES')
+  if head[1] then
+    if n % tonumber(ARGV[1]) ~= 0 then
+      pick, key = head[1], KEYS[i]
+      break
+    end
+    if not seq or tonumber(head[2]) < seq then
+      pick, key, seq = head[1], KEYS[i], tonumber(head[2])
+    end
+  end
+end
+if not pick then return false end
+redis.call('SET', KEYS[13], n)
+redis.call('ZREM', key, pick)
+redis.call('LPUSH', KEYS[14], pick)
+return pick
+`)
+
+// cancelItems removes the deliveries in the delivery set KEYS[15] of a task
+// from the ready and delayed sets, deleting the items of those it found.
+// Deliveries held by a consumer are left in place until they are acked.
+var cancelItems = redis.NewScript(`
+local removed = 0
+for _, id in ipairs(redis.call('SMEMBERS', KEYS[15])) do
+  local n = redis.call('ZREM', KEYS[13], id)
+  for p = 3, 12 do
+    n = n + redis.call('ZREM', KEYS[p], id)
+  end
+  if n > 0 then
+    redis.call('HDEL', KEYS[14], id)
+    redis.call('HDEL', KEYS[1], id)
+    redis.call('SREM', KEYS[15], id)
+    removed = removed + 1
+  elseif redis.call('HEXISTS', KEYS[14], id) == 0 then
+    redis.call('SREM', KEYS[15], id)
+  end
+end
+return removed
+`)
+
+// readyKeys returns the keys the scripts above start with, followed by extra.
+func (b *RedisBroker) readyKeys(extra ...string) []string {
+	keys := []string{b.k("priority"), b.k("seq")}
+	for p := models.MinPriority; p <= models.MaxPriority; p++ {
+		keys = append(keys, b.k("ready", strconv.Itoa(p)))
+	}
+	return append(keys, extra...)
+}
+
+// maintain keeps the heartbeat fresh, promotes due retries and reclaims work
+// from consumers whose heartbeat expired.
+func (b *RedisBroker) maintain() {
+	defer b.maintWG.Done()
+	ctx := context.Background()
+	beat := time.NewTicker(b.heartbeatInterval)
+	defer beat.Stop()
+	poll := time.NewTicker(b.pollInterval)
+	defer poll.Stop()
+	for {
+		select {
+		case <-b.done:
+			return
+		case <-beat.C:
+			if err := b.heartbeat(ctx); err != nil {
+				log.Printf("redis broker: heartbeat: %v", err)
+			}
+			if _, err := b.reclaim(ctx); err != nil {
+				log.Printf("redis broker: reclaim: %v", err)
+			}
+		case <-poll.C:
+			if err := promoteDue.Run(ctx, b.rdb, b.readyKeys(b.k("delayed")), time.Now().UnixMilli(), 100).Err(); err != nil {
+				log.Printf("redis broker: promote: %v", err)
+			}
+		}
+	}
+}
+
+func (b *RedisBroker) heartbeat(ctx context.Context) error {
+	_, err := b.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.SAdd(ctx, b.k("consumers"), b.consumerID)
+		p.Set(ctx, b.k("heartbeat", b.consumerID), time.Now().UnixMilli(), b.heartbeatTTL)
+		return nil
+	})
+	return err
+}
+
+// reclaim returns deliveries held by consumers without a live heartbeat to
+// the pending list and reports how many were moved.
+func (b *RedisBroker) reclaim(ctx context.Context) (int, error) {
+	consumers, err := b.rdb.SMembers(ctx, b.k("consumers")).Result()
+	if err != nil {
+		return 0, err
+	}
+	moved := 0
+	for _, c := range consumers {
+		if c == b.consumerID {
+			continue
+		}
+		alive, err := b.rdb.Exists(ctx, b.k("heartbeat", c)).Result()
+		if err != nil {
+			return moved, err
+		}
+		if alive > 0 {
+			continue
+		}
+		n, err := requeueList.Run(ctx, b.rdb, b.readyKeys(b.k("processing", c), b.k("reclaimed")), 1).Int()
+		moved += n
+		if err != nil {
+			return moved, err
+		}
+		if err := b.rdb.SRem(ctx, b.k("consumers"), c).Err(); err != nil {
+			return moved, err
+		}
+	}
+	return moved, nil
+}
+
+func (b *RedisBroker) Enqueue(ctx context.Context, w *TaskWork) error {
+	if b.isClosed() {
+		return ErrBrokerClosed
+	}
+	data, err := json.Marshal(w)
+	if err != nil {
+		return err
+	}
+	return enqueueItem.Run(ctx, b.rdb, b.readyKeys(b.k("items"), b.k("deliveries", w.ID)), uuid.NewString(), data, priorityOf(w)).Err()
+}
+
+func (b *RedisBroker) isClosed() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.closed
+}
+
+// Consume polls the ready sets until a delivery is available, taking them by
+// priority as described at oldestEvery.
+func (b *RedisBroker) Consume(ctx context.Context) (*Delivery, error) {
+	for {
+		b.mu.Lock()
+		if b.closed {
+			b.mu.Unlock()
+			return nil, ErrBrokerClosed
+		}
+		b.outstanding++
+		b.mu.Unlock()
+
+		d, err := b.take(ctx)
+		if d != nil {
+			return d, nil
+		}
+		b.settle()
+		if err != nil {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-b.done:
+			return nil, ErrBrokerClosed
+		case <-time.After(b.pollInterval):
+		}
+	}
+}
+
+// take moves at most one delivery to this consumer's processing list.
+func (b *RedisBroker) take(ctx context.Context) (*Delivery, error) {
+	processing := b.k("processing", b.consumerID)
+	id, err := takeReady.Run(ctx, b.rdb, b.readyKeys(b.k("taken"), processing), oldestEvery).Text()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var item *redis.StringCmd
+	var reclaimed *redis.BoolCmd
+	_, err = b.rdb.Pipelined(ctx, func(p redis.Pipeliner) error {
+		item = p.HGet(ctx, b.k("items"), id)
+		reclaimed = p.SIsMember(ctx, b.k("reclaimed"), id)
+		return nil
+	})
+	if err == redis.Nil {
+		// item vanished; drop the dangling id
+		return nil, b.rdb.LRem(ctx, processing, 1, id).Err()
+	}
+	if err != nil {
+		return nil, err
+	}
+	var w TaskWork
+	if err := json.Unmarshal([]byte(item.Val()), &w); err != nil {
+		return nil, err
+	}
+	return &Delivery{ID: id, Work: &w, Redelivered: reclaimed.Val()}, nil
+}
+
+func (b *RedisBroker) settle() {
+	b.mu.Lock()
+	b.outstanding--
+	b.settled.Broadcast()
+	b.mu.Unlock()
+}
+
+func (b *RedisBroker) Ack(ctx context.Context, d *Delivery) error {
+	defer b.settle()
+	_, err := b.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.LRem(ctx, b.k("processing", b.consumerID), 1, d.ID)
+		p.HDel(ctx, b.k("items"), d.ID)
+		p.HDel(ctx, b.k("priority"), d.ID)
+		p.SRem(ctx, b.k("reclaimed"), d.ID)
+		p.SRem(ctx, b.k("deliveries", d.Work.ID), d.ID)
+		return nil
+	})
+	return err
+}
+
+func (b *RedisBroker) Nack(ctx context.Context, d *Delivery, delay time.Duration) error {
+	defer b.settle()
+	data, err := json.Marshal(d.Work)
+	if err != nil {
+		return err
+	}
+	_, err = b.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.LRem(ctx, b.k("processing", b.consumerID), 1, d.ID)
+		p.SRem(ctx, b.k("reclaimed"), d.ID)
+		p.HSet(ctx, b.k("items"), d.ID, data)
+		p.ZAdd(ctx, b.k("delayed"), redis.Z{Score: float64(time.Now().Add(delay).UnixMilli()), Member: d.ID})
+		return nil
+	})
+	return err
+}
+
+// Cancel removes the deliveries of task id not yet handed out, found through
+// the task's delivery set.
+func (b *RedisBroker) Cancel(ctx context.Context, id string) (int, error) {
+	return cancelItems.Run(ctx, b.rdb, b.readyKeys(b.k("delayed"), b.k("items"), b.k("deliveries", id))).Int()
+}
+
+// Stats reports pending and delayed work across all consumers and work held
+// by this one.
+func (b *RedisBroker) Stats(ctx context.Context) (BrokerStats, error) {
+	var ready [models.MaxPriority + 1]*redis.IntCmd
+	var delayed, processing *redis.IntCmd
+	_, err := b.rdb.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for i := range ready {
+			ready[i] = p.ZCard(ctx, b.k("ready", strconv.Itoa(i)))
+		}
+		delayed = p.ZCard(ctx, b.k("delayed"))
+		processing = p.LLen(ctx, b.k("processing", b.consumerID))
+		return nil
+	})
+	if err != nil {
+		return BrokerStats{}, err
+	}
+	s := BrokerStats{Delayed: delayed.Val(), Inflight: processing.Val(), ByPriority: map[int]int64{}}
+	for p, n := range ready {
+		if n.Val() > 0 {
+			s.ByPriority[p] = n.Val()
+			s.Pending += n.Val()
+		}
+	}
+	return s, nil
+}
+
+// Close stops handing out work, waits for outstanding deliveries to be
+// settled and deregisters the consumer. Pending work stays in Redis for the
+// next consumer.
+func (b *RedisBroker) Close() error {
+	b.closeOnce.Do(func() {
+		b.mu.Lock()
+		b.closed = true
+		close(b.done)
+		for b.outstanding > 0 {
+			b.settled.Wait()
+		}
+		b.mu.Unlock()
+		b.maintWG.Wait()
+
+		ctx := context.Background()
+		b.rdb.SRem(ctx, b.k("consumers"), b.consumerID)
+		b.rdb.Del(ctx, b.k("heartbeat", b.consumerID))
+		b.rdb.Close()
+	})
+	return nil
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	miniredis "github.com/alicebob/miniredis/v2"
+	"github.com/husainaj20/task-manager-api/internal/models"
+	"github.com/husainaj20/task-manager-api/internal/store"
+)
+
+func TestRedisBroker_RetriesAndCleansUp(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	st := store.NewMemoryStore()
+	q := NewQueueWithBroker(newTestRedisBroker(t, mr.Addr()), 2)
+	q.ConfigureRetry(3, 5*time.Millisecond, 2.0, 50*time.Millisecond, false)
+	q.SetStore(st)
+	var calls int32
+	q.SetProcessor(func(ctx context.Context, tw *TaskWork) error {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			return errors.New("transient")
+		}
+		tw.Result = map[string]any{"echo": tw.Payload["msg"]}
+		return nil
+	})
+	defer q.Stop()
+
+	task, _, _ := st.CreateOrGetByKey(context.Background(), "", &models.Task{Type: "echo", Payload: map[string]any{"msg": "hi"}, Status: models.StatusQueued})
+	if err := q.Enqueue(NewTaskWork(task)); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	waitStatus(t, st, task.ID, models.StatusSucceeded)
+	if !q.WaitIdle(time.Second) {
+		t.Fatalf("queue did not become idle")
+	}
+
+	got, _ := st.Get(context.Background(), task.ID)
+	if got.Attempts != 2 || got.Result["echo"] != "hi" {
+		t.Fatalf("unexpected task %+v", got)
+	}
+	if n, _ := mr.HKeys("test:queue:items"); len(n) != 0 {
+		t.Fatalf("expected items to be cleaned up, got %v", n)
+	}
+}
+
+func TestRedisBroker_ReclaimsFromDeadConsumer(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	st := store.NewMemoryStore()
+	ctx := context.Background()
+	task, _, _ := st.CreateOrGetByKey(ctx, "", &models.Task{Type: "echo", Status: models.StatusQueued})
+
+	// a consumer took the work, marked it running and died without
+	// deregistering; its heartbeat then expires
+	dead := newTestRedisBroker(t, mr.Addr())
+	if err := dead.Enqueue(ctx, NewTaskWork(task)); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if _, err := dead.Consume(ctx); err != nil {
+		t.Fatalf("consume: %v", err)
+	}
+	if err := st.UpdateStatus(ctx, task.ID, models.StatusRunning, nil); err != nil {
+		t.Fatalf("mark running: %v", err)
+	}
+	close(dead.done)
+	dead.maintWG.Wait()
+	mr.Del(dead.k("heartbeat", dead.consumerID))
+
+	var processed int32
+	q := NewQueueWithBroker(newTestRedisBroker(t, mr.Addr()), 1)
+	q.SetStore(st)
+	q.SetProcessor(func(ctx context.Context, tw *TaskWork) error {
+		atomic.AddInt32(&processed, 1)
+		return nil
+	})
+	defer q.Stop()
+
+	waitStatus(t, st, task.ID, models.StatusSucceeded)
+	if atomic.LoadInt32(&processed) != 1 {
+		t.Fatalf("expected reclaimed task processed once, got %d", processed)
+	}
+	if mr.Exists(dead.k("processing", dead.consumerID)) {
+		t.Fatalf("expected dead consumer processing list to be drained")
+	}
+}
+
+func TestRedisBroker_CloseKeepsPendingWork(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	ctx := context.Background()
+	b := newTestRedisBroker(t, mr.Addr())
+	for i := 0; i < 3; i++ {
+		if err := b.Enqueue(ctx, &TaskWork{ID: "t"}); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+	}
+	b.Close()
+	if _, err := b.Consume(ctx); !errors.Is(err, ErrBrokerClosed) {
+		t.Fatalf("expected ErrBrokerClosed, got %v", err)
+	}
+
+	next := newTestRedisBroker(t, mr.Addr())
+	defer next.Close()
+	s, err := next.Stats(ctx)
+	if err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+	if s.Pending != 3 {
+		t.Fatalf("expected 3 pending after restart, got %+v", s)
+	}
+}
+
+func TestRedisBroker_MigratesPendingList(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	// work queued by a broker that kept a single pending list
+	mr.HSet("test:queue:items", "d1", `{"id":"t1","type":"echo"}`)
+	mr.Lpush("test:queue:pending", "d1")
+
+	ctx := context.Background()
+	b := newTestRedisBroker(t, mr.Addr())
+	defer b.Close()
+	if s, err := b.Stats(ctx); err != nil || s.Pending != 1 || s.ByPriority[0] != 1 {
+		t.Fatalf("expected the legacy delivery ready at priority 0, got %+v (%v)", s, err)
+	}
+	d, err := b.Consume(ctx)
+	if err != nil || d.ID != "d1" || d.Work.ID != "t1" {
+		t.Fatalf("unexpected delivery %+v (%v)", d, err)
+	}
+	b.Ack(ctx, d)
+	if mr.Exists("test:queue:pending") {
+		t.Fatalf("expected the legacy pending list to be drained")
+	}
+}
+
+func TestRedisBroker_TracksDeliveriesPerTask(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	b := newTestRedisBroker(t, mr.Addr())
+	defer b.Close()
+	ctx := context.Background()
+	for _, id := range []string{"a", "b", "a"} {
+		if err := b.Enqueue(ctx, &TaskWork{ID: id}); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+	}
+	if n, _ := mr.SMembers("test:queue:deliveries:a"); len(n) != 2 {
+		t.Fatalf("expected 2 deliveries of a, got %v", n)
+	}
+	if n, err := b.Cancel(ctx, "a"); err != nil || n != 2 {
+		t.Fatalf("expected 2 removed, got %d (%v)", n, err)
+	}
+	d, err := b.Consume(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Ack(ctx, d)
+	for _, id := range []string{"a", "b"} {
+		if mr.Exists("test:queue:deliveries:" + id) {
+			t.Fatalf("expected the deliveries of %s cleared", id)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrUnknownTaskType is returned when no handler is registered for a task type.
+var ErrUnknownTaskType = errors.New("unknown task type")
+
+// Handler processes a single task of the type it was registered for and
+// returns the result to store on the task.
+type Handler func(ctx context.Context, t *TaskWork) (map[string]any, error)
+
+// Registry maps task types to their handlers.
+type Registry struct {
+	mu       sync.RWMutex
+	handlers map[string]Handler
+	timeouts map[string]time.Duration
+	queues   map[string]string
+	rates    map[string]Rate
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		handlers: make(map[string]Handler),
+		timeouts: make(map[string]time.Duration),
+		queues:   make(map[string]string),
+		rates:    make(map[string]Rate),
+	}
+}
+
+// Register installs h for taskType, replacing any previous handler.
+func (r *Registry) Register(taskType string, h Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[taskType] = h
+}
+
+// SetTimeout limits how long a single run of taskType may take. Tasks can
+// override it with their own timeout.
+func (r *Registry) SetTimeout(taskType string, d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.timeouts[taskType] = d
+}
+
+// Timeout returns the timeout set for taskType, or 0 if there is none.
+func (r *Registry) Timeout(taskType string) time.Duration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.timeouts[taskType]
+}
+
+// SetQueue routes tasks of taskType that do not name a queue to queue.
+func (r *Registry) SetQueue(taskType, queue string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.queues[taskType] = queue
+}
+
+// Queue returns the queue taskType is routed to, or "" if there is none.
+func (r *Registry) Queue(taskType string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.queues[taskType]
+}
+
+// SetRateLimit limits how often tasks of taskType are dispatched, on every
+// queue that applies the registry's rates.
+func (r *Registry) SetRateLimit(taskType string, rate Rate) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rates[taskType] = rate
+}
+
+// RateLimit returns the rate set for taskType; the zero Rate does not limit.
+func (r *Registry) RateLimit(taskType string) Rate {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.rates[taskType]
+}
+
+func (r *Registry) Lookup(taskType string) (Handler, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	h, ok := r.handlers[taskType]
+	return h, ok
+}
+
+// Has reports whether a handler is registered for taskType.
+func (r *Registry) Has(taskType string) bool {
+	_, ok := r.Lookup(taskType)
+	return ok
+}
+
+// Types returns the registered task types in sorted order.
+func (r *Registry) Types() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	types := make([]string, 0, len(r.handlers))
+	for k := range r.handlers {
+		types = append(types, k)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Process dispatches t to the handler registered for t.Type and stores the
+// handler's result in t.Result. It satisfies Processor so a Registry can be
+// installed directly on a Queue.
+func (r *Registry) Process(ctx context.Context, t *TaskWork) error {
+	h, ok := r.Lookup(t.Type)
+	if !ok {
+		return Permanent(fmt.Errorf("%w: %q", ErrUnknownTaskType, t.Type))
+	}
+	res, err := h(ctx, t)
+	if err != nil {
+		return err
+	}
+	t.Result = res
+	return nil
+}
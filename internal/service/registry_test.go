@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRegistry_DispatchesByType(t *testing.T) {
+	reg := NewRegistry()
+	var echoes, reports int32
+	reg.Register("echo", func(ctx context.Context, tw *TaskWork) (map[string]any, error) {
+		atomic.AddInt32(&echoes, 1)
+		return nil, nil
+	})
+	reg.Register("report", func(ctx context.Context, tw *TaskWork) (map[string]any, error) {
+		atomic.AddInt32(&reports, 1)
+		return nil, nil
+	})
+
+	q := NewQueue(2)
+	q.SetProcessor(reg.Process)
+	q.Enqueue(&TaskWork{ID: "a", Type: "echo"})
+	q.Enqueue(&TaskWork{ID: "b", Type: "report"})
+	q.Enqueue(&TaskWork{ID: "c", Type: "echo"})
+	if !q.WaitIdle(time.Second) {
+		t.Fatalf("queue did not become idle")
+	}
+	q.Stop()
+
+	if echoes != 2 || reports != 1 {
+		t.Fatalf("expected 2 echo and 1 report, got %d and %d", echoes, reports)
+	}
+}
+
+func TestRegistry_UnknownType(t *testing.T) {
+	reg := NewRegistry()
+	reg.Register("echo", func(ctx context.Context, tw *TaskWork) (map[string]any, error) { return nil, nil })
+
+	err := reg.Process(context.Background(), &TaskWork{ID: "x", Type: "nope"})
+	if !errors.Is(err, ErrUnknownTaskType) {
+		t.Fatalf("expected ErrUnknownTaskType, got %v", err)
+	}
+	if !reg.Has("echo") || reg.Has("nope") {
+		t.Fatalf("unexpected Has results")
+	}
+	if got := reg.Types(); len(got) != 1 || got[0] != "echo" {
+		t.Fatalf("unexpected types %v", got)
+	}
+}
+
+func TestRegistry_ProcessStoresHandlerResult(t *testing.T) {
+	reg := NewRegistry()
+	reg.Register("sum", func(ctx context.Context, tw *TaskWork) (map[string]any, error) {
+		a, _ := tw.Payload["a"].(float64)
+		b, _ := tw.Payload["b"].(float64)
+		return map[string]any{"sum": a + b, "requestedBy": tw.Metadata["user"]}, nil
+	})
+
+	tw := &TaskWork{
+		ID:       "s1",
+		Type:     "sum",
+		Payload:  map[string]any{"a": 2.0, "b": 3.0},
+		Metadata: map[string]string{"user": "alice"},
+	}
+	if err := reg.Process(context.Background(), tw); err != nil {
+		t.Fatalf("process: %v", err)
+	}
+	if tw.Result["sum"] != 5.0 || tw.Result["requestedBy"] != "alice" {
+		t.Fatalf("unexpected result %v", tw.Result)
+	}
+}
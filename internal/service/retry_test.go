@@ -9,124 +9,155 @@ import (
 )
 
 func TestRetry_SucceedsBeforeMax(t *testing.T) {
-	q := NewQueue(2)
-	q.ConfigureRetry(4, 5*time.Millisecond, 2.0, 50*time.Millisecond, false)
-	defer q.Stop()
-
-	var attempts int32
-	q.SetProcessor(func(ctx context.Context, tw *TaskWork) error {
-		atomic.AddInt32(&attempts, 1)
-		if atomic.LoadInt32(&attempts) <= 2 {
-			return context.DeadlineExceeded
-		}
-		return nil
-	})
+	forEachBroker(t, func(t *testing.T, newBroker func() Broker) {
+		q := NewQueueWithBroker(newBroker(), 2)
+		q.ConfigureRetry(4, 5*time.Millisecond, 2.0, 50*time.Millisecond, false)
+		defer q.Stop()
 
-	q.Enqueue(&TaskWork{ID: "t1"})
-	// Poll attempts until expected or timeout
-	deadline := time.Now().Add(500 * time.Millisecond)
-	for time.Now().Before(deadline) {
-		if atomic.LoadInt32(&attempts) >= 3 {
-			break
+		var attempts int32
+		q.SetProcessor(func(ctx context.Context, tw *TaskWork) error {
+			atomic.AddInt32(&attempts, 1)
+			if atomic.LoadInt32(&attempts) <= 2 {
+				return context.DeadlineExceeded
+			}
+			return nil
+		})
+
+		q.Enqueue(&TaskWork{ID: "t1"})
+		// Poll attempts until expected or timeout
+		deadline := time.Now().Add(500 * time.Millisecond)
+		for time.Now().Before(deadline) {
+			if atomic.LoadInt32(&attempts) >= 3 {
+				break
+			}
+			time.Sleep(2 * time.Millisecond)
 		}
-		time.Sleep(2 * time.Millisecond)
-	}
-	if atomic.LoadInt32(&attempts) != 3 {
-		t.Fatalf("expected 3 attempts, got %d", atomic.LoadInt32(&attempts))
-	}
+		if atomic.LoadInt32(&attempts) != 3 {
+			t.Fatalf("expected 3 attempts, got %d", atomic.LoadInt32(&attempts))
+		}
+	})
 }
 
 func TestRetry_ExceedsMax_GoesToDLQ(t *testing.T) {
-	q := NewQueue(1)
-	q.ConfigureRetry(3, 5*time.Millisecond, 2.0, 50*time.Millisecond, false)
-	defer q.Stop()
-
-	var failed int32
-	var dlqCalled int32
-	q.SetDLQHandler(func(id string) {
-		atomic.AddInt32(&dlqCalled, 1)
-	})
-	q.SetProcessor(func(ctx context.Context, tw *TaskWork) error {
-		atomic.AddInt32(&failed, 1)
-		return context.DeadlineExceeded
-	})
+	forEachBroker(t, func(t *testing.T, newBroker func() Broker) {
+		q := NewQueueWithBroker(newBroker(), 1)
+		q.ConfigureRetry(3, 5*time.Millisecond, 2.0, 50*time.Millisecond, false)
+		defer q.Stop()
+
+		var failed int32
+		var dlqCalled int32
+		q.SetDLQHandler(func(id string) {
+			atomic.AddInt32(&dlqCalled, 1)
+		})
+		q.SetProcessor(func(ctx context.Context, tw *TaskWork) error {
+			atomic.AddInt32(&failed, 1)
+			return context.DeadlineExceeded
+		})
 
-	q.Enqueue(&TaskWork{ID: "t-dlq"})
-	dl := time.Now().Add(500 * time.Millisecond)
-	for time.Now().Before(dl) {
-		if atomic.LoadInt32(&dlqCalled) >= 1 {
-			break
+		q.Enqueue(&TaskWork{ID: "t-dlq"})
+		dl := time.Now().Add(500 * time.Millisecond)
+		for time.Now().Before(dl) {
+			if atomic.LoadInt32(&dlqCalled) >= 1 {
+				break
+			}
+			time.Sleep(2 * time.Millisecond)
 		}
-		time.Sleep(2 * time.Millisecond)
-	}
-	if atomic.LoadInt32(&dlqCalled) != 1 {
-		t.Fatalf("expected dlq called once, got %d", atomic.LoadInt32(&dlqCalled))
-	}
+		if atomic.LoadInt32(&dlqCalled) != 1 {
+			t.Fatalf("expected dlq called once, got %d", atomic.LoadInt32(&dlqCalled))
+		}
+	})
 }
 
 func TestBackoff_DelaysGrow(t *testing.T) {
-	q := NewQueue(1)
-	q.ConfigureRetry(4, 5*time.Millisecond, 2.0, 40*time.Millisecond, false)
-	defer q.Stop()
-
-	var mu2 sync.Mutex
-	var attempts2 int32
-	timestamps := []time.Time{}
-	q.SetProcessor(func(ctx context.Context, tw *TaskWork) error {
-		mu2.Lock()
-		timestamps = append(timestamps, time.Now())
-		mu2.Unlock()
-		atomic.AddInt32(&attempts2, 1)
+	forEachBroker(t, func(t *testing.T, newBroker func() Broker) {
+		q := NewQueueWithBroker(newBroker(), 1)
+		q.ConfigureRetry(4, 5*time.Millisecond, 2.0, 40*time.Millisecond, false)
+		defer q.Stop()
+
+		var mu2 sync.Mutex
+		var attempts2 int32
+		timestamps := []time.Time{}
+		q.SetProcessor(func(ctx context.Context, tw *TaskWork) error {
+			mu2.Lock()
+			timestamps = append(timestamps, time.Now())
+			mu2.Unlock()
+			atomic.AddInt32(&attempts2, 1)
+			if atomic.LoadInt32(&attempts2) < 4 {
+				return context.DeadlineExceeded
+			}
+			return nil
+		})
+
+		q.Enqueue(&TaskWork{ID: "t-back"})
+		dl2 := time.Now().Add(2 * time.Second)
+		for time.Now().Before(dl2) {
+			if atomic.LoadInt32(&attempts2) >= 4 {
+				break
+			}
+			time.Sleep(2 * time.Millisecond)
+		}
 		if atomic.LoadInt32(&attempts2) < 4 {
-			return context.DeadlineExceeded
+			t.Fatalf("expected 4 attempts, got %d", atomic.LoadInt32(&attempts2))
 		}
-		return nil
-	})
 
-	q.Enqueue(&TaskWork{ID: "t-back"})
-	dl2 := time.Now().Add(2 * time.Second)
-	for time.Now().Before(dl2) {
-		if atomic.LoadInt32(&attempts2) >= 4 {
-			break
+		mu2.Lock()
+		if len(timestamps) != 4 {
+			mu2.Unlock()
+			t.Fatalf("expected 4 attempts, got %d", len(timestamps))
 		}
-		time.Sleep(2 * time.Millisecond)
-	}
-	if atomic.LoadInt32(&attempts2) < 4 {
-		t.Fatalf("expected 4 attempts, got %d", atomic.LoadInt32(&attempts2))
-	}
-
-	mu2.Lock()
-	if len(timestamps) != 4 {
+		d1 := timestamps[1].Sub(timestamps[0])
+		d2 := timestamps[2].Sub(timestamps[1])
+		d3 := timestamps[3].Sub(timestamps[2])
 		mu2.Unlock()
-		t.Fatalf("expected 4 attempts, got %d", len(timestamps))
-	}
-	d1 := timestamps[1].Sub(timestamps[0])
-	d2 := timestamps[2].Sub(timestamps[1])
-	d3 := timestamps[3].Sub(timestamps[2])
-	mu2.Unlock()
 
-	if d1 < 4*time.Millisecond || d1 > 50*time.Millisecond {
-		t.Fatalf("unexpected d1 %v", d1)
-	}
-	if d2 < 8*time.Millisecond || d2 > 100*time.Millisecond {
-		t.Fatalf("unexpected d2 %v", d2)
-	}
-	if d3 < 16*time.Millisecond || d3 > 200*time.Millisecond {
-		t.Fatalf("unexpected d3 %v", d3)
-	}
+		if d1 < 4*time.Millisecond || d1 > 50*time.Millisecond {
+			t.Fatalf("unexpected d1 %v", d1)
+		}
+		if d2 < 8*time.Millisecond || d2 > 100*time.Millisecond {
+			t.Fatalf("unexpected d2 %v", d2)
+		}
+		if d3 < 16*time.Millisecond || d3 > 200*time.Millisecond {
+			t.Fatalf("unexpected d3 %v", d3)
+		}
+	})
 }
 
 func TestStop_WhileRetriesScheduled(t *testing.T) {
-	q := NewQueue(1)
-	q.ConfigureRetry(3, 5*time.Millisecond, 2.0, 50*time.Millisecond, false)
+	forEachBroker(t, func(t *testing.T, newBroker func() Broker) {
+		q := NewQueueWithBroker(newBroker(), 1)
+		q.ConfigureRetry(3, 5*time.Millisecond, 2.0, 50*time.Millisecond, false)
+
+		q.SetProcessor(func(ctx context.Context, tw *TaskWork) error {
+			return context.DeadlineExceeded
+		})
 
-	q.SetProcessor(func(ctx context.Context, tw *TaskWork) error {
-		return context.DeadlineExceeded
+		q.Enqueue(&TaskWork{ID: "t-stop"})
+		// give it a moment to schedule retries
+		time.Sleep(10 * time.Millisecond)
+		q.Stop()
+		// If Stop returns, test is successful (no panic/hang)
 	})
+}
 
-	q.Enqueue(&TaskWork{ID: "t-stop"})
-	// give it a moment to schedule retries
-	time.Sleep(10 * time.Millisecond)
-	q.Stop()
-	// If Stop returns, test is successful (no panic/hang)
+func TestParseRetryPolicy(t *testing.T) {
+	for in, want := range map[string]RetryPolicy{
+		"5":          {MaxAttempts: 5},
+		"1:200ms":    {MaxAttempts: 1, Backoff: 200 * time.Millisecond},
+		"5:200ms:1m": {MaxAttempts: 5, Backoff: 200 * time.Millisecond, MaxBackoff: time.Minute},
+	} {
+		if got, err := ParseRetryPolicy(in); err != nil || got != want {
+			t.Errorf("ParseRetryPolicy(%q) = %+v, %v; want %+v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "0", "x", "5:", "5:0s", "5:1s:10ms", "5:1s:1m:2"} {
+		if _, err := ParseRetryPolicy(in); err == nil {
+			t.Errorf("ParseRetryPolicy(%q): expected an error", in)
+		}
+	}
+
+	e := newExecutor()
+	e.SetRetryPolicy(RetryPolicy{MaxAttempts: 7, Backoff: 10 * time.Second})
+	if e.maxAttempts != 7 || e.baseBackoff != 10*time.Second || e.maxBackoff != 10*time.Second {
+		t.Fatalf("unexpected policy %d %s %s", e.maxAttempts, e.baseBackoff, e.maxBackoff)
+	}
 }
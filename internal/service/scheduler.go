@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/husainaj20/task-manager-api/internal/models"
+	"github.com/husainaj20/task-manager-api/internal/store"
+	"github.com/robfig/cron/v3"
+)
+
+// NextRun returns the first time after after at which the standard 5-field
+// cron expression fires in timezone tz ("" means UTC).
+func NextRun(expr, tz string, after time.Time) (time.Time, error) {
+	sched, err := cron.ParseStandard(expr)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+	}
+	loc := time.UTC
+	if tz != "" {
+		if loc, err = time.LoadLocation(tz); err != nil {
+			return time.Time{}, fmt.Errorf("invalid timezone %q: %w", tz, err)
+		}
+	}
+	return sched.Next(after.In(loc)).UTC(), nil
+}
+
+// Scheduler materializes tasks from recurring schedules. Every replica may run
+// one: a run only fires on the replica that advances the schedule in the
+// store, and the task is created under an idempotency key derived from the
+// schedule and run time.
+type Scheduler struct {
+	store store.Store
+	q     Enqueuer
+
+	wg       sync.WaitGroup
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func NewScheduler(st store.Store, q Enqueuer, interval time.Duration) *Scheduler {
+	s := &Scheduler{store: st, q: q, stop: make(chan struct{})}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				if _, err := s.FireDue(context.Background(), time.Now().UTC()); err != nil {
+					log.Printf("scheduler: %v", err)
+				}
+			}
+		}
+	}()
+	return s
+}
+
+// FireDue creates a task for every active schedule due at now and returns how
+// many were created. Runs missed while no replica was up fire once, then the
+// schedule moves on to its next run after now.
+func (s *Scheduler) FireDue(ctx context.Context, now time.Time) (int, error) {
+	schedules, err := s.store.ListSchedules(ctx)
+	if err != nil {
+		return 0, err
+	}
+	fired := 0
+	for _, sc := range schedules {
+		if sc.Paused || sc.NextRunAt.After(now) {
+			continue
+		}
+		ok, err := s.fire(ctx, sc, now)
+		if err != nil {
+			log.Printf("scheduler: schedule %s: %v", sc.ID, err)
+			continue
+		}
+		if ok {
+			fired++
+		}
+	}
+	return fired, nil
+}
+
+// fire creates the task of sc's due run, then advances sc. The run's key
+// makes creating it idempotent, so a run is never lost: if advancing fails,
+// or the replica dies before it, the next attempt finds the task and only
+// advances. Only the replica that created the task enqueues it, before
+// advancing.
+func (s *Scheduler) fire(ctx context.Context, sc *models.Schedule, now time.Time) (bool, error) {
+	next, err := NextRun(sc.Cron, sc.Timezone, now)
+	if err != nil {
+		return false, err
+	}
+
+	runAt := sc.NextRunAt.UTC().Format(time.RFC3339)
+	metadata := map[string]string{"scheduleId": sc.ID, "scheduledAt": runAt}
+	for k, v := range sc.Metadata {
+		metadata[k] = v
+	}
+	t := &models.Task{
+		Type:     sc.TaskType,
+		Payload:  renderTemplate(sc.Payload, sc.ID, runAt),
+		Metadata: metadata,
+		Status:   models.StatusQueued,
+	}
+	// the '/' keeps the key apart from client keys, which never contain one
+	// before their first ':'
+	key := fmt.Sprintf("schedule/%s/%d", sc.ID, sc.NextRunAt.Unix())
+	task, existed, err := s.store.CreateOrGetByKey(ctx, key, t)
+	if err != nil {
+		return false, err
+	}
+	var enqueueErr error
+	if !existed {
+		_, enqueueErr = EnqueueTask(ctx, s.store, s.q, task)
+	}
+	if _, err := s.store.AdvanceSchedule(ctx, sc.ID, sc.NextRunAt, next); err != nil {
+		return !existed, err
+	}
+	return !existed, enqueueErr
+}
+
+// renderTemplate copies a payload template, substituting {{scheduleId}} and
+// {{scheduledAt}} in string values.
+func renderTemplate(tmpl map[string]any, scheduleID, scheduledAt string) map[string]any {
+	r := strings.NewReplacer("{{scheduleId}}", scheduleID, "{{scheduledAt}}", scheduledAt)
+	var render func(v any) any
+	render = func(v any) any {
+		switch x := v.(type) {
+		case string:
+			return r.Replace(x)
+		case map[string]any:
+			out := make(map[string]any, len(x))
+			for k, e := range x {
+				out[k] = render(e)
+			}
+			return out
+		case []any:
+			out := make([]any, len(x))
+			for i, e := range x {
+				out[i] = render(e)
+			}
+			return out
+		default:
+			return v
+		}
+	}
+	if tmpl == nil {
+		return nil
+	}
+	return render(tmpl).(map[string]any)
+}
+
+func (s *Scheduler) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+		s.wg.Wait()
+	})
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	miniredis "github.com/alicebob/miniredis/v2"
+	"github.com/husainaj20/task-manager-api/internal/models"
+	"github.com/husainaj20/task-manager-api/internal/store"
+)
+
+type recordingEnqueuer struct {
+	mu   sync.Mutex
+	work []*TaskWork
+}
+
+func (r *recordingEnqueuer) Enqueue(t *TaskWork) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.work = append(r.work, t)
+	return nil
+}
+
+func TestNextRun_Timezone(t *testing.T) {
+	after := time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)
+	got, err := NextRun("0 9 * * *", "America/New_York", after)
+	if err != nil {
+		t.Fatalf("next run: %v", err)
+	}
+	// 12:00 UTC is 07:00 in New York (EST, UTC-5), so 09:00 local is 14:00 UTC
+	want := time.Date(2026, 1, 15, 14, 0, 0, 0, time.UTC)
+	if !got.Equal(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	if _, err := NextRun("not a cron", "", after); err == nil {
+		t.Fatalf("expected error for invalid expression")
+	}
+	if _, err := NextRun("* * * * *", "Mars/Olympus", after); err == nil {
+		t.Fatalf("expected error for invalid timezone")
+	}
+}
+
+func TestScheduler_FiresDueScheduleOnce(t *testing.T) {
+	st := store.NewMemoryStore()
+	q := &recordingEnqueuer{}
+	ctx := context.Background()
+	now := time.Now().UTC()
+	sc, _ := st.CreateSchedule(ctx, &models.Schedule{
+		Cron:      "*/5 * * * *",
+		Timezone:  "UTC",
+		TaskType:  "report",
+		Payload:   map[string]any{"note": "run {{scheduledAt}}"},
+		NextRunAt: now.Add(-time.Minute),
+	})
+	paused, _ := st.CreateSchedule(ctx, &models.Schedule{Cron: "* * * * *", TaskType: "report", Paused: true, NextRunAt: now.Add(-time.Minute)})
+
+	s := &Scheduler{store: st, q: q}
+	fired, err := s.FireDue(ctx, now)
+	if err != nil || fired != 1 {
+		t.Fatalf("expected 1 fired, got %d (%v)", fired, err)
+	}
+	if fired, _ := s.FireDue(ctx, now); fired != 0 {
+		t.Fatalf("expected no second fire for the same run, got %d", fired)
+	}
+
+	if len(q.work) != 1 {
+		t.Fatalf("expected 1 enqueued task, got %d", len(q.work))
+	}
+	w := q.work[0]
+	if w.Type != "report" || w.Metadata["scheduleId"] != sc.ID {
+		t.Fatalf("unexpected work %+v", w)
+	}
+	if w.Payload["note"] != "run "+sc.NextRunAt.Format(time.RFC3339) {
+		t.Fatalf("expected rendered template, got %v", w.Payload["note"])
+	}
+
+	updated, _ := st.GetSchedule(ctx, sc.ID)
+	if !updated.NextRunAt.After(now) || updated.LastRunAt == nil {
+		t.Fatalf("expected schedule advanced past now, got %+v", updated)
+	}
+	if p, _ := st.GetSchedule(ctx, paused.ID); p.LastRunAt != nil {
+		t.Fatalf("paused schedule should not fire")
+	}
+}
+
+// flakyScheduleStore fails creates and schedule advances while told to.
+type flakyScheduleStore struct {
+	store.Store
+	failCreate, failAdvance bool
+}
+
+func (f *flakyScheduleStore) CreateOrGetByKey(ctx context.Context, key string, t *models.Task) (*models.Task, bool, error) {
+	if f.failCreate {
+		return nil, false, errors.New("create failed")
+	}
+	return f.Store.CreateOrGetByKey(ctx, key, t)
+}
+
+func (f *flakyScheduleStore) AdvanceSchedule(ctx context.Context, id string, from, next time.Time) (bool, error) {
+	if f.failAdvance {
+		return false, errors.New("advance failed")
+	}
+	return f.Store.AdvanceSchedule(ctx, id, from, next)
+}
+
+func TestScheduler_RunIsNotLostWhenAWriteFails(t *testing.T) {
+	st := &flakyScheduleStore{Store: store.NewMemoryStore()}
+	q := &recordingEnqueuer{}
+	ctx := context.Background()
+	now := time.Now().UTC()
+	sc, _ := st.CreateSchedule(ctx, &models.Schedule{Cron: "* * * * *", TaskType: "report", NextRunAt: now.Add(-time.Minute)})
+	s := &Scheduler{store: st, q: q}
+
+	// a failed create leaves the schedule due
+	st.failCreate = true
+	s.FireDue(ctx, now)
+	if got, _ := st.GetSchedule(ctx, sc.ID); !got.NextRunAt.Equal(sc.NextRunAt) {
+		t.Fatalf("expected the schedule not advanced past an uncreated run, got %s", got.NextRunAt)
+	}
+
+	// a failed advance keeps the created run, which the retry finds
+	st.failCreate, st.failAdvance = false, true
+	s.FireDue(ctx, now)
+	st.failAdvance = false
+	if fired, err := s.FireDue(ctx, now); err != nil || fired != 0 {
+		t.Fatalf("expected the retry to find the created run, got %d (%v)", fired, err)
+	}
+	if got, _ := st.GetSchedule(ctx, sc.ID); !got.NextRunAt.After(now) {
+		t.Fatalf("expected the schedule advanced, got %s", got.NextRunAt)
+	}
+	if len(q.work) != 1 {
+		t.Fatalf("expected the run enqueued once, got %d", len(q.work))
+	}
+}
+
+func TestScheduler_NoDoubleFiringAcrossReplicas(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	setup := store.NewRedisStore(mr.Addr(), "test")
+	for i := 0; i < 5; i++ {
+		setup.CreateSchedule(ctx, &models.Schedule{Cron: "* * * * *", TaskType: "report", NextRunAt: now.Add(-time.Second)})
+	}
+
+	q := &recordingEnqueuer{}
+	var wg sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s := &Scheduler{store: store.NewRedisStore(mr.Addr(), "test"), q: q}
+			if _, err := s.FireDue(ctx, now); err != nil {
+				t.Errorf("fire: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(q.work) != 5 {
+		t.Fatalf("expected each of 5 schedules fired exactly once, got %d tasks", len(q.work))
+	}
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/husainaj20/task-manager-api/internal/models"
+	"github.com/husainaj20/task-manager-api/internal/store"
+)
+
+func TestTimeout_HungHandlerIsRetriedThenDead(t *testing.T) {
+	forEachBroker(t, func(t *testing.T, newBroker func() Broker) {
+		st := store.NewMemoryStore()
+		q := NewQueueWithBroker(newBroker(), 1)
+		q.ConfigureRetry(2, 5*time.Millisecond, 2.0, 50*time.Millisecond, false)
+		q.SetTimeouts(20*time.Millisecond, nil)
+		defer q.Stop()
+		q.SetStore(st)
+		var calls int32
+		q.SetProcessor(func(ctx context.Context, tw *TaskWork) error {
+			atomic.AddInt32(&calls, 1)
+			<-ctx.Done()
+			return ctx.Err()
+		})
+
+		task := createQueued(t, st, "echo")
+		q.Enqueue(NewTaskWork(task))
+		waitStatus(t, st, task.ID, models.StatusDead)
+
+		got, _ := st.Get(context.Background(), task.ID)
+		if atomic.LoadInt32(&calls) != 2 || len(got.History) != 2 {
+			t.Fatalf("expected 2 timed out attempts, got %d calls, %+v", calls, got.History)
+		}
+		if !strings.Contains(got.LastError, ErrTimeout.Error()) {
+			t.Fatalf("expected timeout error, got %q", got.LastError)
+		}
+	})
+}
+
+func TestTimeout_RetryWaitsForTimedOutRun(t *testing.T) {
+	st := store.NewMemoryStore()
+	q := NewQueue(2)
+	q.ConfigureRetry(3, time.Millisecond, 2.0, time.Millisecond, false)
+	q.SetTimeouts(10*time.Millisecond, nil)
+	defer q.Stop()
+	q.SetStore(st)
+	hang := make(chan struct{})
+	var calls, running, overlapped int32
+	q.SetProcessor(func(ctx context.Context, tw *TaskWork) error {
+		if atomic.AddInt32(&running, 1) > 1 {
+			atomic.StoreInt32(&overlapped, 1)
+		}
+		defer atomic.AddInt32(&running, -1)
+		if atomic.AddInt32(&calls, 1) == 1 {
+			<-hang // ignores ctx for a while
+			return ctx.Err()
+		}
+		return nil
+	})
+
+	task := createQueued(t, st, "echo")
+	q.Enqueue(NewTaskWork(task))
+	time.Sleep(60 * time.Millisecond)
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("expected no retry while the timed out run is live, got %d calls", n)
+	}
+	close(hang)
+	waitStatus(t, st, task.ID, models.StatusSucceeded)
+	if atomic.LoadInt32(&overlapped) != 0 {
+		t.Fatal("expected the retry not to overlap the first attempt")
+	}
+	got, _ := st.Get(context.Background(), task.ID)
+	if len(got.History) != 2 || !strings.Contains(got.History[0].Error, ErrTimeout.Error()) {
+		t.Fatalf("expected a timed out attempt then a retry, got %+v", got.History)
+	}
+}
+
+func TestTimeout_HandlerIgnoringCtxIsAbandoned(t *testing.T) {
+	st := store.NewMemoryStore()
+	q := NewQueue(1)
+	q.ConfigureRetry(1, time.Millisecond, 2.0, time.Millisecond, false)
+	q.SetTimeouts(10*time.Millisecond, nil)
+	q.SetGracePeriod(10 * time.Millisecond)
+	defer q.Stop()
+	q.SetStore(st)
+	hang := make(chan struct{})
+	q.SetProcessor(func(ctx context.Context, tw *TaskWork) error {
+		if tw.Type == "hang" {
+			<-hang // never honours ctx
+		}
+		return nil
+	})
+
+	hung := createQueued(t, st, "hang")
+	next := createQueued(t, st, "echo")
+	q.Enqueue(NewTaskWork(hung))
+	q.Enqueue(NewTaskWork(next))
+	waitStatus(t, st, next.ID, models.StatusSucceeded)
+
+	got, _ := st.Get(context.Background(), hung.ID)
+	if got.Status != models.StatusDead || !strings.Contains(got.LastError, ErrTimeout.Error()) {
+		t.Fatalf("expected the hung task dead with a timeout, got %s %q", got.Status, got.LastError)
+	}
+	if n := q.QueueStats()[0].Abandoned; n != 1 {
+		t.Fatalf("expected 1 abandoned handler, got %d", n)
+	}
+	close(hang)
+	deadline := time.Now().Add(time.Second)
+	for q.QueueStats()[0].Abandoned != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the abandoned count to drop once the handler returned")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestTimeout_TaskOverridesType(t *testing.T) {
+	reg := NewRegistry()
+	reg.SetTimeout("slow", time.Hour)
+	e := newExecutor()
+	e.SetTimeouts(time.Minute, reg.Timeout)
+
+	cases := []struct {
+		w    *TaskWork
+		want time.Duration
+	}{
+		{&TaskWork{Type: "other"}, time.Minute},
+		{&TaskWork{Type: "slow"}, time.Hour},
+		{&TaskWork{Type: "slow", Timeout: time.Second}, time.Second},
+	}
+	for _, c := range cases {
+		if got := e.timeoutFor(c.w); got != c.want {
+			t.Errorf("%+v: expected %s, got %s", c.w, c.want, got)
+		}
+	}
+}
+
+func TestTimeout_HandlerFinishingInTimeSucceeds(t *testing.T) {
+	st := store.NewMemoryStore()
+	reg := NewRegistry()
+	reg.Register("quick", func(ctx context.Context, tw *TaskWork) (map[string]any, error) {
+		return map[string]any{"ok": true}, nil
+	})
+	reg.SetTimeout("quick", time.Second)
+	q := NewQueue(1)
+	q.SetTimeouts(time.Nanosecond, reg.Timeout)
+	defer q.Stop()
+	q.SetStore(st)
+	q.SetProcessor(reg.Process)
+
+	task := createQueued(t, st, "quick")
+	q.Enqueue(NewTaskWork(task))
+	waitStatus(t, st, task.ID, models.StatusSucceeded)
+	got, _ := st.Get(context.Background(), task.ID)
+	if got.Result["ok"] != true {
+		t.Fatalf("expected result recorded, got %v", got.Result)
+	}
+}
@@ -0,0 +1,360 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/husainaj20/task-manager-api/internal/models"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	boltTasks     = []byte("tasks")     // task id -> task JSON
+	boltIdem      = []byte("idem")      // idempotency key -> boltKey JSON
+	boltScheduled = []byte("scheduled") // RunAt (unix ns, big endian) + task id -> nil
+	boltSchedules = []byte("schedules") // schedule id -> schedule JSON
+	boltPauses    = []byte("pauses")    // scope + ":" + name -> pause JSON
+	boltWorkflows = []byte("workflows") // workflow id -> workflow JSON
+)
+
+// BoltStore keeps tasks and schedules in a single bbolt file, for
+// deployments without a database server. Every write is a transaction that
+// is synced to disk before it returns, so a crash loses no acknowledged
+// write. The file is locked while open: only one process can use it.
+type BoltStore struct {
+	db      *bolt.DB
+	idemTTL time.Duration
+}
+
+// boltKey is the stored form of an idempotency key; Expires is zero for keys
+// kept until their task is deleted.
+type boltKey struct {
+	TaskID  string    `json:"taskId"`
+	Expires time.Time `json:"expires,omitempty"`
+}
+
+func (k boltKey) live(now time.Time) bool {
+	return idemEntry{taskID: k.TaskID, expires: k.Expires}.live(now)
+}
+
+// NewBoltStore opens, or creates, the store file at path.
+func NewBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltTasks, boltIdem, boltScheduled, boltSchedules, boltPauses, boltWorkflows} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltStore{db: db}, nil
+}
+
+// Close releases the file.
+func (b *BoltStore) Close() error { return b.db.Close() }
+
+// SetIdempotencyTTL sets how long an idempotency key keeps pointing at its
+// task. Zero, the default, keeps keys until the task is deleted.
+func (b *BoltStore) SetIdempotencyTTL(d time.Duration) { b.idemTTL = d }
+
+func boltDueKey(t *models.Task) []byte {
+	k := make([]byte, 8, 8+len(t.ID))
+	binary.BigEndian.PutUint64(k, uint64(t.RunAt.UnixNano()))
+	return append(k, t.ID...)
+}
+
+func boltGetTask(tx *bolt.Tx, id string) (*models.Task, error) {
+	data := tx.Bucket(boltTasks).Get([]byte(id))
+	if data == nil {
+		return nil, ErrNotFound
+	}
+	var t models.Task
+	if err := decodeTask(data, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// boltPutTask writes t and moves its entry in the scheduled index; prev is the
+// stored version of t, or nil for a new task.
+func boltPutTask(tx *bolt.Tx, t, prev *models.Task) error {
+	data, err := encodeTask(t)
+	if err != nil {
+		return err
+	}
+	scheduled := tx.Bucket(boltScheduled)
+	if prev != nil && prev.Status == models.StatusScheduled && prev.RunAt != nil {
+		if err := scheduled.Delete(boltDueKey(prev)); err != nil {
+			return err
+		}
+	}
+	if t.Status == models.StatusScheduled && t.RunAt != nil {
+		if err := scheduled.Put(boltDueKey(t), nil); err != nil {
+			return err
+		}
+	}
+	return tx.Bucket(boltTasks).Put([]byte(t.ID), data)
+}
+
+// boltDeleteTask removes t, its index entries, its idempotency key if that
+// still points at it, and its workflow once that has no tasks left.
+func boltDeleteTask(tx *bolt.Tx, t *models.Task) error {
+	if t.Status == models.StatusScheduled && t.RunAt != nil {
+		if err := tx.Bucket(boltScheduled).Delete(boltDueKey(t)); err != nil {
+			return err
+		}
+	}
+	if t.IdempotencyKey != "" {
+		if k, ok := boltGetKey(tx, t.IdempotencyKey); ok && k.TaskID == t.ID {
+			if err := tx.Bucket(boltIdem).Delete([]byte(t.IdempotencyKey)); err != nil {
+				return err
+			}
+		}
+	}
+	if err := tx.Bucket(boltTasks).Delete([]byte(t.ID)); err != nil {
+		return err
+	}
+	return boltDeleteEmptyWorkflow(tx, t.WorkflowID)
+}
+
+// boltDeleteEmptyWorkflow deletes workflow id once none of its tasks is left.
+func boltDeleteEmptyWorkflow(tx *bolt.Tx, id string) error {
+	if id == "" {
+		return nil
+	}
+	data := tx.Bucket(boltWorkflows).Get([]byte(id))
+	if data == nil {
+		return nil
+	}
+	var w models.Workflow
+	if err := json.Unmarshal(data, &w); err != nil {
+		return err
+	}
+	for _, taskID := range w.Tasks {
+		if tx.Bucket(boltTasks).Get([]byte(taskID)) != nil {
+			return nil
+		}
+	}
+	return tx.Bucket(boltWorkflows).Delete([]byte(id))
+}
+
+func boltGetKey(tx *bolt.Tx, key string) (boltKey, bool) {
+	var k boltKey
+	data := tx.Bucket(boltIdem).Get([]byte(key))
+	if data == nil || json.Unmarshal(data, &k) != nil {
+		return k, false
+	}
+	return k, true
+}
+
+func (b *BoltStore) CreateOrGetByKey(ctx context.Context, key string, t *models.Task) (*models.Task, bool, error) {
+	var existing *models.Task
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		now := time.Now().UTC()
+		if key != "" {
+			if k, ok := boltGetKey(tx, key); ok && k.live(now) {
+				if found, err := boltGetTask(tx, k.TaskID); err == nil {
+					existing = found
+					return nil
+				}
+			}
+		}
+
+		if t.ID == "" {
+			t.ID = uuid.NewString()
+		}
+		t.CreatedAt, t.UpdatedAt = now, now
+		t.Version = 1
+		t.IdempotencyKey = key
+		if err := boltPutTask(tx, t, nil); err != nil {
+			return err
+		}
+		if key == "" {
+			return nil
+		}
+		k := boltKey{TaskID: t.ID}
+		if b.idemTTL > 0 {
+			k.Expires = now.Add(b.idemTTL)
+		}
+		data, err := json.Marshal(k)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(boltIdem).Put([]byte(key), data)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if existing != nil {
+		return existing, true, nil
+	}
+	return clone(t), false, nil
+}
+
+func (b *BoltStore) Get(ctx context.Context, id string) (*models.Task, error) {
+	var t *models.Task
+	err := b.db.View(func(tx *bolt.Tx) error {
+		var err error
+		t, err = boltGetTask(tx, id)
+		return err
+	})
+	return t, err
+}
+
+// Update runs in a bolt write transaction, and those never run concurrently.
+func (b *BoltStore) Update(ctx context.Context, id string, version int64, fn func(t *models.Task) error) (*models.Task, error) {
+	var out *models.Task
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		prev, err := boltGetTask(tx, id)
+		if err != nil {
+			return err
+		}
+		if version != 0 && prev.Version != version {
+			return ErrVersionMismatch
+		}
+		t := clone(prev)
+		if err := fn(t); err != nil {
+			return err
+		}
+		touch(t)
+		if err := boltPutTask(tx, t, prev); err != nil {
+			return err
+		}
+		out = t
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (b *BoltStore) UpdateStatus(ctx context.Context, id string, status models.Status, result map[string]any) error {
+	_, err := b.Update(ctx, id, 0, func(t *models.Task) error {
+		if err := t.Transition(status); err != nil {
+			return err
+		}
+		if result != nil {
+			t.Result = result
+		}
+		return nil
+	})
+	return err
+}
+
+func (b *BoltStore) RecordAttempt(ctx context.Context, id string, a models.Attempt) error {
+	_, err := b.Update(ctx, id, 0, func(t *models.Task) error {
+		t.AddAttempt(a)
+		return nil
+	})
+	return err
+}
+
+func (b *BoltStore) Delete(ctx context.Context, id string, version int64) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		t, err := boltGetTask(tx, id)
+		if err != nil {
+			return err
+		}
+		if version != 0 && t.Version != version {
+			return ErrVersionMismatch
+		}
+		return boltDeleteTask(tx, t)
+	})
+}
+
+func (b *BoltStore) Expire(ctx context.Context, status models.Status, cutoff time.Time, limit int) (int, error) {
+	n := 0
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		var expired []*models.Task
+		err := tx.Bucket(boltTasks).ForEach(func(_, data []byte) error {
+			if limit > 0 && len(expired) == limit {
+				return nil
+			}
+			var t models.Task
+			if err := decodeTask(data, &t); err != nil {
+				return err
+			}
+			if t.Status == status && t.UpdatedAt.Before(cutoff) {
+				expired = append(expired, &t)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, t := range expired {
+			if err := boltDeleteTask(tx, t); err != nil {
+				return err
+			}
+		}
+		n = len(expired)
+
+		// expired idempotency keys are only skipped on create; drop them here
+		now := time.Now()
+		var stale [][]byte
+		tx.Bucket(boltIdem).ForEach(func(key, data []byte) error {
+			var k boltKey
+			if json.Unmarshal(data, &k) == nil && !k.live(now) {
+				stale = append(stale, append([]byte(nil), key...))
+			}
+			return nil
+		})
+		for _, key := range stale {
+			if err := tx.Bucket(boltIdem).Delete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return n, err
+}
+
+// ClaimDue walks the scheduled index, which is ordered by RunAt.
+func (b *BoltStore) ClaimDue(ctx context.Context, now time.Time, limit int) ([]*models.Task, error) {
+	var claimed []*models.Task
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		end := make([]byte, 8)
+		binary.BigEndian.PutUint64(end, uint64(now.UnixNano()))
+		var ids []string
+		c := tx.Bucket(boltScheduled).Cursor()
+		for k, _ := c.First(); k != nil && bytes.Compare(k[:8], end) <= 0; k, _ = c.Next() {
+			if limit > 0 && len(ids) == limit {
+				break
+			}
+			ids = append(ids, string(k[8:]))
+		}
+		for _, id := range ids {
+			prev, err := boltGetTask(tx, id)
+			if err != nil {
+				return err
+			}
+			t := clone(prev)
+			if err := t.Transition(models.StatusQueued); err != nil {
+				return err
+			}
+			touch(t)
+			if err := boltPutTask(tx, t, prev); err != nil {
+				return err
+			}
+			claimed = append(claimed, t)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return claimed, nil
+}
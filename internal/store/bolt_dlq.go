@@ -0,0 +1,43 @@
+package store
+
+import (
+	"context"
+
+	"github.com/husainaj20/task-manager-api/internal/models"
+	bolt "go.etcd.io/bbolt"
+)
+
+func (b *BoltStore) ListDead(ctx context.Context) ([]*models.Task, error) {
+	page, err := b.ListTasks(ctx, ListOptions{Statuses: []models.Status{models.StatusDead}, SortBy: SortByUpdated})
+	if err != nil {
+		return nil, err
+	}
+	return page.Tasks, nil
+}
+
+func (b *BoltStore) PurgeDead(ctx context.Context, ids []string) (int, error) {
+	n := 0
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		for _, id := range ids {
+			t, err := boltGetTask(tx, id)
+			if err == ErrNotFound {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if t.Status != models.StatusDead {
+				continue
+			}
+			if err := boltDeleteTask(tx, t); err != nil {
+				return err
+			}
+			n++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
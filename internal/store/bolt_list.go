@@ -0,0 +1,33 @@
+package store
+
+import (
+	"context"
+
+	"github.com/husainaj20/task-manager-api/internal/models"
+	bolt "go.etcd.io/bbolt"
+)
+
+// ListTasks scans every task; embedded installs hold few enough for that.
+func (b *BoltStore) ListTasks(ctx context.Context, opts ListOptions) (*TaskPage, error) {
+	cur, err := decodeCursor(opts.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	matched := []*models.Task{}
+	err = b.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltTasks).ForEach(func(_, data []byte) error {
+			var t models.Task
+			if err := decodeTask(data, &t); err != nil {
+				return err
+			}
+			if opts.match(&t) && cur.after(opts.key(&t), t.ID, opts.Desc) {
+				matched = append(matched, &t)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return opts.page(matched), nil
+}
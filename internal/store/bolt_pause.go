@@ -0,0 +1,48 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/husainaj20/task-manager-api/internal/models"
+	bolt "go.etcd.io/bbolt"
+)
+
+func (b *BoltStore) AddPause(ctx context.Context, p models.Pause) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		key := []byte(string(p.Scope) + ":" + p.Name)
+		if tx.Bucket(boltPauses).Get(key) != nil {
+			return nil
+		}
+		return tx.Bucket(boltPauses).Put(key, data)
+	})
+}
+
+func (b *BoltStore) RemovePause(ctx context.Context, scope models.PauseScope, name string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltPauses).Delete([]byte(string(scope) + ":" + name))
+	})
+}
+
+func (b *BoltStore) ListPauses(ctx context.Context) ([]models.Pause, error) {
+	out := []models.Pause{}
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltPauses).ForEach(func(_, data []byte) error {
+			var p models.Pause
+			if err := json.Unmarshal(data, &p); err != nil {
+				return err
+			}
+			out = append(out, p)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortPauses(out)
+	return out, nil
+}
@@ -0,0 +1,121 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/husainaj20/task-manager-api/internal/models"
+	bolt "go.etcd.io/bbolt"
+)
+
+func boltGetSchedule(tx *bolt.Tx, id string) (*models.Schedule, error) {
+	data := tx.Bucket(boltSchedules).Get([]byte(id))
+	if data == nil {
+		return nil, ErrScheduleNotFound
+	}
+	var s models.Schedule
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func boltPutSchedule(tx *bolt.Tx, s *models.Schedule) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(boltSchedules).Put([]byte(s.ID), data)
+}
+
+func (b *BoltStore) CreateSchedule(ctx context.Context, s *models.Schedule) (*models.Schedule, error) {
+	if s.ID == "" {
+		s.ID = uuid.NewString()
+	}
+	now := time.Now().UTC()
+	s.CreatedAt, s.UpdatedAt = now, now
+	if err := b.db.Update(func(tx *bolt.Tx) error { return boltPutSchedule(tx, s) }); err != nil {
+		return nil, err
+	}
+	return cloneSchedule(s), nil
+}
+
+func (b *BoltStore) GetSchedule(ctx context.Context, id string) (*models.Schedule, error) {
+	var s *models.Schedule
+	err := b.db.View(func(tx *bolt.Tx) error {
+		var err error
+		s, err = boltGetSchedule(tx, id)
+		return err
+	})
+	return s, err
+}
+
+func (b *BoltStore) ListSchedules(ctx context.Context) ([]*models.Schedule, error) {
+	out := []*models.Schedule{}
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltSchedules).ForEach(func(_, data []byte) error {
+			var s models.Schedule
+			if err := json.Unmarshal(data, &s); err != nil {
+				return err
+			}
+			out = append(out, &s)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out, nil
+}
+
+func (b *BoltStore) SetSchedulePaused(ctx context.Context, id string, paused bool, next time.Time) (*models.Schedule, error) {
+	var out *models.Schedule
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		s, err := boltGetSchedule(tx, id)
+		if err != nil {
+			return err
+		}
+		s.Paused = paused
+		s.NextRunAt = next
+		s.UpdatedAt = time.Now().UTC()
+		out = s
+		return boltPutSchedule(tx, s)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (b *BoltStore) DeleteSchedule(ctx context.Context, id string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		if _, err := boltGetSchedule(tx, id); err != nil {
+			return err
+		}
+		return tx.Bucket(boltSchedules).Delete([]byte(id))
+	})
+}
+
+func (b *BoltStore) AdvanceSchedule(ctx context.Context, id string, from, next time.Time) (bool, error) {
+	advanced := false
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		s, err := boltGetSchedule(tx, id)
+		if err != nil {
+			return err
+		}
+		if s.Paused || !s.NextRunAt.Equal(from) {
+			return nil
+		}
+		last := from
+		s.LastRunAt = &last
+		s.NextRunAt = next
+		s.UpdatedAt = time.Now().UTC()
+		advanced = true
+		return boltPutSchedule(tx, s)
+	})
+	return advanced, err
+}
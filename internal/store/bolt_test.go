@@ -0,0 +1,42 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/husainaj20/task-manager-api/internal/models"
+)
+
+func TestBoltStore_SurvivesReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.db")
+	bs, err := NewBoltStore(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	ctx := context.Background()
+	past := time.Now().Add(-time.Minute)
+	due, _, _ := bs.CreateOrGetByKey(ctx, "k1", &models.Task{Type: "echo", Status: models.StatusScheduled, RunAt: &past})
+	sc, _ := bs.CreateSchedule(ctx, &models.Schedule{Cron: "* * * * *", TaskType: "echo", NextRunAt: past})
+	bs.Close()
+
+	bs, err = NewBoltStore(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer bs.Close()
+	if got, existed, _ := bs.CreateOrGetByKey(ctx, "k1", &models.Task{Type: "echo", Status: models.StatusQueued}); !existed || got.ID != due.ID {
+		t.Fatalf("expected idempotency key to survive a restart")
+	}
+	if _, err := bs.GetSchedule(ctx, sc.ID); err != nil {
+		t.Fatalf("expected schedule to survive a restart: %v", err)
+	}
+	claimed, err := bs.ClaimDue(ctx, time.Now(), 10)
+	if err != nil || len(claimed) != 1 || claimed[0].ID != due.ID || claimed[0].Status != models.StatusQueued {
+		t.Fatalf("expected the due task claimed after a restart, got %+v (%v)", claimed, err)
+	}
+	if again, _ := bs.ClaimDue(ctx, time.Now(), 10); len(again) != 0 {
+		t.Fatalf("expected due task to be claimed only once, got %d", len(again))
+	}
+}
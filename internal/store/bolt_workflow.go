@@ -0,0 +1,42 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/husainaj20/task-manager-api/internal/models"
+	bolt "go.etcd.io/bbolt"
+)
+
+func (b *BoltStore) CreateWorkflow(ctx context.Context, w *models.Workflow, tasks []*models.Task) (*models.Workflow, error) {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		newWorkflow(w, tasks, time.Now().UTC())
+		for _, t := range tasks {
+			if err := boltPutTask(tx, t, nil); err != nil {
+				return err
+			}
+		}
+		data, err := json.Marshal(w)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(boltWorkflows).Put([]byte(w.ID), data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cloneWorkflow(w), nil
+}
+
+func (b *BoltStore) GetWorkflow(ctx context.Context, id string) (*models.Workflow, error) {
+	var w *models.Workflow
+	err := b.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(boltWorkflows).Get([]byte(id))
+		if data == nil {
+			return ErrWorkflowNotFound
+		}
+		return json.Unmarshal(data, &w)
+	})
+	return w, err
+}
@@ -0,0 +1,40 @@
+package store_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	miniredis "github.com/alicebob/miniredis/v2"
+	"github.com/husainaj20/task-manager-api/internal/store"
+	"github.com/husainaj20/task-manager-api/internal/store/storetest"
+)
+
+func TestMemoryStore_Conformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store { return store.NewMemoryStore() })
+}
+
+func TestRedisStore_Conformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		mr, err := miniredis.Run()
+		if err != nil {
+			t.Fatalf("failed to start miniredis: %v", err)
+		}
+		t.Cleanup(mr.Close)
+		return store.NewRedisStore(mr.Addr(), "test")
+	})
+}
+
+func TestBoltStore_Conformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		bs, err := store.NewBoltStore(filepath.Join(t.TempDir(), "tasks.db"))
+		if err != nil {
+			t.Fatalf("open: %v", err)
+		}
+		t.Cleanup(func() { bs.Close() })
+		return bs
+	})
+}
+
+func TestPostgresStore_Conformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store { return store.NewTestPostgres(t) })
+}
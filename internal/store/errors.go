@@ -0,0 +1,32 @@
+package store
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+)
+
+// unavailable wraps err in ErrUnavailable if it is a connection failure, and
+// returns any other error as is.
+func unavailable(err error) error {
+	if err == nil || errors.Is(err, ErrUnavailable) || !connError(err) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrUnavailable, err)
+}
+
+// connError reports whether err says the backend could not be reached or
+// dropped the connection.
+func connError(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE)
+}
@@ -0,0 +1,5 @@
+package store
+
+// NewTestPostgres lets the conformance tests in package store_test share the
+// connection and reset logic.
+var NewTestPostgres = newTestPostgres
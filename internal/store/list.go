@@ -0,0 +1,152 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/husainaj20/task-manager-api/internal/models"
+)
+
+// ErrInvalidCursor is returned by ListTasks for a cursor it did not issue.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// SortField is the task timestamp a listing is ordered by.
+type SortField string
+
+const (
+	SortByCreated SortField = "createdAt"
+	SortByUpdated SortField = "updatedAt"
+)
+
+// ListOptions filters and orders a task listing. Zero values match
+// everything; time ranges include After and exclude Before.
+type ListOptions struct {
+	Statuses []models.Status
+	Type     string
+	// Labels must all be present in the task metadata with equal values.
+	Labels map[string]string
+
+	CreatedAfter, CreatedBefore time.Time
+	UpdatedAfter, UpdatedBefore time.Time
+
+	SortBy SortField // defaults to SortByCreated
+	Desc   bool
+	Limit  int
+	// Cursor continues a previous listing with the same options.
+	Cursor string
+}
+
+// TaskPage is one page of a listing. NextCursor is empty on the last page.
+type TaskPage struct {
+	Tasks      []*models.Task `json:"tasks"`
+	NextCursor string         `json:"nextCursor,omitempty"`
+}
+
+func (o ListOptions) sortBy() SortField {
+	if o.SortBy == SortByUpdated {
+		return SortByUpdated
+	}
+	return SortByCreated
+}
+
+func (o ListOptions) sortTime(t *models.Task) time.Time {
+	return sortTime(o.sortBy(), t)
+}
+
+// key is the sort key of t that cursors record.
+func (o ListOptions) key(t *models.Task) int64 { return o.sortTime(t).UnixNano() }
+
+// page orders tasks, already filtered by match and the cursor, and cuts the
+// first page from them. Stores without indexes list through it.
+func (o ListOptions) page(tasks []*models.Task) *TaskPage {
+	sort.Slice(tasks, func(i, j int) bool {
+		ki, kj := o.key(tasks[i]), o.key(tasks[j])
+		if ki != kj {
+			return (ki < kj) != o.Desc
+		}
+		return (tasks[i].ID < tasks[j].ID) != o.Desc
+	})
+	page := &TaskPage{Tasks: tasks}
+	if o.Limit > 0 && len(tasks) > o.Limit {
+		page.Tasks = tasks[:o.Limit]
+		last := page.Tasks[o.Limit-1]
+		page.NextCursor = encodeCursor(o.key(last), last.ID)
+	}
+	return page
+}
+
+func sortTime(f SortField, t *models.Task) time.Time {
+	if f == SortByUpdated {
+		return t.UpdatedAt
+	}
+	return t.CreatedAt
+}
+
+func (o ListOptions) match(t *models.Task) bool {
+	if len(o.Statuses) > 0 {
+		found := false
+		for _, s := range o.Statuses {
+			if t.Status == s {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if o.Type != "" && t.Type != o.Type {
+		return false
+	}
+	for k, v := range o.Labels {
+		if got, ok := t.Metadata[k]; !ok || got != v {
+			return false
+		}
+	}
+	return inRange(t.CreatedAt, o.CreatedAfter, o.CreatedBefore) &&
+		inRange(t.UpdatedAt, o.UpdatedAfter, o.UpdatedBefore)
+}
+
+func inRange(t, after, before time.Time) bool {
+	return (after.IsZero() || !t.Before(after)) && (before.IsZero() || t.Before(before))
+}
+
+// cursor is the position of the last task on a page: its sort key and ID.
+type cursor struct {
+	Key int64  `json:"k"`
+	ID  string `json:"id"`
+}
+
+func encodeCursor(key int64, id string) string {
+	b, _ := json.Marshal(cursor{Key: key, ID: id})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (*cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(b, &c); err != nil || c.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// after reports whether (key, id) comes after the cursor in listing order.
+func (c *cursor) after(key int64, id string, desc bool) bool {
+	if c == nil {
+		return true
+	}
+	if desc {
+		return key < c.Key || (key == c.Key && id < c.ID)
+	}
+	return key > c.Key || (key == c.Key && id > c.ID)
+}
@@ -2,7 +2,7 @@ package store
 
 import (
 	"context"
-	"errors"
+	"sort"
 	"sync"
 	"time"
 
@@ -10,30 +10,56 @@ import (
 	"github.com/husainaj20/task-manager-api/internal/models"
 )
 
-var (
-	errNotFound = errors.New("task not found")
-)
-
 type MemoryStore struct {
 	mu        sync.RWMutex
 	tasks     map[string]*models.Task
-	idemIndex map[string]string // idempotency key -> taskID
+	idemIndex map[string]idemEntry // idempotency key -> task
+	idemTTL   time.Duration
+	schedules map[string]*models.Schedule
+	pauses    map[pauseKey]models.Pause
+	workflows map[string]*models.Workflow
+}
+
+type pauseKey struct {
+	scope models.PauseScope
+	name  string
+}
+
+type idemEntry struct {
+	taskID  string
+	expires time.Time // zero: never
+}
+
+func (e idemEntry) live(now time.Time) bool {
+	return e.expires.IsZero() || now.Before(e.expires)
 }
 
 func NewMemoryStore() *MemoryStore {
 	return &MemoryStore{
 		tasks:     make(map[string]*models.Task),
-		idemIndex: make(map[string]string),
+		idemIndex: make(map[string]idemEntry),
+		schedules: make(map[string]*models.Schedule),
+		pauses:    make(map[pauseKey]models.Pause),
+		workflows: make(map[string]*models.Workflow),
 	}
 }
 
+// SetIdempotencyTTL sets how long an idempotency key keeps pointing at its
+// task. Zero, the default, keeps keys until the task is deleted.
+func (m *MemoryStore) SetIdempotencyTTL(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.idemTTL = d
+}
+
 func (m *MemoryStore) CreateOrGetByKey(ctx context.Context, key string, t *models.Task) (*models.Task, bool, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	now := time.Now().UTC()
 	if key != "" {
-		if id, ok := m.idemIndex[key]; ok {
-			if existing, ok := m.tasks[id]; ok {
+		if e, ok := m.idemIndex[key]; ok && e.live(now) {
+			if existing, ok := m.tasks[e.taskID]; ok {
 				return clone(existing), true, nil
 			}
 		}
@@ -42,11 +68,16 @@ func (m *MemoryStore) CreateOrGetByKey(ctx context.Context, key string, t *model
 	if t.ID == "" {
 		t.ID = uuid.NewString()
 	}
-	now := time.Now().UTC()
 	t.CreatedAt, t.UpdatedAt = now, now
+	t.Version = 1
+	t.IdempotencyKey = key
 	m.tasks[t.ID] = clone(t)
 	if key != "" {
-		m.idemIndex[key] = t.ID
+		e := idemEntry{taskID: t.ID}
+		if m.idemTTL > 0 {
+			e.expires = now.Add(m.idemTTL)
+		}
+		m.idemIndex[key] = e
 	}
 	return clone(t), false, nil
 }
@@ -57,28 +88,184 @@ func (m *MemoryStore) Get(ctx context.Context, id string) (*models.Task, error)
 	if t, ok := m.tasks[id]; ok {
 		return clone(t), nil
 	}
-	return nil, errNotFound
+	return nil, ErrNotFound
+}
+
+// Update applies fn to a copy of the task, so a failing fn leaves it as it was.
+func (m *MemoryStore) Update(ctx context.Context, id string, version int64, fn func(t *models.Task) error) (*models.Task, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cur, ok := m.tasks[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if version != 0 && cur.Version != version {
+		return nil, ErrVersionMismatch
+	}
+	t := clone(cur)
+	if err := fn(t); err != nil {
+		return nil, err
+	}
+	touch(t)
+	m.tasks[id] = t
+	return clone(t), nil
+}
+
+func (m *MemoryStore) UpdateStatus(ctx context.Context, id string, status models.Status, result map[string]any) error {
+	_, err := m.Update(ctx, id, 0, func(t *models.Task) error {
+		if err := t.Transition(status); err != nil {
+			return err
+		}
+		if result != nil {
+			t.Result = result
+		}
+		return nil
+	})
+	return err
 }
 
-func (m *MemoryStore) UpdateStatus(ctx context.Context, id string, status string, result map[string]any) error {
+func (m *MemoryStore) RecordAttempt(ctx context.Context, id string, a models.Attempt) error {
+	_, err := m.Update(ctx, id, 0, func(t *models.Task) error {
+		t.AddAttempt(a)
+		return nil
+	})
+	return err
+}
+
+func (m *MemoryStore) Delete(ctx context.Context, id string, version int64) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	t, ok := m.tasks[id]
 	if !ok {
-		return errNotFound
+		return ErrNotFound
 	}
-	t.Status = status
-	if result != nil {
-		t.Result = result
+	if version != 0 && t.Version != version {
+		return ErrVersionMismatch
 	}
-	t.UpdatedAt = time.Now().UTC()
+	m.deleteLocked(t)
 	return nil
 }
 
+func (m *MemoryStore) Expire(ctx context.Context, status models.Status, cutoff time.Time, limit int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for _, t := range m.tasks {
+		if limit > 0 && n == limit {
+			break
+		}
+		if t.Status == status && t.UpdatedAt.Before(cutoff) {
+			m.deleteLocked(t)
+			n++
+		}
+	}
+	// expired idempotency keys are only skipped on lookup; drop them here
+	now := time.Now()
+	for key, e := range m.idemIndex {
+		if !e.live(now) {
+			delete(m.idemIndex, key)
+		}
+	}
+	return n, nil
+}
+
+// deleteLocked removes t, its idempotency key, and its workflow once that has
+// no tasks left; m.mu must be held.
+func (m *MemoryStore) deleteLocked(t *models.Task) {
+	delete(m.tasks, t.ID)
+	if t.IdempotencyKey != "" && m.idemIndex[t.IdempotencyKey].taskID == t.ID {
+		delete(m.idemIndex, t.IdempotencyKey)
+	}
+	if w, ok := m.workflows[t.WorkflowID]; ok {
+		for _, id := range w.Tasks {
+			if _, ok := m.tasks[id]; ok {
+				return
+			}
+		}
+		delete(m.workflows, w.ID)
+	}
+}
+
+func (m *MemoryStore) ClaimDue(ctx context.Context, now time.Time, limit int) ([]*models.Task, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var due []*models.Task
+	for _, t := range m.tasks {
+		if t.Status != models.StatusScheduled || t.RunAt == nil || t.RunAt.After(now) {
+			continue
+		}
+		due = append(due, t)
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].RunAt.Before(*due[j].RunAt) })
+	if limit > 0 && len(due) > limit {
+		due = due[:limit]
+	}
+	claimed := make([]*models.Task, 0, len(due))
+	for _, t := range due {
+		if err := t.Transition(models.StatusQueued); err != nil {
+			return nil, err
+		}
+		touch(t)
+		claimed = append(claimed, clone(t))
+	}
+	return claimed, nil
+}
+
 func clone(t *models.Task) *models.Task {
 	if t == nil {
 		return nil
 	}
 	c := *t
+	c.Payload = copyMap(t.Payload)
+	c.Result = copyMap(t.Result)
+	if t.Metadata != nil {
+		c.Metadata = make(map[string]string, len(t.Metadata))
+		for k, v := range t.Metadata {
+			c.Metadata[k] = v
+		}
+	}
+	if t.History != nil {
+		c.History = append([]models.Attempt(nil), t.History...)
+	}
+	if t.RunAt != nil {
+		runAt := *t.RunAt
+		c.RunAt = &runAt
+	}
+	if t.DependsOn != nil {
+		c.DependsOn = append([]string(nil), t.DependsOn...)
+	}
+	if t.Awaiting != nil {
+		c.Awaiting = append([]string(nil), t.Awaiting...)
+	}
+	if t.Dependents != nil {
+		c.Dependents = append([]string(nil), t.Dependents...)
+	}
 	return &c
 }
+
+// copyMap deep-copies the JSON-like values held in task payloads and results.
+func copyMap(m map[string]any) map[string]any {
+	if m == nil {
+		return nil
+	}
+	c := make(map[string]any, len(m))
+	for k, v := range m {
+		c[k] = copyValue(v)
+	}
+	return c
+}
+
+func copyValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		return copyMap(v)
+	case []any:
+		c := make([]any, len(v))
+		for i, e := range v {
+			c[i] = copyValue(e)
+		}
+		return c
+	default:
+		return v
+	}
+}
@@ -0,0 +1,34 @@
+package store
+
+import (
+	"context"
+	"sort"
+
+	"github.com/husainaj20/task-manager-api/internal/models"
+)
+
+func (m *MemoryStore) ListDead(ctx context.Context) ([]*models.Task, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var dead []*models.Task
+	for _, t := range m.tasks {
+		if t.Status == models.StatusDead {
+			dead = append(dead, clone(t))
+		}
+	}
+	sort.Slice(dead, func(i, j int) bool { return dead[i].UpdatedAt.Before(dead[j].UpdatedAt) })
+	return dead, nil
+}
+
+func (m *MemoryStore) PurgeDead(ctx context.Context, ids []string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	purged := 0
+	for _, id := range ids {
+		if t, ok := m.tasks[id]; ok && t.Status == models.StatusDead {
+			m.deleteLocked(t)
+			purged++
+		}
+	}
+	return purged, nil
+}
@@ -0,0 +1,24 @@
+package store
+
+import (
+	"context"
+
+	"github.com/husainaj20/task-manager-api/internal/models"
+)
+
+func (m *MemoryStore) ListTasks(ctx context.Context, opts ListOptions) (*TaskPage, error) {
+	cur, err := decodeCursor(opts.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	matched := []*models.Task{}
+	for _, t := range m.tasks {
+		if opts.match(t) && cur.after(opts.key(t), t.ID, opts.Desc) {
+			matched = append(matched, clone(t))
+		}
+	}
+	m.mu.RUnlock()
+	return opts.page(matched), nil
+}
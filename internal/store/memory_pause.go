@@ -0,0 +1,35 @@
+package store
+
+import (
+	"context"
+
+	"github.com/husainaj20/task-manager-api/internal/models"
+)
+
+func (m *MemoryStore) AddPause(ctx context.Context, p models.Pause) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k := pauseKey{p.Scope, p.Name}
+	if _, ok := m.pauses[k]; !ok {
+		m.pauses[k] = p
+	}
+	return nil
+}
+
+func (m *MemoryStore) RemovePause(ctx context.Context, scope models.PauseScope, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.pauses, pauseKey{scope, name})
+	return nil
+}
+
+func (m *MemoryStore) ListPauses(ctx context.Context) ([]models.Pause, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make([]models.Pause, 0, len(m.pauses))
+	for _, p := range m.pauses {
+		out = append(out, p)
+	}
+	sortPauses(out)
+	return out, nil
+}
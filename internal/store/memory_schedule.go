@@ -0,0 +1,94 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/husainaj20/task-manager-api/internal/models"
+)
+
+func (m *MemoryStore) CreateSchedule(ctx context.Context, s *models.Schedule) (*models.Schedule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s.ID == "" {
+		s.ID = uuid.NewString()
+	}
+	now := time.Now().UTC()
+	s.CreatedAt, s.UpdatedAt = now, now
+	m.schedules[s.ID] = cloneSchedule(s)
+	return cloneSchedule(s), nil
+}
+
+func (m *MemoryStore) GetSchedule(ctx context.Context, id string) (*models.Schedule, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if s, ok := m.schedules[id]; ok {
+		return cloneSchedule(s), nil
+	}
+	return nil, ErrScheduleNotFound
+}
+
+func (m *MemoryStore) ListSchedules(ctx context.Context) ([]*models.Schedule, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make([]*models.Schedule, 0, len(m.schedules))
+	for _, s := range m.schedules {
+		out = append(out, cloneSchedule(s))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out, nil
+}
+
+func (m *MemoryStore) SetSchedulePaused(ctx context.Context, id string, paused bool, next time.Time) (*models.Schedule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.schedules[id]
+	if !ok {
+		return nil, ErrScheduleNotFound
+	}
+	s.Paused = paused
+	s.NextRunAt = next
+	s.UpdatedAt = time.Now().UTC()
+	return cloneSchedule(s), nil
+}
+
+func (m *MemoryStore) DeleteSchedule(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.schedules[id]; !ok {
+		return ErrScheduleNotFound
+	}
+	delete(m.schedules, id)
+	return nil
+}
+
+func (m *MemoryStore) AdvanceSchedule(ctx context.Context, id string, from, next time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.schedules[id]
+	if !ok {
+		return false, ErrScheduleNotFound
+	}
+	if s.Paused || !s.NextRunAt.Equal(from) {
+		return false, nil
+	}
+	last := from
+	s.LastRunAt = &last
+	s.NextRunAt = next
+	s.UpdatedAt = time.Now().UTC()
+	return true, nil
+}
+
+func cloneSchedule(s *models.Schedule) *models.Schedule {
+	if s == nil {
+		return nil
+	}
+	c := *s
+	if s.LastRunAt != nil {
+		last := *s.LastRunAt
+		c.LastRunAt = &last
+	}
+	return &c
+}
@@ -1,56 +1,30 @@
 package store
 
 import (
-    "context"
-    "sync"
-    "testing"
+	"context"
+	"testing"
+	"time"
 
-    "github.com/husainaj20/task-manager-api/internal/models"
+	"github.com/husainaj20/task-manager-api/internal/models"
 )
 
-func TestMemoryStore_CreateOrGetByKey_Concurrent(t *testing.T) {
-    ms := NewMemoryStore()
-    key := "concurrent-key"
-    var wg sync.WaitGroup
-    ids := make([]string, 10)
-    wg.Add(10)
-    for i := 0; i < 10; i++ {
-        idx := i
-        go func() {
-            defer wg.Done()
-            task := &models.Task{Type: "echo", Payload: map[string]any{"i": idx}}
-            got, _, _ := ms.CreateOrGetByKey(context.Background(), key, task)
-            ids[idx] = got.ID
-        }()
-    }
-    wg.Wait()
-    // All ids should be the same non-empty string
-    if ids[0] == "" {
-        t.Fatalf("expected non-empty id")
-    }
-    for i := 1; i < len(ids); i++ {
-        if ids[i] != ids[0] {
-            t.Fatalf("expected same id for all concurrent creates, got %s and %s", ids[0], ids[i])
-        }
-    }
-}
-
-func TestMemoryStore_UpdateStatus(t *testing.T) {
-    ms := NewMemoryStore()
-    task := &models.Task{Type: "echo", Payload: map[string]any{"x": 1}}
-    created, _, _ := ms.CreateOrGetByKey(context.Background(), "k1", task)
-    err := ms.UpdateStatus(context.Background(), created.ID, "done", map[string]any{"echo": map[string]any{"x": 1}})
-    if err != nil {
-        t.Fatalf("unexpected error: %v", err)
-    }
-    fetched, err := ms.Get(context.Background(), created.ID)
-    if err != nil {
-        t.Fatalf("get failed: %v", err)
-    }
-    if fetched.Status != "done" {
-        t.Fatalf("expected status done, got %s", fetched.Status)
-    }
-    if fetched.Result == nil {
-        t.Fatalf("expected result to be set")
-    }
+func TestMemoryStore_IdempotencyTTL(t *testing.T) {
+	ms := NewMemoryStore()
+	ms.SetIdempotencyTTL(20 * time.Millisecond)
+	ctx := context.Background()
+	first, _, _ := ms.CreateOrGetByKey(ctx, "k", &models.Task{Type: "echo", Status: models.StatusQueued})
+	if _, existed, _ := ms.CreateOrGetByKey(ctx, "k", &models.Task{Type: "echo", Status: models.StatusQueued}); !existed {
+		t.Fatalf("expected key to be live within its TTL")
+	}
+	time.Sleep(30 * time.Millisecond)
+	second, existed, _ := ms.CreateOrGetByKey(ctx, "k", &models.Task{Type: "echo", Status: models.StatusQueued})
+	if existed || second.ID == first.ID {
+		t.Fatalf("expected a new task once the key expired")
+	}
+	// deleting the first task must not release the key now held by the second
+	ms.UpdateStatus(ctx, first.ID, models.StatusCancelled, nil)
+	ms.Delete(ctx, first.ID, 0)
+	if got, existed, _ := ms.CreateOrGetByKey(ctx, "k", &models.Task{Type: "echo", Status: models.StatusQueued}); !existed || got.ID != second.ID {
+		t.Fatalf("expected key to still map to the second task")
+	}
 }
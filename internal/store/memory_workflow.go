@@ -0,0 +1,28 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/husainaj20/task-manager-api/internal/models"
+)
+
+func (m *MemoryStore) CreateWorkflow(ctx context.Context, w *models.Workflow, tasks []*models.Task) (*models.Workflow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	newWorkflow(w, tasks, time.Now().UTC())
+	for _, t := range tasks {
+		m.tasks[t.ID] = clone(t)
+	}
+	m.workflows[w.ID] = cloneWorkflow(w)
+	return cloneWorkflow(w), nil
+}
+
+func (m *MemoryStore) GetWorkflow(ctx context.Context, id string) (*models.Workflow, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if w, ok := m.workflows[id]; ok {
+		return cloneWorkflow(w), nil
+	}
+	return nil, ErrWorkflowNotFound
+}
@@ -0,0 +1,34 @@
+-- Tasks keep their full JSON document in data; the columns copy the fields
+-- listings, the promoter and the janitor filter and sort on.
+CREATE TABLE tasks (
+    id              text COLLATE "C" PRIMARY KEY,
+    type            text NOT NULL,
+    status          text NOT NULL,
+    run_at          timestamptz,
+    created_at      timestamptz NOT NULL,
+    updated_at      timestamptz NOT NULL,
+    version         bigint NOT NULL,
+    idempotency_key text NOT NULL DEFAULT '',
+    data            jsonb NOT NULL
+);
+
+CREATE INDEX tasks_created_at_idx ON tasks (created_at, id);
+CREATE INDEX tasks_updated_at_idx ON tasks (updated_at, id);
+CREATE INDEX tasks_status_idx ON tasks (status, updated_at);
+CREATE INDEX tasks_type_idx ON tasks (type, created_at);
+CREATE INDEX tasks_due_idx ON tasks (run_at) WHERE status = 'scheduled';
+
+-- expires_at is NULL for keys that live as long as their task.
+CREATE TABLE idempotency_keys (
+    key        text PRIMARY KEY,
+    task_id    text NOT NULL,
+    expires_at timestamptz
+);
+
+CREATE INDEX idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
+
+CREATE TABLE schedules (
+    id         text COLLATE "C" PRIMARY KEY,
+    created_at timestamptz NOT NULL,
+    data       jsonb NOT NULL
+);
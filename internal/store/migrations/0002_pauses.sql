@@ -0,0 +1,7 @@
+-- Paused queues and task types; scope is 'queue' or 'type'.
+CREATE TABLE pauses (
+    scope     text NOT NULL,
+    name      text NOT NULL,
+    paused_at timestamptz NOT NULL,
+    PRIMARY KEY (scope, name)
+);
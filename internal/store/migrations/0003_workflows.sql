@@ -0,0 +1,5 @@
+CREATE TABLE workflows (
+    id         text PRIMARY KEY,
+    created_at timestamptz NOT NULL,
+    data       jsonb NOT NULL
+);
@@ -0,0 +1,373 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/husainaj20/task-manager-api/internal/models"
+	"github.com/lib/pq"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// PostgresStore keeps tasks and schedules in PostgreSQL. Every write runs in a
+// transaction, and rows are locked while they are read-modified-written, so
+// any number of replicas can share a database.
+type PostgresStore struct {
+	db      *sql.DB
+	idemTTL time.Duration
+}
+
+// NewPostgresStore connects to dsn and applies any pending migrations.
+func NewPostgresStore(dsn string) (*PostgresStore, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := migrate(ctx, db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrate: %w", err)
+	}
+	return &PostgresStore{db: db}, nil
+}
+
+// Close closes the connection pool.
+func (p *PostgresStore) Close() error { return p.db.Close() }
+
+// migrate applies the embedded migrations that schema_migrations does not list
+// yet, in name order and in a single transaction. The advisory lock keeps
+// replicas starting together from running them twice.
+func migrate(ctx context.Context, db *sql.DB) error {
+	names, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+	return inTx(ctx, db, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(4242001)`); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    text PRIMARY KEY,
+			applied_at timestamptz NOT NULL DEFAULT now()
+		)`); err != nil {
+			return err
+		}
+		for _, name := range names {
+			version := path.Base(name)
+			var applied bool
+			if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`, version).Scan(&applied); err != nil {
+				return err
+			}
+			if applied {
+				continue
+			}
+			script, err := migrations.ReadFile(name)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, string(script)); err != nil {
+				return fmt.Errorf("%s: %w", version, err)
+			}
+			if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, version); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func inTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return pgError(err)
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return pgError(err)
+	}
+	return pgError(tx.Commit())
+}
+
+// pgError reports serialization failures and deadlocks as ErrConflict and
+// lost or refused connections as ErrUnavailable.
+func pgError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch code := pqErr.Code; {
+		case code == "40001" || code == "40P01":
+			return fmt.Errorf("%w: %w", ErrConflict, err)
+		case code.Class() == "08" || code == "53300" || code == "57P01" || code == "57P03":
+			return fmt.Errorf("%w: %w", ErrUnavailable, err)
+		}
+	}
+	return unavailable(err)
+}
+
+// SetIdempotencyTTL sets how long an idempotency key keeps pointing at its
+// task. Zero, the default, keeps keys until the task is deleted.
+func (p *PostgresStore) SetIdempotencyTTL(d time.Duration) { p.idemTTL = d }
+
+const (
+	insertTaskSQL = `INSERT INTO tasks (id, type, status, run_at, created_at, updated_at, version, idempotency_key, data)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+	updateTaskSQL = `UPDATE tasks SET type = $2, status = $3, run_at = $4, created_at = $5, updated_at = $6,
+		version = $7, idempotency_key = $8, data = $9 WHERE id = $1`
+
+	// claimKeySQL points key at a new task unless it is held by a live one,
+	// returning no row in that case. A concurrent claim of the same key
+	// waits for the first to commit.
+	claimKeySQL = `INSERT INTO idempotency_keys (key, task_id, expires_at) VALUES ($1, $2, $3)
+		ON CONFLICT (key) DO UPDATE SET task_id = EXCLUDED.task_id, expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at IS NOT NULL AND idempotency_keys.expires_at <= $4
+		RETURNING task_id`
+)
+
+// deleteTasksSQL deletes the tasks matching where together with the
+// idempotency keys still pointing at them and the workflows left without
+// tasks, and counts the deleted tasks. The statement still sees the deleted
+// tasks in the tasks table, so they are excluded by hand.
+func deleteTasksSQL(where string) string {
+	return `WITH gone AS (
+			DELETE FROM tasks WHERE ` + where + ` RETURNING id, idempotency_key, data->>'workflowId' AS workflow_id
+		), keys AS (
+			DELETE FROM idempotency_keys k USING gone
+			WHERE k.key = gone.idempotency_key AND k.task_id = gone.id
+		), flows AS (
+			DELETE FROM workflows w WHERE w.id IN (SELECT workflow_id FROM gone)
+			AND NOT EXISTS (
+				SELECT 1 FROM jsonb_each_text(w.data->'tasks') wt JOIN tasks t ON t.id = wt.value
+				WHERE t.id NOT IN (SELECT id FROM gone))
+		)
+		SELECT count(*) FROM gone`
+}
+
+// writeTask runs query, insertTaskSQL or updateTaskSQL, with t's columns.
+func writeTask(ctx context.Context, tx *sql.Tx, query string, t *models.Task) error {
+	data, err := encodeTask(t)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, query, t.ID, t.Type, t.Status, t.RunAt, t.CreatedAt, t.UpdatedAt,
+		t.Version, t.IdempotencyKey, string(data))
+	return err
+}
+
+// pgTouch is touch with UpdatedAt cut to the microseconds Postgres stores, so
+// the column and the JSON document agree.
+func pgTouch(t *models.Task) {
+	touch(t)
+	t.UpdatedAt = t.UpdatedAt.Truncate(time.Microsecond)
+}
+
+func scanTask(row *sql.Row) (*models.Task, error) {
+	var data []byte
+	if err := row.Scan(&data); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	var t models.Task
+	if err := decodeTask(data, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func scanTasks(rows *sql.Rows) ([]*models.Task, error) {
+	defer rows.Close()
+	tasks := []*models.Task{}
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var t models.Task
+		if err := decodeTask(data, &t); err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, &t)
+	}
+	return tasks, rows.Err()
+}
+
+// limitArg turns a non-positive limit into NULL, which LIMIT treats as none.
+func limitArg(limit int) any {
+	if limit > 0 {
+		return limit
+	}
+	return nil
+}
+
+func (p *PostgresStore) CreateOrGetByKey(ctx context.Context, key string, t *models.Task) (*models.Task, bool, error) {
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	if t.ID == "" {
+		t.ID = uuid.NewString()
+	}
+	t.CreatedAt, t.UpdatedAt = now, now
+	t.Version = 1
+	t.IdempotencyKey = key
+
+	var existing *models.Task
+	err := inTx(ctx, p.db, func(tx *sql.Tx) error {
+		if key != "" {
+			var expires *time.Time
+			if p.idemTTL > 0 {
+				e := now.Add(p.idemTTL)
+				expires = &e
+			}
+			var owner string
+			err := tx.QueryRowContext(ctx, claimKeySQL, key, t.ID, expires, now).Scan(&owner)
+			if errors.Is(err, sql.ErrNoRows) {
+				existing, err = scanTask(tx.QueryRowContext(ctx,
+					`SELECT data FROM tasks WHERE id = (SELECT task_id FROM idempotency_keys WHERE key = $1)`, key))
+				return err
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return writeTask(ctx, tx, insertTaskSQL, t)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if existing != nil {
+		return existing, true, nil
+	}
+	return clone(t), false, nil
+}
+
+func (p *PostgresStore) Get(ctx context.Context, id string) (*models.Task, error) {
+	t, err := scanTask(p.db.QueryRowContext(ctx, `SELECT data FROM tasks WHERE id = $1`, id))
+	return t, pgError(err)
+}
+
+// Update locks the task row for the duration of the transaction, so
+// concurrent updates of a task are applied one after the other.
+func (p *PostgresStore) Update(ctx context.Context, id string, version int64, fn func(t *models.Task) error) (*models.Task, error) {
+	var out *models.Task
+	err := inTx(ctx, p.db, func(tx *sql.Tx) error {
+		t, err := scanTask(tx.QueryRowContext(ctx, `SELECT data FROM tasks WHERE id = $1 FOR UPDATE`, id))
+		if err != nil {
+			return err
+		}
+		if version != 0 && t.Version != version {
+			return ErrVersionMismatch
+		}
+		if err := fn(t); err != nil {
+			return err
+		}
+		pgTouch(t)
+		if err := writeTask(ctx, tx, updateTaskSQL, t); err != nil {
+			return err
+		}
+		out = t
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (p *PostgresStore) UpdateStatus(ctx context.Context, id string, status models.Status, result map[string]any) error {
+	_, err := p.Update(ctx, id, 0, func(t *models.Task) error {
+		if err := t.Transition(status); err != nil {
+			return err
+		}
+		if result != nil {
+			t.Result = result
+		}
+		return nil
+	})
+	return err
+}
+
+func (p *PostgresStore) RecordAttempt(ctx context.Context, id string, a models.Attempt) error {
+	_, err := p.Update(ctx, id, 0, func(t *models.Task) error {
+		t.AddAttempt(a)
+		return nil
+	})
+	return err
+}
+
+func (p *PostgresStore) Delete(ctx context.Context, id string, version int64) error {
+	return inTx(ctx, p.db, func(tx *sql.Tx) error {
+		var current int64
+		err := tx.QueryRowContext(ctx, `SELECT version FROM tasks WHERE id = $1 FOR UPDATE`, id).Scan(&current)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if version != 0 && current != version {
+			return ErrVersionMismatch
+		}
+		var n int
+		return tx.QueryRowContext(ctx, deleteTasksSQL(`id = $1`), id).Scan(&n)
+	})
+}
+
+func (p *PostgresStore) Expire(ctx context.Context, status models.Status, cutoff time.Time, limit int) (int, error) {
+	var n int
+	err := p.db.QueryRowContext(ctx, deleteTasksSQL(`id IN (
+			SELECT id FROM tasks WHERE status = $1 AND updated_at < $2
+			ORDER BY updated_at LIMIT $3 FOR UPDATE SKIP LOCKED)`), status, cutoff, limitArg(limit)).Scan(&n)
+	if err != nil {
+		return 0, pgError(err)
+	}
+	// expired idempotency keys are only skipped on create; drop them here
+	if _, err := p.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= $1`, time.Now().UTC()); err != nil {
+		return n, pgError(err)
+	}
+	return n, nil
+}
+
+// ClaimDue skips rows locked by other replicas claiming at the same time, so
+// each due task is claimed exactly once.
+func (p *PostgresStore) ClaimDue(ctx context.Context, now time.Time, limit int) ([]*models.Task, error) {
+	var claimed []*models.Task
+	err := inTx(ctx, p.db, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, `SELECT data FROM tasks WHERE status = $1 AND run_at <= $2
+			ORDER BY run_at LIMIT $3 FOR UPDATE SKIP LOCKED`, models.StatusScheduled, now, limitArg(limit))
+		if err != nil {
+			return err
+		}
+		due, err := scanTasks(rows)
+		if err != nil {
+			return err
+		}
+		for _, t := range due {
+			if err := t.Transition(models.StatusQueued); err != nil {
+				return err
+			}
+			pgTouch(t)
+			if err := writeTask(ctx, tx, updateTaskSQL, t); err != nil {
+				return err
+			}
+		}
+		claimed = due
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return claimed, nil
+}
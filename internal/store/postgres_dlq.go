@@ -0,0 +1,23 @@
+package store
+
+import (
+	"context"
+
+	"github.com/husainaj20/task-manager-api/internal/models"
+	"github.com/lib/pq"
+)
+
+func (p *PostgresStore) ListDead(ctx context.Context) ([]*models.Task, error) {
+	rows, err := p.db.QueryContext(ctx, `SELECT data FROM tasks WHERE status = $1 ORDER BY updated_at, id`, models.StatusDead)
+	if err != nil {
+		return nil, pgError(err)
+	}
+	tasks, err := scanTasks(rows)
+	return tasks, pgError(err)
+}
+
+func (p *PostgresStore) PurgeDead(ctx context.Context, ids []string) (int, error) {
+	var n int
+	err := p.db.QueryRowContext(ctx, deleteTasksSQL(`id = ANY($1) AND status = $2`), pq.Array(ids), models.StatusDead).Scan(&n)
+	return n, pgError(err)
+}
@@ -0,0 +1,94 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ListTasks builds a single query from opts. Paging is keyset based on
+// (sort column, id), which the created_at and updated_at indexes cover.
+func (p *PostgresStore) ListTasks(ctx context.Context, opts ListOptions) (*TaskPage, error) {
+	cur, err := decodeCursor(opts.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	col := "created_at"
+	if opts.sortBy() == SortByUpdated {
+		col = "updated_at"
+	}
+
+	var where []string
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	if len(opts.Statuses) > 0 {
+		statuses := make([]string, len(opts.Statuses))
+		for i, s := range opts.Statuses {
+			statuses[i] = string(s)
+		}
+		where = append(where, "status = ANY("+arg(pq.Array(statuses))+")")
+	}
+	if opts.Type != "" {
+		where = append(where, "type = "+arg(opts.Type))
+	}
+	if len(opts.Labels) > 0 {
+		labels, _ := json.Marshal(opts.Labels)
+		where = append(where, "data->'metadata' @> "+arg(string(labels))+"::jsonb")
+	}
+	for _, r := range []struct {
+		col    string
+		bound  time.Time
+		lowest bool
+	}{
+		{"created_at", opts.CreatedAfter, true}, {"created_at", opts.CreatedBefore, false},
+		{"updated_at", opts.UpdatedAfter, true}, {"updated_at", opts.UpdatedBefore, false},
+	} {
+		if r.bound.IsZero() {
+			continue
+		}
+		op := " < "
+		if r.lowest {
+			op = " >= "
+		}
+		where = append(where, r.col+op+arg(r.bound))
+	}
+	dir, op := "ASC", ">"
+	if opts.Desc {
+		dir, op = "DESC", "<"
+	}
+	if cur != nil {
+		where = append(where, "("+col+", id) "+op+" ("+arg(time.Unix(0, cur.Key).UTC())+", "+arg(cur.ID)+")")
+	}
+
+	query := "SELECT data FROM tasks"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY " + col + " " + dir + ", id " + dir
+	if opts.Limit > 0 {
+		// one extra row tells whether there is a next page
+		query += " LIMIT " + arg(opts.Limit+1)
+	}
+	rows, err := p.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, pgError(err)
+	}
+	tasks, err := scanTasks(rows)
+	if err != nil {
+		return nil, pgError(err)
+	}
+	page := &TaskPage{Tasks: tasks}
+	if opts.Limit > 0 && len(tasks) > opts.Limit {
+		page.Tasks = tasks[:opts.Limit]
+		last := page.Tasks[opts.Limit-1]
+		page.NextCursor = encodeCursor(opts.key(last), last.ID)
+	}
+	return page, nil
+}
@@ -0,0 +1,40 @@
+package store
+
+import (
+	"context"
+
+	"github.com/husainaj20/task-manager-api/internal/models"
+)
+
+func (p *PostgresStore) AddPause(ctx context.Context, pause models.Pause) error {
+	_, err := p.db.ExecContext(ctx, `INSERT INTO pauses (scope, name, paused_at) VALUES ($1, $2, $3)
+		ON CONFLICT (scope, name) DO NOTHING`, string(pause.Scope), pause.Name, pause.PausedAt)
+	return pgError(err)
+}
+
+func (p *PostgresStore) RemovePause(ctx context.Context, scope models.PauseScope, name string) error {
+	_, err := p.db.ExecContext(ctx, `DELETE FROM pauses WHERE scope = $1 AND name = $2`, string(scope), name)
+	return pgError(err)
+}
+
+func (p *PostgresStore) ListPauses(ctx context.Context) ([]models.Pause, error) {
+	rows, err := p.db.QueryContext(ctx, `SELECT scope, name, paused_at FROM pauses`)
+	if err != nil {
+		return nil, pgError(err)
+	}
+	defer rows.Close()
+	out := []models.Pause{}
+	for rows.Next() {
+		var pause models.Pause
+		if err := rows.Scan(&pause.Scope, &pause.Name, &pause.PausedAt); err != nil {
+			return nil, pgError(err)
+		}
+		pause.PausedAt = pause.PausedAt.UTC()
+		out = append(out, pause)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, pgError(err)
+	}
+	sortPauses(out)
+	return out, nil
+}
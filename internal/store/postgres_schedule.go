@@ -0,0 +1,131 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/husainaj20/task-manager-api/internal/models"
+)
+
+func scanSchedule(row *sql.Row) (*models.Schedule, error) {
+	var data []byte
+	if err := row.Scan(&data); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrScheduleNotFound
+		}
+		return nil, err
+	}
+	var s models.Schedule
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (p *PostgresStore) CreateSchedule(ctx context.Context, s *models.Schedule) (*models.Schedule, error) {
+	if s.ID == "" {
+		s.ID = uuid.NewString()
+	}
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	s.CreatedAt, s.UpdatedAt = now, now
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.db.ExecContext(ctx, `INSERT INTO schedules (id, created_at, data) VALUES ($1, $2, $3)`,
+		s.ID, s.CreatedAt, string(data)); err != nil {
+		return nil, pgError(err)
+	}
+	return cloneSchedule(s), nil
+}
+
+func (p *PostgresStore) GetSchedule(ctx context.Context, id string) (*models.Schedule, error) {
+	s, err := scanSchedule(p.db.QueryRowContext(ctx, `SELECT data FROM schedules WHERE id = $1`, id))
+	return s, pgError(err)
+}
+
+func (p *PostgresStore) ListSchedules(ctx context.Context) ([]*models.Schedule, error) {
+	rows, err := p.db.QueryContext(ctx, `SELECT data FROM schedules ORDER BY created_at, id`)
+	if err != nil {
+		return nil, pgError(err)
+	}
+	defer rows.Close()
+	out := []*models.Schedule{}
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, pgError(err)
+		}
+		var s models.Schedule
+		if err := json.Unmarshal(data, &s); err != nil {
+			return nil, err
+		}
+		out = append(out, &s)
+	}
+	return out, pgError(rows.Err())
+}
+
+func (p *PostgresStore) SetSchedulePaused(ctx context.Context, id string, paused bool, next time.Time) (*models.Schedule, error) {
+	s, _, err := p.updateSchedule(ctx, id, func(s *models.Schedule) bool {
+		s.Paused = paused
+		s.NextRunAt = next
+		return true
+	})
+	return s, err
+}
+
+func (p *PostgresStore) DeleteSchedule(ctx context.Context, id string) error {
+	res, err := p.db.ExecContext(ctx, `DELETE FROM schedules WHERE id = $1`, id)
+	if err != nil {
+		return pgError(err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrScheduleNotFound
+	}
+	return nil
+}
+
+func (p *PostgresStore) AdvanceSchedule(ctx context.Context, id string, from, next time.Time) (bool, error) {
+	_, ok, err := p.updateSchedule(ctx, id, func(s *models.Schedule) bool {
+		if s.Paused || !s.NextRunAt.Equal(from) {
+			return false
+		}
+		last := from
+		s.LastRunAt = &last
+		s.NextRunAt = next
+		return true
+	})
+	return ok, err
+}
+
+// updateSchedule applies fn to the locked schedule row and writes it back
+// unless fn reports false.
+func (p *PostgresStore) updateSchedule(ctx context.Context, id string, fn func(s *models.Schedule) bool) (*models.Schedule, bool, error) {
+	var out *models.Schedule
+	var changed bool
+	err := inTx(ctx, p.db, func(tx *sql.Tx) error {
+		s, err := scanSchedule(tx.QueryRowContext(ctx, `SELECT data FROM schedules WHERE id = $1 FOR UPDATE`, id))
+		if err != nil {
+			return err
+		}
+		out = s
+		if changed = fn(s); !changed {
+			return nil
+		}
+		s.UpdatedAt = time.Now().UTC()
+		data, err := json.Marshal(s)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE schedules SET data = $2 WHERE id = $1`, id, string(data))
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return out, changed, nil
+}
@@ -0,0 +1,49 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/husainaj20/task-manager-api/internal/models"
+)
+
+func (p *PostgresStore) CreateWorkflow(ctx context.Context, w *models.Workflow, tasks []*models.Task) (*models.Workflow, error) {
+	newWorkflow(w, tasks, time.Now().UTC().Truncate(time.Microsecond))
+	data, err := json.Marshal(w)
+	if err != nil {
+		return nil, err
+	}
+	err = inTx(ctx, p.db, func(tx *sql.Tx) error {
+		for _, t := range tasks {
+			if err := writeTask(ctx, tx, insertTaskSQL, t); err != nil {
+				return err
+			}
+		}
+		_, err := tx.ExecContext(ctx, `INSERT INTO workflows (id, created_at, data) VALUES ($1, $2, $3)`,
+			w.ID, w.CreatedAt, string(data))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cloneWorkflow(w), nil
+}
+
+func (p *PostgresStore) GetWorkflow(ctx context.Context, id string) (*models.Workflow, error) {
+	var data []byte
+	err := p.db.QueryRowContext(ctx, `SELECT data FROM workflows WHERE id = $1`, id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrWorkflowNotFound
+	}
+	if err != nil {
+		return nil, pgError(err)
+	}
+	var w models.Workflow
+	if err := json.Unmarshal(data, &w); err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
@@ -2,8 +2,7 @@ package store
 
 import (
 	"context"
-	"encoding/json"
-	"errors"
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
@@ -11,85 +10,437 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
-var errRedisNotFound = errors.New("task not found")
-
 type RedisStore struct {
-	rdb    *redis.Client
-	prefix string
+	rdb     *redis.Client
+	prefix  string
+	idemTTL time.Duration
 }
 
 func NewRedisStore(addr string, prefix string) *RedisStore {
 	rdb := redis.NewClient(&redis.Options{Addr: addr})
+	rdb.AddHook(unavailableHook{})
 	return &RedisStore{rdb: rdb, prefix: prefix}
 }
 
+// unavailableHook reports commands that failed to reach Redis with
+// ErrUnavailable. Results are read from the commands, so their errors are
+// replaced as well.
+type unavailableHook struct{}
+
+func (unavailableHook) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (unavailableHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		err := unavailable(next(ctx, cmd))
+		if cmd.Err() != nil {
+			cmd.SetErr(unavailable(cmd.Err()))
+		}
+		return err
+	}
+}
+
+func (unavailableHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		err := unavailable(next(ctx, cmds))
+		for _, cmd := range cmds {
+			if cmd.Err() != nil {
+				cmd.SetErr(unavailable(cmd.Err()))
+			}
+		}
+		return err
+	}
+}
+
+// SetIdempotencyTTL sets how long an idempotency key keeps pointing at its
+// task. Zero, the default, keeps keys until the task is deleted.
+func (r *RedisStore) SetIdempotencyTTL(d time.Duration) { r.idemTTL = d }
+
 func (r *RedisStore) key(id string) string { return r.prefix + ":task:" + id }
 
-func (r *RedisStore) CreateOrGetByKey(ctx context.Context, key string, t *models.Task) (*models.Task, bool, error) {
-	// Simple idempotency via separate key -> id mapping
-	if key != "" {
-		if id, err := r.rdb.Get(ctx, r.prefix+":idem:"+key).Result(); err == nil {
-			data, err := r.rdb.Get(ctx, r.key(id)).Result()
-			if err != nil {
-				return nil, false, err
+func (r *RedisStore) idemKey(key string) string { return r.prefix + ":idem:" + key }
+
+// scheduledKey is a sorted set of scheduled task ids scored by RunAt (unix ms).
+func (r *RedisStore) scheduledKey() string { return r.prefix + ":scheduled" }
+
+// deadKey is a sorted set of dead task ids scored by UpdatedAt (unix ms).
+func (r *RedisStore) deadKey() string { return r.prefix + ":dead" }
+
+// index writes t's entries in the indexes, given prev, the stored version of
+// t, or nil for a new task. Only entries that changed are written: a task
+// leaves the sets it no longer belongs to, and joins new ones or moves within
+// those scored by a changed time.
+func (r *RedisStore) index(ctx context.Context, p redis.Pipeliner, prev, t *models.Task) {
+	if t.Status == models.StatusScheduled && t.RunAt != nil {
+		p.ZAdd(ctx, r.scheduledKey(), redis.Z{Score: float64(t.RunAt.UnixMilli()), Member: t.ID})
+	} else if prev != nil && prev.Status == models.StatusScheduled {
+		p.ZRem(ctx, r.scheduledKey(), t.ID)
+	}
+	if t.Status == models.StatusDead {
+		p.ZAdd(ctx, r.deadKey(), redis.Z{Score: float64(t.UpdatedAt.UnixMilli()), Member: t.ID})
+	} else if prev != nil && prev.Status == models.StatusDead {
+		p.ZRem(ctx, r.deadKey(), t.ID)
+	}
+	for _, f := range []SortField{SortByCreated, SortByUpdated} {
+		old := make(map[string]bool)
+		if prev != nil {
+			for _, k := range r.indexKeys(f, prev) {
+				old[k] = true
 			}
-			var existing models.Task
-			if err := json.Unmarshal([]byte(data), &existing); err != nil {
-				return nil, false, err
+		}
+		moved := prev == nil || !sortTime(f, prev).Equal(sortTime(f, t))
+		z := redis.Z{Score: float64(sortTime(f, t).UnixMilli()), Member: t.ID}
+		for _, k := range r.indexKeys(f, t) {
+			if moved || !old[k] {
+				p.ZAdd(ctx, k, z)
 			}
-			return &existing, true, nil
+			delete(old, k)
+		}
+		for k := range old {
+			p.ZRem(ctx, k, t.ID)
+		}
+	}
+}
+
+// unindex removes a deleted task from every index.
+func (r *RedisStore) unindex(ctx context.Context, p redis.Pipeliner, t *models.Task) {
+	p.ZRem(ctx, r.scheduledKey(), t.ID)
+	p.ZRem(ctx, r.deadKey(), t.ID)
+	for _, f := range []SortField{SortByCreated, SortByUpdated} {
+		for _, k := range r.indexKeys(f, t) {
+			p.ZRem(ctx, k, t.ID)
 		}
 	}
+}
 
+// CreateOrGetByKey creates t, or returns the task key already maps to. The
+// idempotency key is watched while the task is written, so when replicas race
+// on the same key exactly one creates a task and the others retry and find it.
+func (r *RedisStore) CreateOrGetByKey(ctx context.Context, key string, t *models.Task) (*models.Task, bool, error) {
 	if t.ID == "" {
 		t.ID = uuid.NewString()
 	}
 	now := time.Now().UTC()
 	t.CreatedAt, t.UpdatedAt = now, now
-	b, err := json.Marshal(t)
+	t.Version = 1
+	t.IdempotencyKey = key
+	b, err := encodeTask(t)
 	if err != nil {
 		return nil, false, err
 	}
 
-	if err := r.rdb.Set(ctx, r.key(t.ID), b, 0).Err(); err != nil {
-		return nil, false, err
+	if key == "" {
+		_, err := r.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			p.Set(ctx, r.key(t.ID), b, 0)
+			r.index(ctx, p, nil, t)
+			return nil
+		})
+		if err != nil {
+			return nil, false, err
+		}
+		return t, false, nil
 	}
-	if key != "" {
-		if err := r.rdb.Set(ctx, r.prefix+":idem:"+key, t.ID, 0).Err(); err != nil {
+
+	for i := 0; i < 10; i++ {
+		existing, err := r.createOnce(ctx, key, t, b)
+		if err == redis.TxFailedErr {
+			continue
+		}
+		if err != nil {
 			return nil, false, err
 		}
+		if existing != nil {
+			return existing, true, nil
+		}
+		return t, false, nil
 	}
-	return t, false, nil
+	return nil, false, ErrConflict
+}
+
+// createOnce returns the live task key maps to, or writes t (marshalled as b)
+// and claims key for it. It fails with redis.TxFailedErr if key changed in the
+// meantime.
+func (r *RedisStore) createOnce(ctx context.Context, key string, t *models.Task, b []byte) (*models.Task, error) {
+	var existing *models.Task
+	err := r.rdb.Watch(ctx, func(tx *redis.Tx) error {
+		id, err := tx.Get(ctx, r.idemKey(key)).Result()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+		if err == nil {
+			s, err := tx.Get(ctx, r.key(id)).Result()
+			if err == nil {
+				var found models.Task
+				if err := decodeTask([]byte(s), &found); err != nil {
+					return err
+				}
+				existing = &found
+				return nil
+			}
+			// a deleted task frees its key
+			if err != redis.Nil {
+				return err
+			}
+		}
+		_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			p.Set(ctx, r.key(t.ID), b, 0)
+			r.index(ctx, p, nil, t)
+			p.Set(ctx, r.idemKey(key), t.ID, r.idemTTL)
+			return nil
+		})
+		return err
+	}, r.idemKey(key))
+	return existing, err
 }
 
 func (r *RedisStore) Get(ctx context.Context, id string) (*models.Task, error) {
 	s, err := r.rdb.Get(ctx, r.key(id)).Result()
 	if err == redis.Nil {
-		return nil, errRedisNotFound
+		return nil, ErrNotFound
 	}
 	if err != nil {
 		return nil, err
 	}
 	var t models.Task
-	if err := json.Unmarshal([]byte(s), &t); err != nil {
+	if err := decodeTask([]byte(s), &t); err != nil {
 		return nil, err
 	}
 	return &t, nil
 }
 
-func (r *RedisStore) UpdateStatus(ctx context.Context, id string, status string, result map[string]any) error {
-	t, err := r.Get(ctx, id)
+func (r *RedisStore) UpdateStatus(ctx context.Context, id string, status models.Status, result map[string]any) error {
+	_, err := r.Update(ctx, id, 0, func(t *models.Task) error {
+		if err := t.Transition(status); err != nil {
+			return err
+		}
+		if result != nil {
+			t.Result = result
+		}
+		return nil
+	})
+	return err
+}
+
+func (r *RedisStore) RecordAttempt(ctx context.Context, id string, a models.Attempt) error {
+	_, err := r.Update(ctx, id, 0, func(t *models.Task) error {
+		t.AddAttempt(a)
+		return nil
+	})
+	return err
+}
+
+// Update runs under WATCH on the task key. A concurrent write makes the
+// transaction fail, and the update is retried against the new state.
+func (r *RedisStore) Update(ctx context.Context, id string, version int64, fn func(t *models.Task) error) (*models.Task, error) {
+	for i := 0; i < 10; i++ {
+		t, err := r.updateOnce(ctx, id, version, fn)
+		if err != redis.TxFailedErr {
+			return t, err
+		}
+	}
+	return nil, ErrConflict
+}
+
+func (r *RedisStore) updateOnce(ctx context.Context, id string, version int64, fn func(t *models.Task) error) (*models.Task, error) {
+	var updated *models.Task
+	err := r.rdb.Watch(ctx, func(tx *redis.Tx) error {
+		s, err := tx.Get(ctx, r.key(id)).Result()
+		if err == redis.Nil {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		var t models.Task
+		if err := decodeTask([]byte(s), &t); err != nil {
+			return err
+		}
+		if version != 0 && t.Version != version {
+			return ErrVersionMismatch
+		}
+		prev := clone(&t)
+		if err := fn(&t); err != nil {
+			return err
+		}
+		touch(&t)
+		b, err := encodeTask(&t)
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			p.Set(ctx, r.key(id), b, 0)
+			r.index(ctx, p, prev, &t)
+			return nil
+		})
+		if err == nil {
+			updated = &t
+		}
+		return err
+	}, r.key(id))
+	return updated, err
+}
+
+func (r *RedisStore) Delete(ctx context.Context, id string, version int64) error {
+	mismatch := false
+	ok, err := r.deleteIf(ctx, id, func(t *models.Task) bool {
+		mismatch = version != 0 && t.Version != version
+		return !mismatch
+	})
+	switch {
+	case err != nil:
+		return err
+	case mismatch:
+		return ErrVersionMismatch
+	case !ok:
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *RedisStore) Expire(ctx context.Context, status models.Status, cutoff time.Time, limit int) (int, error) {
+	by := &redis.ZRangeBy{Min: "-inf", Max: "(" + strconv.FormatInt(cutoff.UnixMilli(), 10)}
+	if limit > 0 {
+		by.Count = int64(limit)
+	}
+	ids, err := r.rdb.ZRangeByScore(ctx, r.listKey(SortByUpdated, "status", string(status)), by).Result()
 	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, id := range ids {
+		ok, err := r.deleteIf(ctx, id, func(t *models.Task) bool {
+			return t.Status == status && t.UpdatedAt.Before(cutoff)
+		})
+		if err != nil {
+			return n, err
+		}
+		if ok {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// deleteIf deletes a task, its idempotency key and its index entries if cond
+// holds, and reports false for a missing task. The key is watched so a task
+// changed concurrently is re-checked rather than deleted.
+func (r *RedisStore) deleteIf(ctx context.Context, id string, cond func(t *models.Task) bool) (bool, error) {
+	for i := 0; i < 5; i++ {
+		deleted, err := r.tryDeleteIf(ctx, id, cond)
+		if err != redis.TxFailedErr {
+			return deleted, err
+		}
+	}
+	return false, ErrConflict
+}
+
+func (r *RedisStore) tryDeleteIf(ctx context.Context, id string, cond func(t *models.Task) bool) (bool, error) {
+	var deleted bool
+	var workflowID string
+	err := r.rdb.Watch(ctx, func(tx *redis.Tx) error {
+		s, err := tx.Get(ctx, r.key(id)).Result()
+		if err == redis.Nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var t models.Task
+		if err := decodeTask([]byte(s), &t); err != nil {
+			return err
+		}
+		if !cond(&t) {
+			return nil
+		}
+		// the key may have expired and been reused by another task
+		ownsKey := false
+		if t.IdempotencyKey != "" {
+			owner, err := tx.Get(ctx, r.idemKey(t.IdempotencyKey)).Result()
+			if err != nil && err != redis.Nil {
+				return err
+			}
+			ownsKey = owner == id
+		}
+		_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			p.Del(ctx, r.key(id))
+			if ownsKey {
+				p.Del(ctx, r.idemKey(t.IdempotencyKey))
+			}
+			r.unindex(ctx, p, &t)
+			return nil
+		})
+		deleted = err == nil
+		workflowID = t.WorkflowID
 		return err
+	}, r.key(id))
+	if deleted && workflowID != "" {
+		err = r.deleteEmptyWorkflow(ctx, workflowID)
 	}
-	t.Status = status
-	if result != nil {
-		t.Result = result
+	return deleted, err
+}
+
+func (r *RedisStore) ClaimDue(ctx context.Context, now time.Time, limit int) ([]*models.Task, error) {
+	by := &redis.ZRangeBy{Min: "-inf", Max: strconv.FormatInt(now.UnixMilli(), 10)}
+	if limit > 0 {
+		by.Count = int64(limit)
 	}
-	t.UpdatedAt = time.Now().UTC()
-	b, err := json.Marshal(t)
+	ids, err := r.rdb.ZRangeByScore(ctx, r.scheduledKey(), by).Result()
 	if err != nil {
+		return nil, err
+	}
+	var claimed []*models.Task
+	for _, id := range ids {
+		t, err := r.claim(ctx, id)
+		if err != nil {
+			return claimed, err
+		}
+		if t != nil {
+			claimed = append(claimed, t)
+		}
+	}
+	return claimed, nil
+}
+
+// claim moves one scheduled task to queued. The task key is watched so that
+// when several replicas race for the same task only one of them succeeds;
+// the others get (nil, nil).
+func (r *RedisStore) claim(ctx context.Context, id string) (*models.Task, error) {
+	var claimed *models.Task
+	err := r.rdb.Watch(ctx, func(tx *redis.Tx) error {
+		s, err := tx.Get(ctx, r.key(id)).Result()
+		if err == redis.Nil {
+			return tx.ZRem(ctx, r.scheduledKey(), id).Err()
+		}
+		if err != nil {
+			return err
+		}
+		var t models.Task
+		if err := decodeTask([]byte(s), &t); err != nil {
+			return err
+		}
+		if t.Status != models.StatusScheduled {
+			return nil
+		}
+		prev := clone(&t)
+		if err := t.Transition(models.StatusQueued); err != nil {
+			return err
+		}
+		touch(&t)
+		b, err := encodeTask(&t)
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			p.Set(ctx, r.key(id), b, 0)
+			r.index(ctx, p, prev, &t)
+			return nil
+		})
+		if err == nil {
+			claimed = &t
+		}
 		return err
+	}, r.key(id))
+	if err == redis.TxFailedErr {
+		return nil, nil
 	}
-	return r.rdb.Set(ctx, r.key(id), b, 0).Err()
+	return claimed, err
 }
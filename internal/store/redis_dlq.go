@@ -0,0 +1,40 @@
+package store
+
+import (
+	"context"
+
+	"github.com/husainaj20/task-manager-api/internal/models"
+)
+
+func (r *RedisStore) ListDead(ctx context.Context) ([]*models.Task, error) {
+	ids, err := r.rdb.ZRange(ctx, r.deadKey(), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	dead := make([]*models.Task, 0, len(ids))
+	for _, id := range ids {
+		t, err := r.Get(ctx, id)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		dead = append(dead, t)
+	}
+	return dead, nil
+}
+
+func (r *RedisStore) PurgeDead(ctx context.Context, ids []string) (int, error) {
+	purged := 0
+	for _, id := range ids {
+		ok, err := r.deleteIf(ctx, id, func(t *models.Task) bool { return t.Status == models.StatusDead })
+		if err != nil {
+			return purged, err
+		}
+		if ok {
+			purged++
+		}
+	}
+	return purged, nil
+}
@@ -0,0 +1,223 @@
+package store
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/husainaj20/task-manager-api/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// listKey names a listing index: a sorted set of task ids scored by the sort
+// field (unix ms), optionally narrowed to one status, type or label.
+func (r *RedisStore) listKey(f SortField, narrow ...string) string {
+	k := r.prefix + ":idx:" + string(f)
+	for _, n := range narrow {
+		k += ":" + n
+	}
+	return k
+}
+
+// indexKeys names the listing indexes t belongs to for sort field f.
+func (r *RedisStore) indexKeys(f SortField, t *models.Task) []string {
+	keys := []string{
+		r.listKey(f),
+		r.listKey(f, "type", t.Type),
+		r.listKey(f, "status", string(t.Status)),
+	}
+	for k, v := range t.Metadata {
+		keys = append(keys, r.labelKey(f, k, v))
+	}
+	return keys
+}
+
+func (r *RedisStore) labelKey(f SortField, k, v string) string {
+	return r.listKey(f, "label", k+"="+v)
+}
+
+// tmpTTL bounds how long a combined index outlives a listing that failed to
+// delete it.
+const tmpTTL = time.Minute
+
+// selectIndex returns the index to walk for opts, scored by sort field f. A
+// single condition walks its own index. Several are combined in Redis into a
+// temporary set, which the returned func deletes: statuses are united, then
+// intersected with the type and label indexes and cut to the range on the
+// other time field, so only candidate tasks are loaded.
+func (r *RedisStore) selectIndex(ctx context.Context, f SortField, opts ListOptions) (string, func(), error) {
+	var sets []string
+	if len(opts.Statuses) == 1 {
+		sets = append(sets, r.listKey(f, "status", string(opts.Statuses[0])))
+	}
+	if opts.Type != "" {
+		sets = append(sets, r.listKey(f, "type", opts.Type))
+	}
+	for k, v := range opts.Labels {
+		sets = append(sets, r.labelKey(f, k, v))
+	}
+	other, after, before := SortByUpdated, opts.UpdatedAfter, opts.UpdatedBefore
+	if f == SortByUpdated {
+		other, after, before = SortByCreated, opts.CreatedAfter, opts.CreatedBefore
+	}
+	union := len(opts.Statuses) > 1
+	ranged := !after.IsZero() || !before.IsZero()
+	if !union && !ranged && len(sets) <= 1 {
+		if len(sets) == 0 {
+			return r.listKey(f), func() {}, nil
+		}
+		return sets[0], func() {}, nil
+	}
+
+	// each step writes its own key: a destination that is also a source is
+	// not portable across Redis implementations
+	tmp := r.prefix + ":tmp:" + uuid.NewString()
+	statuses, inRange := tmp+":statuses", tmp+":range"
+	drop := func() { r.rdb.Del(context.Background(), tmp, statuses, inRange) }
+	result := tmp
+	_, err := r.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		if union {
+			keys := make([]string, len(opts.Statuses))
+			for i, s := range opts.Statuses {
+				keys[i] = r.listKey(f, "status", string(s))
+			}
+			p.ZUnionStore(ctx, statuses, &redis.ZStore{Keys: keys, Aggregate: "MIN"})
+			p.Expire(ctx, statuses, tmpTTL)
+			sets = append(sets, statuses)
+		}
+		if len(sets) == 0 {
+			sets = append(sets, r.listKey(f))
+		}
+		switch {
+		case ranged:
+			// score by the other time field to cut the range, then back
+			weights := make([]float64, len(sets)+1)
+			weights[len(sets)] = 1
+			p.ZInterStore(ctx, inRange, &redis.ZStore{Keys: append(sets, r.listKey(other)), Weights: weights})
+			p.Expire(ctx, inRange, tmpTTL)
+			if !after.IsZero() {
+				p.ZRemRangeByScore(ctx, inRange, "-inf", "("+strconv.FormatInt(after.UnixMilli(), 10))
+			}
+			if !before.IsZero() {
+				p.ZRemRangeByScore(ctx, inRange, "("+strconv.FormatInt(before.UnixMilli(), 10), "+inf")
+			}
+			p.ZInterStore(ctx, tmp, &redis.ZStore{Keys: []string{inRange, r.listKey(f)}, Weights: []float64{0, 1}})
+		case len(sets) > 1:
+			p.ZInterStore(ctx, tmp, &redis.ZStore{Keys: sets, Aggregate: "MIN"})
+		default:
+			result = statuses
+		}
+		p.Expire(ctx, tmp, tmpTTL)
+		return nil
+	})
+	if err != nil {
+		drop()
+		return "", nil, err
+	}
+	return result, drop, nil
+}
+
+// ListTasks walks the index selectIndex picks for opts in listing order and
+// checks each loaded task against opts, which also drops tasks changed since
+// they were indexed.
+func (r *RedisStore) ListTasks(ctx context.Context, opts ListOptions) (*TaskPage, error) {
+	cur, err := decodeCursor(opts.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	f := opts.sortBy()
+	key, drop, err := r.selectIndex(ctx, f, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer drop()
+
+	// score bounds from the cursor and the time range on the sort field
+	after, before := opts.CreatedAfter, opts.CreatedBefore
+	if f == SortByUpdated {
+		after, before = opts.UpdatedAfter, opts.UpdatedBefore
+	}
+	min, max := "-inf", "+inf"
+	if !after.IsZero() {
+		min = strconv.FormatInt(after.UnixMilli(), 10)
+	}
+	if !before.IsZero() {
+		max = strconv.FormatInt(before.UnixMilli(), 10)
+	}
+	if cur != nil {
+		if opts.Desc {
+			max = strconv.FormatInt(cur.Key, 10)
+		} else {
+			min = strconv.FormatInt(cur.Key, 10)
+		}
+	}
+
+	limit := opts.Limit
+	batch := int64(100)
+	if limit > 0 && int64(limit)+1 > batch {
+		batch = int64(limit) + 1
+	}
+	page := &TaskPage{Tasks: []*models.Task{}}
+	var lastKey int64
+	for offset := int64(0); ; offset += batch {
+		by := &redis.ZRangeBy{Min: min, Max: max, Offset: offset, Count: batch}
+		var zs []redis.Z
+		if opts.Desc {
+			zs, err = r.rdb.ZRevRangeByScoreWithScores(ctx, key, by).Result()
+		} else {
+			zs, err = r.rdb.ZRangeByScoreWithScores(ctx, key, by).Result()
+		}
+		if err != nil {
+			return nil, err
+		}
+		tasks, err := r.getMany(ctx, zs)
+		if err != nil {
+			return nil, err
+		}
+		for i, z := range zs {
+			t, k := tasks[i], int64(z.Score)
+			if t == nil || !cur.after(k, t.ID, opts.Desc) || !opts.match(t) {
+				continue
+			}
+			if limit > 0 && len(page.Tasks) == limit {
+				page.NextCursor = encodeCursor(lastKey, page.Tasks[limit-1].ID)
+				return page, nil
+			}
+			page.Tasks = append(page.Tasks, t)
+			lastKey = k
+		}
+		if int64(len(zs)) < batch {
+			return page, nil
+		}
+	}
+}
+
+// getMany loads the tasks of an index range in one round trip. Entries whose
+// task no longer exists are nil.
+func (r *RedisStore) getMany(ctx context.Context, zs []redis.Z) ([]*models.Task, error) {
+	if len(zs) == 0 {
+		return nil, nil
+	}
+	keys := make([]string, len(zs))
+	for i, z := range zs {
+		keys[i] = r.key(z.Member.(string))
+	}
+	vals, err := r.rdb.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	tasks := make([]*models.Task, len(vals))
+	for i, v := range vals {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		var t models.Task
+		if err := decodeTask([]byte(s), &t); err != nil {
+			return nil, err
+		}
+		tasks[i] = &t
+	}
+	return tasks, nil
+}
@@ -0,0 +1,40 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/husainaj20/task-manager-api/internal/models"
+)
+
+// pausesKey is a hash of "<scope>:<name>" -> Pause JSON.
+func (r *RedisStore) pausesKey() string { return r.prefix + ":pauses" }
+
+func (r *RedisStore) AddPause(ctx context.Context, p models.Pause) error {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return r.rdb.HSetNX(ctx, r.pausesKey(), string(p.Scope)+":"+p.Name, b).Err()
+}
+
+func (r *RedisStore) RemovePause(ctx context.Context, scope models.PauseScope, name string) error {
+	return r.rdb.HDel(ctx, r.pausesKey(), string(scope)+":"+name).Err()
+}
+
+func (r *RedisStore) ListPauses(ctx context.Context) ([]models.Pause, error) {
+	all, err := r.rdb.HGetAll(ctx, r.pausesKey()).Result()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]models.Pause, 0, len(all))
+	for _, data := range all {
+		var p models.Pause
+		if err := json.Unmarshal([]byte(data), &p); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	sortPauses(out)
+	return out, nil
+}
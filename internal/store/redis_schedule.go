@@ -0,0 +1,148 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/husainaj20/task-manager-api/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+func (r *RedisStore) scheduleKey(id string) string { return r.prefix + ":schedule:" + id }
+
+// schedulesKey is the set of all schedule ids.
+func (r *RedisStore) schedulesKey() string { return r.prefix + ":schedules" }
+
+func (r *RedisStore) CreateSchedule(ctx context.Context, s *models.Schedule) (*models.Schedule, error) {
+	if s.ID == "" {
+		s.ID = uuid.NewString()
+	}
+	now := time.Now().UTC()
+	s.CreatedAt, s.UpdatedAt = now, now
+	b, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.Set(ctx, r.scheduleKey(s.ID), b, 0)
+		p.SAdd(ctx, r.schedulesKey(), s.ID)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (r *RedisStore) GetSchedule(ctx context.Context, id string) (*models.Schedule, error) {
+	data, err := r.rdb.Get(ctx, r.scheduleKey(id)).Result()
+	if err == redis.Nil {
+		return nil, ErrScheduleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var s models.Schedule
+	if err := json.Unmarshal([]byte(data), &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *RedisStore) ListSchedules(ctx context.Context) ([]*models.Schedule, error) {
+	ids, err := r.rdb.SMembers(ctx, r.schedulesKey()).Result()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*models.Schedule, 0, len(ids))
+	for _, id := range ids {
+		s, err := r.GetSchedule(ctx, id)
+		if err == ErrScheduleNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out, nil
+}
+
+func (r *RedisStore) SetSchedulePaused(ctx context.Context, id string, paused bool, next time.Time) (*models.Schedule, error) {
+	var updated *models.Schedule
+	_, err := r.mutateSchedule(ctx, id, func(s *models.Schedule) bool {
+		s.Paused = paused
+		s.NextRunAt = next
+		updated = s
+		return true
+	})
+	if err == redis.TxFailedErr {
+		return nil, ErrConflict
+	}
+	return updated, err
+}
+
+func (r *RedisStore) DeleteSchedule(ctx context.Context, id string) error {
+	n, err := r.rdb.Del(ctx, r.scheduleKey(id)).Result()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrScheduleNotFound
+	}
+	return r.rdb.SRem(ctx, r.schedulesKey(), id).Err()
+}
+
+func (r *RedisStore) AdvanceSchedule(ctx context.Context, id string, from, next time.Time) (bool, error) {
+	ok, err := r.mutateSchedule(ctx, id, func(s *models.Schedule) bool {
+		if s.Paused || !s.NextRunAt.Equal(from) {
+			return false
+		}
+		last := from
+		s.LastRunAt = &last
+		s.NextRunAt = next
+		return true
+	})
+	if err == redis.TxFailedErr {
+		// another replica changed the schedule first
+		return false, nil
+	}
+	return ok, err
+}
+
+// mutateSchedule applies fn to the schedule under WATCH and writes it back if
+// fn returns true. A concurrent write makes it fail with redis.TxFailedErr.
+func (r *RedisStore) mutateSchedule(ctx context.Context, id string, fn func(s *models.Schedule) bool) (bool, error) {
+	applied := false
+	err := r.rdb.Watch(ctx, func(tx *redis.Tx) error {
+		data, err := tx.Get(ctx, r.scheduleKey(id)).Result()
+		if err == redis.Nil {
+			return ErrScheduleNotFound
+		}
+		if err != nil {
+			return err
+		}
+		var s models.Schedule
+		if err := json.Unmarshal([]byte(data), &s); err != nil {
+			return err
+		}
+		if !fn(&s) {
+			return nil
+		}
+		s.UpdatedAt = time.Now().UTC()
+		b, err := json.Marshal(&s)
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			p.Set(ctx, r.scheduleKey(id), b, 0)
+			return nil
+		})
+		applied = err == nil
+		return err
+	}, r.scheduleKey(id))
+	return applied, err
+}
@@ -2,13 +2,18 @@ package store
 
 import (
 	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	miniredis "github.com/alicebob/miniredis/v2"
 	"github.com/husainaj20/task-manager-api/internal/models"
 )
 
-func TestRedisStore_CreateGetUpdate_Idempotent(t *testing.T) {
+func TestRedisStore_CreateOrGetByKey_ConcurrentReplicas(t *testing.T) {
 	mr, err := miniredis.Run()
 	if err != nil {
 		t.Fatalf("failed to start miniredis: %v", err)
@@ -16,55 +21,199 @@ func TestRedisStore_CreateGetUpdate_Idempotent(t *testing.T) {
 	defer mr.Close()
 
 	ctx := context.Background()
-	rs := NewRedisStore(mr.Addr(), "test")
+	const callers = 50
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	ids := map[string]bool{}
+	created := 0
+	start := make(chan struct{})
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			replica := NewRedisStore(mr.Addr(), "test")
+			<-start
+			task, existed, err := replica.CreateOrGetByKey(ctx, "shared", &models.Task{Type: "echo", Status: models.StatusQueued})
+			if err != nil {
+				t.Errorf("create error: %v", err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			ids[task.ID] = true
+			if !existed {
+				created++
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
 
-	task := &models.Task{Type: "echo", Payload: map[string]any{"msg": "hello"}, Status: "queued"}
-	created, existed, err := rs.CreateOrGetByKey(ctx, "k1", task)
-	if err != nil {
-		t.Fatalf("create error: %v", err)
+	if created != 1 || len(ids) != 1 {
+		t.Fatalf("expected exactly one task created, got %d created and %d distinct ids", created, len(ids))
 	}
-	if existed {
-		t.Fatalf("expected not existed on first create")
+	tasks := 0
+	for _, k := range mr.Keys() {
+		if strings.HasPrefix(k, "test:task:") {
+			tasks++
+		}
 	}
-	if created.ID == "" {
-		t.Fatalf("expected id set")
+	if tasks != 1 {
+		t.Fatalf("expected a single stored task, got %d", tasks)
 	}
+}
 
-	// Second create with same idempotency key should return same ID
-	created2, existed2, err := rs.CreateOrGetByKey(ctx, "k1", task)
+func TestRedisStore_ClaimDue_OnlyOnceAcrossReplicas(t *testing.T) {
+	mr, err := miniredis.Run()
 	if err != nil {
-		t.Fatalf("second create error: %v", err)
+		t.Fatalf("failed to start miniredis: %v", err)
 	}
-	if !existed2 {
-		t.Fatalf("expected existed on second create")
+	defer mr.Close()
+
+	ctx := context.Background()
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+	writer := NewRedisStore(mr.Addr(), "test")
+	for i := 0; i < 20; i++ {
+		if _, _, err := writer.CreateOrGetByKey(ctx, "", &models.Task{Type: "echo", Status: models.StatusScheduled, RunAt: &past}); err != nil {
+			t.Fatalf("create error: %v", err)
+		}
+	}
+	later, _, _ := writer.CreateOrGetByKey(ctx, "", &models.Task{Type: "echo", Status: models.StatusScheduled, RunAt: &future})
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	seen := map[string]int{}
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			replica := NewRedisStore(mr.Addr(), "test")
+			claimed, err := replica.ClaimDue(ctx, time.Now(), 0)
+			if err != nil {
+				t.Errorf("claim error: %v", err)
+			}
+			mu.Lock()
+			for _, c := range claimed {
+				seen[c.ID]++
+			}
+			mu.Unlock()
+		}()
 	}
-	if created.ID != created2.ID {
-		t.Fatalf("expected same id, got %s vs %s", created.ID, created2.ID)
+	wg.Wait()
+
+	if len(seen) != 20 {
+		t.Fatalf("expected 20 distinct tasks claimed, got %d", len(seen))
 	}
+	for id, n := range seen {
+		if n != 1 {
+			t.Fatalf("task %s claimed %d times", id, n)
+		}
+	}
+	got, _ := writer.Get(ctx, later.ID)
+	if got.Status != models.StatusScheduled {
+		t.Fatalf("expected future task to stay scheduled, got %s", got.Status)
+	}
+}
 
-	// Get
-	got, err := rs.Get(ctx, created.ID)
+func TestRedisStore_IdempotencyTTL(t *testing.T) {
+	mr, err := miniredis.Run()
 	if err != nil {
-		t.Fatalf("get error: %v", err)
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	ctx := context.Background()
+	rs := NewRedisStore(mr.Addr(), "test")
+	rs.SetIdempotencyTTL(time.Hour)
+	first, _, _ := rs.CreateOrGetByKey(ctx, "k", &models.Task{Type: "echo", Status: models.StatusQueued})
+	if ttl := mr.TTL("test:idem:k"); ttl != time.Hour {
+		t.Fatalf("expected idempotency key TTL of 1h, got %s", ttl)
 	}
-	if got.Type != task.Type {
-		t.Fatalf("expected type %s, got %s", task.Type, got.Type)
+	mr.FastForward(2 * time.Hour)
+	second, existed, _ := rs.CreateOrGetByKey(ctx, "k", &models.Task{Type: "echo", Status: models.StatusQueued})
+	if existed || second.ID == first.ID {
+		t.Fatalf("expected a new task once the key expired")
 	}
+	rs.UpdateStatus(ctx, first.ID, models.StatusCancelled, nil)
+	if err := rs.Delete(ctx, first.ID, 0); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if got, _ := mr.Get("test:idem:k"); got != second.ID {
+		t.Fatalf("expected key to still map to the second task, got %q", got)
+	}
+}
 
-	// Update status
-	if err := rs.UpdateStatus(ctx, created.ID, "done", map[string]any{"ok": true}); err != nil {
-		t.Fatalf("update status error: %v", err)
+func TestRedisStore_UpdateAcrossReplicas(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
 	}
-	got2, err := rs.Get(ctx, created.ID)
+	defer mr.Close()
+
+	ctx := context.Background()
+	writer := NewRedisStore(mr.Addr(), "test")
+	task, _, _ := writer.CreateOrGetByKey(ctx, "", &models.Task{Type: "echo", Status: models.StatusQueued})
+
+	// each WATCH round lets at least one writer through, so stay within the
+	// Redis store's retry budget
+	const writers = 8
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := NewRedisStore(mr.Addr(), "test").Update(ctx, task.ID, 0, func(t *models.Task) error {
+				n, _ := strconv.Atoi(t.Metadata["n"])
+				if t.Metadata == nil {
+					t.Metadata = map[string]string{}
+				}
+				t.Metadata["n"] = strconv.Itoa(n + 1)
+				return nil
+			})
+			if err != nil {
+				t.Errorf("update: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	got, _ := writer.Get(ctx, task.ID)
+	if got.Metadata["n"] != strconv.Itoa(writers) || got.Version != 1+writers {
+		t.Fatalf("expected %d updates applied, got n=%s version=%d", writers, got.Metadata["n"], got.Version)
+	}
+}
+
+func TestRedisStore_UpdateWritesOnlyChangedIndexes(t *testing.T) {
+	mr, err := miniredis.Run()
 	if err != nil {
-		t.Fatalf("get after update error: %v", err)
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	ctx := context.Background()
+	st := NewRedisStore(mr.Addr(), "test")
+	task, _, _ := st.CreateOrGetByKey(ctx, "", &models.Task{Type: "echo", Status: models.StatusQueued})
+
+	before := mr.CommandCount()
+	if err := st.UpdateStatus(ctx, task.ID, models.StatusRunning, nil); err != nil {
+		t.Fatal(err)
 	}
-	if got2.Status != "done" {
-		t.Fatalf("expected status done, got %s", got2.Status)
+	// WATCH, GET, MULTI, SET, two ZREMs of the old status, four ZADDs, EXEC
+	// and UNWATCH
+	if n := mr.CommandCount() - before; n > 12 {
+		t.Fatalf("expected an update to write only the changed index entries, got %d commands", n)
+	}
+	for _, f := range []SortField{SortByCreated, SortByUpdated} {
+		if _, err := st.rdb.ZScore(ctx, st.listKey(f, "status", string(models.StatusQueued)), task.ID).Result(); err == nil {
+			t.Fatalf("expected the task gone from the queued index of %s", f)
+		}
+		if _, err := st.rdb.ZScore(ctx, st.listKey(f, "status", string(models.StatusRunning)), task.ID).Result(); err != nil {
+			t.Fatalf("expected the task in the running index of %s: %v", f, err)
+		}
 	}
 }
 
-func TestRedisStore_Get_NotFound(t *testing.T) {
+func TestRedisStore_ListCombinesIndexes(t *testing.T) {
 	mr, err := miniredis.Run()
 	if err != nil {
 		t.Fatalf("failed to start miniredis: %v", err)
@@ -72,10 +221,46 @@ func TestRedisStore_Get_NotFound(t *testing.T) {
 	defer mr.Close()
 
 	ctx := context.Background()
+	st := NewRedisStore(mr.Addr(), "test")
+	for i := 0; i < 4; i++ {
+		team := map[bool]string{true: "a", false: "b"}[i%2 == 0]
+		task, _, _ := st.CreateOrGetByKey(ctx, "", &models.Task{Type: "echo", Status: models.StatusQueued, Metadata: map[string]string{"team": team}})
+		if i < 2 {
+			st.UpdateStatus(ctx, task.ID, models.StatusRunning, nil)
+		}
+	}
+
+	page, err := st.ListTasks(ctx, ListOptions{
+		Statuses:     []models.Status{models.StatusQueued, models.StatusRunning},
+		Labels:       map[string]string{"team": "a"},
+		UpdatedAfter: time.Now().Add(-time.Minute),
+	})
+	if err != nil || len(page.Tasks) != 2 {
+		t.Fatalf("expected the 2 tasks of team a, got %+v (%v)", page, err)
+	}
+	for _, k := range mr.Keys() {
+		if strings.HasPrefix(k, "test:tmp:") {
+			t.Fatalf("expected the combined index %s deleted after listing", k)
+		}
+	}
+}
+
+func TestRedisStore_Unavailable(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("failed to start miniredis: %v", err)
+	}
 	rs := NewRedisStore(mr.Addr(), "test")
+	mr.Close()
 
-	_, err = rs.Get(ctx, "missing-id")
-	if err == nil {
-		t.Fatalf("expected error for missing id")
+	ctx := context.Background()
+	if _, err := rs.Get(ctx, "id"); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("expected ErrUnavailable from Get, got %v", err)
+	}
+	if _, _, err := rs.CreateOrGetByKey(ctx, "k", &models.Task{Type: "echo", Status: models.StatusQueued}); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("expected ErrUnavailable from CreateOrGetByKey, got %v", err)
+	}
+	if _, err := rs.ListTasks(ctx, ListOptions{}); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("expected ErrUnavailable from ListTasks, got %v", err)
 	}
 }
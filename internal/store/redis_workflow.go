@@ -0,0 +1,74 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/husainaj20/task-manager-api/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+func (r *RedisStore) workflowKey(id string) string { return r.prefix + ":workflow:" + id }
+
+func (r *RedisStore) CreateWorkflow(ctx context.Context, w *models.Workflow, tasks []*models.Task) (*models.Workflow, error) {
+	newWorkflow(w, tasks, time.Now().UTC())
+	wb, err := json.Marshal(w)
+	if err != nil {
+		return nil, err
+	}
+	data := make([][]byte, len(tasks))
+	for i, t := range tasks {
+		if data[i], err = encodeTask(t); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := r.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		for i, t := range tasks {
+			p.Set(ctx, r.key(t.ID), data[i], 0)
+			r.index(ctx, p, nil, t)
+		}
+		p.Set(ctx, r.workflowKey(w.ID), wb, 0)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return cloneWorkflow(w), nil
+}
+
+func (r *RedisStore) GetWorkflow(ctx context.Context, id string) (*models.Workflow, error) {
+	data, err := r.rdb.Get(ctx, r.workflowKey(id)).Result()
+	if err == redis.Nil {
+		return nil, ErrWorkflowNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var w models.Workflow
+	if err := json.Unmarshal([]byte(data), &w); err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// deleteEmptyWorkflow deletes workflow id once none of its tasks is left. Of
+// several tasks deleted at once, at least the last one finds none left.
+func (r *RedisStore) deleteEmptyWorkflow(ctx context.Context, id string) error {
+	w, err := r.GetWorkflow(ctx, id)
+	if err == ErrWorkflowNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(w.Tasks))
+	for _, taskID := range w.Tasks {
+		keys = append(keys, r.key(taskID))
+	}
+	if len(keys) > 0 {
+		if n, err := r.rdb.Exists(ctx, keys...).Result(); err != nil || n > 0 {
+			return err
+		}
+	}
+	return r.rdb.Del(ctx, r.workflowKey(id)).Err()
+}
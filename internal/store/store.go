@@ -2,13 +2,179 @@ package store
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/husainaj20/task-manager-api/internal/models"
 )
 
+// ErrNotFound is returned for a task ID that is not stored.
+var ErrNotFound = errors.New("task not found")
+
+// ErrScheduleNotFound is returned for a schedule ID that is not stored.
+var ErrScheduleNotFound = errors.New("schedule not found")
+
+// ErrWorkflowNotFound is returned for a workflow ID that is not stored.
+var ErrWorkflowNotFound = errors.New("workflow not found")
+
+// ErrVersionMismatch is returned when a write expects a task version that is
+// no longer current.
+var ErrVersionMismatch = errors.New("task version mismatch")
+
+// ErrConflict is returned when a write keeps losing to concurrent writes of
+// the same record and the store gives up; retrying later may succeed.
+var ErrConflict = errors.New("concurrent write conflict")
+
+// ErrUnavailable wraps errors reaching the backend of a networked store,
+// such as a refused connection or a timeout.
+var ErrUnavailable = errors.New("store unavailable")
+
 // Store defines the operations used by the API/service layers.
 type Store interface {
+	ScheduleStore
+	DeadLetterStore
+	PauseStore
+	WorkflowStore
+
+	// CreateOrGetByKey stores t, or, when key is set and still maps to a
+	// task, returns that task and true instead.
 	CreateOrGetByKey(ctx context.Context, key string, t *models.Task) (*models.Task, bool, error)
+	// SetIdempotencyTTL sets how long keys passed to CreateOrGetByKey stay
+	// valid; zero keeps them until their task is deleted.
+	SetIdempotencyTTL(d time.Duration)
 	Get(ctx context.Context, id string) (*models.Task, error)
-	UpdateStatus(ctx context.Context, id string, status string, result map[string]any) error
+	// ListTasks returns a page of tasks matching opts, returning
+	// ErrInvalidCursor for a cursor it did not issue.
+	ListTasks(ctx context.Context, opts ListOptions) (*TaskPage, error)
+	// Update applies fn to the task and writes it back atomically with its
+	// version incremented. If version is non-zero and the stored task has
+	// another version, nothing is written and ErrVersionMismatch is returned.
+	// An error from fn aborts the update.
+	Update(ctx context.Context, id string, version int64, fn func(t *models.Task) error) (*models.Task, error)
+	// UpdateStatus moves a task to status, returning an error wrapping
+	// models.ErrInvalidTransition if the state machine does not allow it.
+	UpdateStatus(ctx context.Context, id string, status models.Status, result map[string]any) error
+	// RecordAttempt appends an execution attempt to the task history.
+	RecordAttempt(ctx context.Context, id string, a models.Attempt) error
+	// Delete removes a task together with its idempotency key. A non-zero
+	// version must match the stored one, as for Update.
+	Delete(ctx context.Context, id string, version int64) error
+	// Expire deletes up to limit tasks in status last updated before cutoff
+	// and reports how many were deleted.
+	Expire(ctx context.Context, status models.Status, cutoff time.Time, limit int) (int, error)
+	// ClaimDue moves up to limit scheduled tasks whose RunAt is not after now
+	// to queued and returns them. A task is only ever claimed by one caller.
+	ClaimDue(ctx context.Context, now time.Time, limit int) ([]*models.Task, error)
+}
+
+// ScheduleStore persists recurring task schedules.
+type ScheduleStore interface {
+	CreateSchedule(ctx context.Context, s *models.Schedule) (*models.Schedule, error)
+	GetSchedule(ctx context.Context, id string) (*models.Schedule, error)
+	ListSchedules(ctx context.Context) ([]*models.Schedule, error)
+	// SetSchedulePaused pauses or resumes a schedule, setting its next run.
+	SetSchedulePaused(ctx context.Context, id string, paused bool, next time.Time) (*models.Schedule, error)
+	DeleteSchedule(ctx context.Context, id string) error
+	// AdvanceSchedule moves an active schedule whose next run is still from
+	// on to next, recording from as its last run. It reports false when the
+	// schedule was already advanced or paused, so among replicas sharing a
+	// store exactly one fires each run.
+	AdvanceSchedule(ctx context.Context, id string, from, next time.Time) (bool, error)
+}
+
+// DeadLetterStore gives access to dead tasks, which keep their final error and
+// attempt history until replayed or purged.
+type DeadLetterStore interface {
+	// ListDead returns dead tasks, least recently updated first.
+	ListDead(ctx context.Context) ([]*models.Task, error)
+	// PurgeDead deletes those of ids that are still dead and reports how
+	// many were deleted.
+	PurgeDead(ctx context.Context, ids []string) (int, error)
+}
+
+// PauseStore persists paused queues and task types, so pauses survive a
+// restart and reach every replica sharing the store.
+type PauseStore interface {
+	// AddPause records p unless its queue or type is already paused, in
+	// which case the earlier pause is kept.
+	AddPause(ctx context.Context, p models.Pause) error
+	// RemovePause deletes the pause of a queue or type, if there is one.
+	RemovePause(ctx context.Context, scope models.PauseScope, name string) error
+	// ListPauses returns all pauses ordered by scope and name.
+	ListPauses(ctx context.Context) ([]models.Pause, error)
+}
+
+// WorkflowStore persists workflows together with their tasks.
+type WorkflowStore interface {
+	// CreateWorkflow stores w and tasks in one transaction: either all of
+	// them are written or none is. Tasks are stored as CreateOrGetByKey
+	// stores them, without idempotency keys.
+	CreateWorkflow(ctx context.Context, w *models.Workflow, tasks []*models.Task) (*models.Workflow, error)
+	GetWorkflow(ctx context.Context, id string) (*models.Workflow, error)
+}
+
+// sortPauses orders pauses by scope and name.
+func sortPauses(ps []models.Pause) {
+	sort.Slice(ps, func(i, j int) bool {
+		if ps[i].Scope != ps[j].Scope {
+			return ps[i].Scope < ps[j].Scope
+		}
+		return ps[i].Name < ps[j].Name
+	})
+}
+
+// newWorkflow prepares w and tasks for CreateWorkflow.
+func newWorkflow(w *models.Workflow, tasks []*models.Task, now time.Time) {
+	if w.ID == "" {
+		w.ID = uuid.NewString()
+	}
+	w.CreatedAt = now
+	for _, t := range tasks {
+		if t.ID == "" {
+			t.ID = uuid.NewString()
+		}
+		t.CreatedAt, t.UpdatedAt = now, now
+		t.Version = 1
+		t.IdempotencyKey = ""
+		t.WorkflowID = w.ID
+	}
+}
+
+func cloneWorkflow(w *models.Workflow) *models.Workflow {
+	c := *w
+	c.Tasks = make(map[string]string, len(w.Tasks))
+	for k, v := range w.Tasks {
+		c.Tasks[k] = v
+	}
+	return &c
+}
+
+// storedTask is a task as the stores that keep JSON documents persist it:
+// with the fields the task itself keeps out of API responses.
+type storedTask struct {
+	*models.Task
+	IdempotencyKey     string `json:"idempotencyKey,omitempty"`
+	RequestFingerprint string `json:"requestFingerprint,omitempty"`
+}
+
+func encodeTask(t *models.Task) ([]byte, error) {
+	return json.Marshal(storedTask{Task: t, IdempotencyKey: t.IdempotencyKey, RequestFingerprint: t.RequestFingerprint})
+}
+
+func decodeTask(data []byte, t *models.Task) error {
+	s := storedTask{Task: t}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	t.IdempotencyKey, t.RequestFingerprint = s.IdempotencyKey, s.RequestFingerprint
+	return nil
+}
+
+// touch marks a task as written: it bumps the version and UpdatedAt.
+func touch(t *models.Task) {
+	t.UpdatedAt = time.Now().UTC()
+	t.Version++
 }
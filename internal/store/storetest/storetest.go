@@ -0,0 +1,661 @@
+// Package storetest is a conformance suite for store.Store implementations.
+// A backend passes it by calling Run from a test:
+//
+//	func TestMyStore(t *testing.T) {
+//		storetest.Run(t, func(t *testing.T) store.Store { return newEmptyStore(t) })
+//	}
+package storetest
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/husainaj20/task-manager-api/internal/models"
+	"github.com/husainaj20/task-manager-api/internal/store"
+)
+
+// Run runs every conformance test against the stores newStore returns. It is
+// called once per test and must return an empty store; the store is used
+// from several goroutines at once.
+func Run(t *testing.T, newStore func(t *testing.T) store.Store) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, st store.Store)
+	}{
+		{"CreateAndGet", testCreateAndGet},
+		{"Idempotency", testIdempotency},
+		{"ConcurrentCreate", testConcurrentCreate},
+		{"NotFound", testNotFound},
+		{"UpdateStatus", testUpdateStatus},
+		{"RecordAttempt", testRecordAttempt},
+		{"CompareAndSet", testCompareAndSet},
+		{"CloneIsolation", testCloneIsolation},
+		{"ListTasks", testListTasks},
+		{"DeleteAndExpire", testDeleteAndExpire},
+		{"ClaimDue", testClaimDue},
+		{"Schedules", testSchedules},
+		{"DeadLetters", testDeadLetters},
+		{"Pauses", testPauses},
+		{"Workflows", testWorkflows},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) { tc.fn(t, newStore(t)) })
+	}
+}
+
+func create(t *testing.T, st store.Store, key string, task *models.Task) *models.Task {
+	t.Helper()
+	created, _, err := st.CreateOrGetByKey(context.Background(), key, task)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	return created
+}
+
+func queued() *models.Task { return &models.Task{Type: "echo", Status: models.StatusQueued} }
+
+func testCreateAndGet(t *testing.T, st store.Store) {
+	runAt := time.Now().UTC().Add(time.Hour).Truncate(time.Millisecond)
+	created := create(t, st, "", &models.Task{
+		Type:     "echo",
+		Payload:  map[string]any{"msg": "hello", "n": float64(2)},
+		Metadata: map[string]string{"team": "a"},
+		Status:   models.StatusScheduled,
+		RunAt:    &runAt,
+		Priority: 7,
+	})
+	if created.ID == "" || created.Version != 1 || created.CreatedAt.IsZero() || !created.UpdatedAt.Equal(created.CreatedAt) {
+		t.Fatalf("unexpected created task %+v", created)
+	}
+	got, err := st.Get(context.Background(), created.ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got.Type != "echo" || got.Status != models.StatusScheduled || got.Payload["msg"] != "hello" || got.Payload["n"] != float64(2) ||
+		got.Metadata["team"] != "a" || got.Priority != 7 || got.RunAt == nil || !got.RunAt.Equal(runAt) || got.Version != 1 || !got.CreatedAt.Equal(created.CreatedAt) {
+		t.Fatalf("stored task differs from created one: %+v", got)
+	}
+}
+
+func testIdempotency(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	first := create(t, st, "k1", &models.Task{Type: "echo", Payload: map[string]any{"msg": "first"}, Status: models.StatusQueued, RequestFingerprint: "fp1"})
+	again, existed, err := st.CreateOrGetByKey(ctx, "k1", &models.Task{Type: "echo", Payload: map[string]any{"msg": "second"}, Status: models.StatusQueued})
+	if err != nil || !existed || again.ID != first.ID || again.Payload["msg"] != "first" || again.RequestFingerprint != "fp1" {
+		t.Fatalf("expected the first task back, got %+v existed=%v (%v)", again, existed, err)
+	}
+	if other, existed, _ := st.CreateOrGetByKey(ctx, "k2", queued()); existed || other.ID == first.ID {
+		t.Fatalf("expected another key to create another task")
+	}
+	a, b := create(t, st, "", queued()), create(t, st, "", queued())
+	if a.ID == b.ID {
+		t.Fatalf("expected creates without a key never to be merged")
+	}
+	if first.IdempotencyKey != "k1" || a.IdempotencyKey != "" {
+		t.Fatalf("expected tasks to record their key, got %q and %q", first.IdempotencyKey, a.IdempotencyKey)
+	}
+	// the key is kept out of the task's JSON, so stores persist it themselves
+	if got, _ := st.Get(ctx, first.ID); got.IdempotencyKey != "k1" || got.RequestFingerprint != "fp1" {
+		t.Fatalf("expected the key and fingerprint stored, got %q and %q", got.IdempotencyKey, got.RequestFingerprint)
+	}
+}
+
+func testConcurrentCreate(t *testing.T, st store.Store) {
+	const callers = 20
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	ids := map[string]bool{}
+	created := 0
+	start := make(chan struct{})
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			task, existed, err := st.CreateOrGetByKey(context.Background(), "shared", queued())
+			if err != nil {
+				t.Errorf("create: %v", err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			ids[task.ID] = true
+			if !existed {
+				created++
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if created != 1 || len(ids) != 1 {
+		t.Fatalf("expected exactly one task created, got %d created and %d distinct ids", created, len(ids))
+	}
+	page, err := st.ListTasks(context.Background(), store.ListOptions{})
+	if err != nil || len(page.Tasks) != 1 {
+		t.Fatalf("expected a single stored task, got %d (%v)", len(page.Tasks), err)
+	}
+}
+
+func testNotFound(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	noop := func(*models.Task) error { return nil }
+	for name, err := range map[string]error{
+		"Get":           second(st.Get(ctx, "missing")),
+		"Update":        second(st.Update(ctx, "missing", 0, noop)),
+		"UpdateStatus":  st.UpdateStatus(ctx, "missing", models.StatusRunning, nil),
+		"RecordAttempt": st.RecordAttempt(ctx, "missing", models.Attempt{Number: 1}),
+		"Delete":        st.Delete(ctx, "missing", 0),
+	} {
+		if !errors.Is(err, store.ErrNotFound) {
+			t.Errorf("%s: expected ErrNotFound, got %v", name, err)
+		}
+	}
+	_, advanceErr := st.AdvanceSchedule(ctx, "missing", time.Now(), time.Now())
+	for name, err := range map[string]error{
+		"GetSchedule":       second(st.GetSchedule(ctx, "missing")),
+		"SetSchedulePaused": second(st.SetSchedulePaused(ctx, "missing", true, time.Now())),
+		"AdvanceSchedule":   advanceErr,
+		"DeleteSchedule":    st.DeleteSchedule(ctx, "missing"),
+	} {
+		if !errors.Is(err, store.ErrScheduleNotFound) {
+			t.Errorf("%s: expected ErrScheduleNotFound, got %v", name, err)
+		}
+	}
+	if n, err := st.PurgeDead(ctx, []string{"missing"}); err != nil || n != 0 {
+		t.Errorf("PurgeDead: expected missing ids to be skipped, got %d (%v)", n, err)
+	}
+}
+
+func second[T any](_ T, err error) error { return err }
+
+func testUpdateStatus(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	task := create(t, st, "", queued())
+	if err := st.UpdateStatus(ctx, task.ID, models.StatusSucceeded, nil); !errors.Is(err, models.ErrInvalidTransition) {
+		t.Fatalf("expected ErrInvalidTransition for queued -> succeeded, got %v", err)
+	}
+	if got, _ := st.Get(ctx, task.ID); got.Status != models.StatusQueued || got.Version != 1 {
+		t.Fatalf("expected a rejected transition to leave the task alone, got %+v", got)
+	}
+	if err := st.UpdateStatus(ctx, task.ID, models.StatusRunning, nil); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if err := st.UpdateStatus(ctx, task.ID, models.StatusSucceeded, map[string]any{"ok": true}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	got, _ := st.Get(ctx, task.ID)
+	if got.Status != models.StatusSucceeded || got.Result["ok"] != true || got.Version != 3 || got.UpdatedAt.Before(got.CreatedAt) {
+		t.Fatalf("unexpected task after updates %+v", got)
+	}
+	if err := st.UpdateStatus(ctx, task.ID, models.StatusRunning, nil); !errors.Is(err, models.ErrInvalidTransition) {
+		t.Fatalf("expected terminal task not to move, got %v", err)
+	}
+}
+
+func testRecordAttempt(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	task := create(t, st, "", queued())
+	now := time.Now().UTC()
+	if err := st.RecordAttempt(ctx, task.ID, models.Attempt{Number: 1, WorkerID: "w-0", StartedAt: now, FinishedAt: now, Error: "boom"}); err != nil {
+		t.Fatalf("record attempt: %v", err)
+	}
+	st.RecordAttempt(ctx, task.ID, models.Attempt{Number: 2, WorkerID: "w-1", StartedAt: now, FinishedAt: now})
+	got, _ := st.Get(ctx, task.ID)
+	if got.Attempts != 2 || got.LastError != "boom" || len(got.History) != 2 || got.History[0].WorkerID != "w-0" || got.History[1].Number != 2 {
+		t.Fatalf("unexpected task after attempts: %+v", got)
+	}
+}
+
+func testCompareAndSet(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	task := create(t, st, "", queued())
+	st.UpdateStatus(ctx, task.ID, models.StatusRunning, nil)
+
+	if _, err := st.Update(ctx, task.ID, 1, func(t *models.Task) error { return t.Transition(models.StatusCancelled) }); !errors.Is(err, store.ErrVersionMismatch) {
+		t.Fatalf("expected ErrVersionMismatch for a stale version, got %v", err)
+	}
+	if _, err := st.Update(ctx, task.ID, 2, func(t *models.Task) error { return errors.New("abort") }); err == nil {
+		t.Fatalf("expected fn error to abort the update")
+	}
+	got, _ := st.Get(ctx, task.ID)
+	if got.Version != 2 || got.Status != models.StatusRunning {
+		t.Fatalf("expected task untouched at version 2, got %+v", got)
+	}
+	updated, err := st.Update(ctx, task.ID, 2, func(t *models.Task) error { return t.Transition(models.StatusCancelled) })
+	if err != nil || updated.Version != 3 || updated.Status != models.StatusCancelled {
+		t.Fatalf("expected cancelled at version 3, got %+v (%v)", updated, err)
+	}
+
+	// concurrent unconditional updates are all kept; stores retrying
+	// optimistic transactions let at least one writer through per round
+	const writers = 8
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := st.Update(ctx, task.ID, 0, func(t *models.Task) error {
+				n, _ := strconv.Atoi(t.Metadata["n"])
+				if t.Metadata == nil {
+					t.Metadata = map[string]string{}
+				}
+				t.Metadata["n"] = strconv.Itoa(n + 1)
+				return nil
+			})
+			if err != nil {
+				t.Errorf("update: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	got, _ = st.Get(ctx, task.ID)
+	if got.Metadata["n"] != strconv.Itoa(writers) || got.Version != 3+writers {
+		t.Fatalf("expected %d updates applied, got n=%s version=%d", writers, got.Metadata["n"], got.Version)
+	}
+
+	if err := st.Delete(ctx, task.ID, 3); !errors.Is(err, store.ErrVersionMismatch) {
+		t.Fatalf("expected ErrVersionMismatch deleting a stale version, got %v", err)
+	}
+	if err := st.Delete(ctx, task.ID, got.Version); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+}
+
+// testCloneIsolation checks that tasks handed in and out of the store do not
+// share state with the stored copy.
+func testCloneIsolation(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	runAt := time.Now().UTC().Add(time.Hour).Truncate(time.Millisecond)
+	in := &models.Task{
+		Type:     "echo",
+		Payload:  map[string]any{"msg": "hello", "nested": map[string]any{"k": "v"}},
+		Metadata: map[string]string{"team": "a"},
+		Status:   models.StatusScheduled,
+		RunAt:    new(time.Time),
+	}
+	*in.RunAt = runAt
+	created := create(t, st, "", in)
+	st.RecordAttempt(ctx, created.ID, models.Attempt{Number: 1, Error: "boom"})
+
+	in.Payload["msg"] = "changed"
+	in.Metadata["team"] = "changed"
+	*in.RunAt = runAt.Add(time.Hour)
+	created.Payload["nested"].(map[string]any)["k"] = "changed"
+
+	out, _ := st.Get(ctx, created.ID)
+	out.Metadata["team"] = "changed"
+	out.History[0].Error = "changed"
+	*out.RunAt = runAt.Add(time.Hour)
+
+	got, _ := st.Get(ctx, created.ID)
+	if got.Payload["msg"] != "hello" || got.Payload["nested"].(map[string]any)["k"] != "v" || got.Metadata["team"] != "a" ||
+		!got.RunAt.Equal(runAt) || got.History[0].Error != "boom" {
+		t.Fatalf("expected the stored task to be isolated from callers, got %+v", got)
+	}
+
+	updated, _ := st.Update(ctx, created.ID, 0, func(t *models.Task) error { return nil })
+	updated.Metadata["team"] = "changed"
+	if got, _ := st.Get(ctx, created.ID); got.Metadata["team"] != "a" {
+		t.Fatalf("expected tasks returned by Update to be copies")
+	}
+}
+
+// testListTasks checks filtering and cursor pagination.
+func testListTasks(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	start := time.Now().UTC()
+	for i := 0; i < 7; i++ {
+		typ, team := "echo", "a"
+		if i%2 == 1 {
+			typ, team = "report", "b"
+		}
+		task := create(t, st, "", &models.Task{Type: typ, Metadata: map[string]string{"team": team}, Status: models.StatusQueued})
+		if i < 2 {
+			st.UpdateStatus(ctx, task.ID, models.StatusRunning, nil)
+		}
+	}
+
+	for _, desc := range []bool{false, true} {
+		seen := map[string]bool{}
+		var prev time.Time
+		opts := store.ListOptions{Limit: 3, Desc: desc}
+		pages := 0
+		for {
+			page, err := st.ListTasks(ctx, opts)
+			if err != nil {
+				t.Fatalf("list: %v", err)
+			}
+			pages++
+			for _, task := range page.Tasks {
+				if seen[task.ID] {
+					t.Fatalf("task %s listed twice", task.ID)
+				}
+				seen[task.ID] = true
+				// the Redis indexes order at millisecond resolution
+				created := task.CreatedAt.Truncate(time.Millisecond)
+				if !prev.IsZero() && (desc && created.After(prev) || !desc && created.Before(prev)) {
+					t.Fatalf("tasks out of order (desc=%v)", desc)
+				}
+				prev = created
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			opts.Cursor = page.NextCursor
+		}
+		if len(seen) != 7 || pages != 3 {
+			t.Fatalf("expected 7 tasks over 3 pages, got %d over %d", len(seen), pages)
+		}
+	}
+
+	cases := []struct {
+		name string
+		opts store.ListOptions
+		want int
+	}{
+		{"status", store.ListOptions{Statuses: []models.Status{models.StatusRunning}}, 2},
+		{"statuses", store.ListOptions{Statuses: []models.Status{models.StatusRunning, models.StatusQueued}}, 7},
+		{"type", store.ListOptions{Type: "report"}, 3},
+		{"type and status", store.ListOptions{Type: "echo", Statuses: []models.Status{models.StatusQueued}}, 3},
+		{"label", store.ListOptions{Labels: map[string]string{"team": "a"}}, 4},
+		{"created range", store.ListOptions{CreatedAfter: start.Add(-time.Minute), CreatedBefore: start.Add(time.Minute)}, 7},
+		{"created before", store.ListOptions{CreatedBefore: start.Add(-time.Minute)}, 0},
+		{"updated after", store.ListOptions{SortBy: store.SortByUpdated, UpdatedAfter: start.Add(time.Minute)}, 0},
+		{"statuses and label", store.ListOptions{Statuses: []models.Status{models.StatusRunning, models.StatusQueued}, Labels: map[string]string{"team": "a"}}, 4},
+		{"type and label", store.ListOptions{Type: "report", Labels: map[string]string{"team": "b"}}, 3},
+		{"updated range", store.ListOptions{UpdatedAfter: start.Add(-time.Minute), UpdatedBefore: start.Add(time.Minute)}, 7},
+		{"label and updated before", store.ListOptions{Labels: map[string]string{"team": "a"}, UpdatedBefore: start.Add(-time.Minute)}, 0},
+	}
+	for _, c := range cases {
+		page, err := st.ListTasks(ctx, c.opts)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if len(page.Tasks) != c.want {
+			t.Errorf("%s: expected %d tasks, got %d", c.name, c.want, len(page.Tasks))
+		}
+	}
+
+	// a relabelled task moves to the index of its new label
+	page, _ := st.ListTasks(ctx, store.ListOptions{Labels: map[string]string{"team": "a"}, Limit: 1})
+	st.Update(ctx, page.Tasks[0].ID, 0, func(t *models.Task) error {
+		t.Metadata["team"] = "c"
+		return nil
+	})
+	for team, want := range map[string]int{"a": 3, "c": 1} {
+		page, err := st.ListTasks(ctx, store.ListOptions{Labels: map[string]string{"team": team}})
+		if err != nil || len(page.Tasks) != want {
+			t.Errorf("relabelled: expected %d tasks of team %s, got %d (%v)", want, team, len(page.Tasks), err)
+		}
+	}
+
+	if _, err := st.ListTasks(ctx, store.ListOptions{Cursor: "bogus!"}); err != store.ErrInvalidCursor {
+		t.Fatalf("expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+// testDeleteAndExpire checks that deleting a task, directly or through
+// Expire, releases its idempotency key and leaves other tasks alone.
+func testDeleteAndExpire(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	finish := func(key string, status models.Status) *models.Task {
+		task := create(t, st, key, queued())
+		st.UpdateStatus(ctx, task.ID, models.StatusRunning, nil)
+		if err := st.UpdateStatus(ctx, task.ID, status, nil); err != nil {
+			t.Fatalf("finish: %v", err)
+		}
+		return task
+	}
+
+	done := finish("k-done", models.StatusSucceeded)
+	if err := st.Delete(ctx, done.ID, 0); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := st.Get(ctx, done.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("expected deleted task to be gone, got %v", err)
+	}
+	if err := st.Delete(ctx, done.ID, 0); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound deleting a missing task, got %v", err)
+	}
+	if _, existed, _ := st.CreateOrGetByKey(ctx, "k-done", queued()); existed {
+		t.Fatalf("expected deleted task to release its idempotency key")
+	}
+
+	old := finish("k-old", models.StatusSucceeded)
+	failed := finish("", models.StatusFailed)
+	now := time.Now().UTC()
+	if n, err := st.Expire(ctx, models.StatusSucceeded, now.Add(-time.Hour), 10); err != nil || n != 0 {
+		t.Fatalf("expected nothing expired before the cutoff, got %d (%v)", n, err)
+	}
+	if n, err := st.Expire(ctx, models.StatusSucceeded, now.Add(time.Hour), 10); err != nil || n != 1 {
+		t.Fatalf("expected 1 expired, got %d (%v)", n, err)
+	}
+	if _, err := st.Get(ctx, old.ID); err == nil {
+		t.Fatalf("expected expired task to be gone")
+	}
+	if _, err := st.Get(ctx, failed.ID); err != nil {
+		t.Fatalf("failed task should be kept: %v", err)
+	}
+	page, _ := st.ListTasks(ctx, store.ListOptions{Statuses: []models.Status{models.StatusSucceeded}})
+	if len(page.Tasks) != 0 {
+		t.Fatalf("expected expired task out of listings, got %d", len(page.Tasks))
+	}
+	if _, existed, _ := st.CreateOrGetByKey(ctx, "k-old", queued()); existed {
+		t.Fatalf("expected expired task to release its idempotency key")
+	}
+}
+
+func testClaimDue(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+	for i := 0; i < 20; i++ {
+		create(t, st, "", &models.Task{Type: "echo", Status: models.StatusScheduled, RunAt: &past})
+	}
+	later := create(t, st, "", &models.Task{Type: "echo", Status: models.StatusScheduled, RunAt: &future})
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	seen := map[string]int{}
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			claimed, err := st.ClaimDue(ctx, time.Now(), 0)
+			if err != nil {
+				t.Errorf("claim: %v", err)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for _, c := range claimed {
+				if c.Status != models.StatusQueued {
+					t.Errorf("expected claimed task queued, got %s", c.Status)
+				}
+				seen[c.ID]++
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(seen) != 20 {
+		t.Fatalf("expected 20 distinct tasks claimed, got %d", len(seen))
+	}
+	for id, n := range seen {
+		if n != 1 {
+			t.Fatalf("task %s claimed %d times", id, n)
+		}
+	}
+	if got, _ := st.Get(ctx, later.ID); got.Status != models.StatusScheduled {
+		t.Fatalf("expected future task to stay scheduled, got %s", got.Status)
+	}
+	if again, _ := st.ClaimDue(ctx, future.Add(time.Minute), 0); len(again) != 1 || again[0].ID != later.ID {
+		t.Fatalf("expected only the later task left to claim, got %d", len(again))
+	}
+}
+
+func testSchedules(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	first := time.Now().UTC().Truncate(time.Minute)
+	sc, err := st.CreateSchedule(ctx, &models.Schedule{Cron: "* * * * *", TaskType: "echo", NextRunAt: first})
+	if err != nil {
+		t.Fatalf("create schedule: %v", err)
+	}
+	if list, err := st.ListSchedules(ctx); err != nil || len(list) != 1 || list[0].ID != sc.ID {
+		t.Fatalf("unexpected list %v (%v)", list, err)
+	}
+	if ok, err := st.AdvanceSchedule(ctx, sc.ID, first, first.Add(time.Minute)); err != nil || !ok {
+		t.Fatalf("expected first advance to win, got %v %v", ok, err)
+	}
+	if ok, _ := st.AdvanceSchedule(ctx, sc.ID, first, first.Add(time.Minute)); ok {
+		t.Fatalf("expected stale advance to lose")
+	}
+	got, _ := st.GetSchedule(ctx, sc.ID)
+	if got.LastRunAt == nil || !got.LastRunAt.Equal(first) || !got.NextRunAt.Equal(first.Add(time.Minute)) {
+		t.Fatalf("unexpected schedule after advance %+v", got)
+	}
+	paused, err := st.SetSchedulePaused(ctx, sc.ID, true, got.NextRunAt)
+	if err != nil || !paused.Paused {
+		t.Fatalf("pause: %+v %v", paused, err)
+	}
+	if ok, _ := st.AdvanceSchedule(ctx, sc.ID, got.NextRunAt, got.NextRunAt.Add(time.Minute)); ok {
+		t.Fatalf("expected paused schedule not to advance")
+	}
+	if err := st.DeleteSchedule(ctx, sc.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := st.GetSchedule(ctx, sc.ID); !errors.Is(err, store.ErrScheduleNotFound) {
+		t.Fatalf("expected not found after delete, got %v", err)
+	}
+}
+
+func testDeadLetters(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	dead := create(t, st, "k1", queued())
+	live := create(t, st, "", queued())
+	kill := func() {
+		for _, s := range []models.Status{models.StatusRunning, models.StatusDead} {
+			if err := st.UpdateStatus(ctx, dead.ID, s, nil); err != nil {
+				t.Fatalf("update: %v", err)
+			}
+		}
+	}
+	kill()
+	st.RecordAttempt(ctx, dead.ID, models.Attempt{Number: 1, Error: "boom"})
+
+	list, err := st.ListDead(ctx)
+	if err != nil || len(list) != 1 || list[0].ID != dead.ID || list[0].LastError != "boom" {
+		t.Fatalf("unexpected dead list %+v (%v)", list, err)
+	}
+	// replaying takes the task out of the dead-letter queue
+	if err := st.UpdateStatus(ctx, dead.ID, models.StatusQueued, nil); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if list, _ := st.ListDead(ctx); len(list) != 0 {
+		t.Fatalf("expected no dead tasks after replay, got %d", len(list))
+	}
+	if n, _ := st.PurgeDead(ctx, []string{dead.ID}); n != 0 {
+		t.Fatalf("expected queued task not to be purged")
+	}
+
+	kill()
+	n, err := st.PurgeDead(ctx, []string{dead.ID, live.ID})
+	if err != nil || n != 1 {
+		t.Fatalf("expected 1 purged, got %d (%v)", n, err)
+	}
+	if _, err := st.Get(ctx, dead.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("expected purged task to be gone, got %v", err)
+	}
+	if _, err := st.Get(ctx, live.ID); err != nil {
+		t.Fatalf("live task should survive purge: %v", err)
+	}
+	if _, existed, _ := st.CreateOrGetByKey(ctx, "k1", queued()); existed {
+		t.Fatalf("expected purged task to free its idempotency key")
+	}
+}
+
+func testPauses(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	if ps, err := st.ListPauses(ctx); err != nil || len(ps) != 0 {
+		t.Fatalf("expected no pauses, got %v (%v)", ps, err)
+	}
+	first := time.Now().UTC().Add(-time.Minute).Truncate(time.Millisecond)
+	for _, p := range []models.Pause{
+		{Scope: models.PauseTaskType, Name: "echo", PausedAt: first},
+		{Scope: models.PauseQueue, Name: "emails", PausedAt: first},
+		{Scope: models.PauseQueue, Name: "default", PausedAt: first},
+		{Scope: models.PauseQueue, Name: "emails", PausedAt: time.Now().UTC()},
+	} {
+		if err := st.AddPause(ctx, p); err != nil {
+			t.Fatalf("add pause: %v", err)
+		}
+	}
+	ps, err := st.ListPauses(ctx)
+	if err != nil || len(ps) != 3 {
+		t.Fatalf("expected 3 pauses, got %v (%v)", ps, err)
+	}
+	if ps[0].Scope != models.PauseQueue || ps[0].Name != "default" || ps[1].Name != "emails" || ps[2].Scope != models.PauseTaskType {
+		t.Fatalf("expected pauses ordered by scope and name, got %v", ps)
+	}
+	if !ps[1].PausedAt.Equal(first) {
+		t.Fatalf("expected pausing again to keep the first pause, got %v", ps[1].PausedAt)
+	}
+
+	if err := st.RemovePause(ctx, models.PauseQueue, "emails"); err != nil {
+		t.Fatalf("remove pause: %v", err)
+	}
+	if err := st.RemovePause(ctx, models.PauseQueue, "missing"); err != nil {
+		t.Fatalf("expected removing a missing pause to succeed, got %v", err)
+	}
+	if ps, _ := st.ListPauses(ctx); len(ps) != 2 || ps[0].Name != "default" || ps[1].Name != "echo" {
+		t.Fatalf("unexpected pauses after remove: %v", ps)
+	}
+}
+
+func testWorkflows(t *testing.T, st store.Store) {
+	ctx := context.Background()
+	root := &models.Task{ID: "wf-root", Type: "echo", Status: models.StatusQueued}
+	child := &models.Task{ID: "wf-load", Type: "echo", Status: models.StatusBlocked, DependsOn: []string{"wf-root"}}
+	w, err := st.CreateWorkflow(ctx, &models.Workflow{Name: "etl", Tasks: map[string]string{"extract": "wf-root", "load": "wf-load"}},
+		[]*models.Task{root, child})
+	if err != nil {
+		t.Fatalf("create workflow: %v", err)
+	}
+	if w.ID == "" || w.CreatedAt.IsZero() || child.WorkflowID != w.ID {
+		t.Fatalf("expected IDs and creation time set, got %+v and task %+v", w, child)
+	}
+
+	got, err := st.GetWorkflow(ctx, w.ID)
+	if err != nil || got.Name != "etl" || got.Tasks["extract"] != "wf-root" || !got.CreatedAt.Equal(w.CreatedAt) {
+		t.Fatalf("unexpected stored workflow %+v (%v)", got, err)
+	}
+	stored, err := st.Get(ctx, child.ID)
+	if err != nil || stored.Status != models.StatusBlocked || stored.Version != 1 || len(stored.DependsOn) != 1 || stored.DependsOn[0] != "wf-root" {
+		t.Fatalf("unexpected stored task %+v (%v)", stored, err)
+	}
+	page, err := st.ListTasks(ctx, store.ListOptions{Statuses: []models.Status{models.StatusBlocked}})
+	if err != nil || len(page.Tasks) != 1 || page.Tasks[0].ID != child.ID {
+		t.Fatalf("expected the blocked task listed, got %+v (%v)", page, err)
+	}
+	if _, err := st.GetWorkflow(ctx, "missing"); !errors.Is(err, store.ErrWorkflowNotFound) {
+		t.Fatalf("expected ErrWorkflowNotFound, got %v", err)
+	}
+
+	// the workflow goes with its last task
+	if err := st.Delete(ctx, root.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.GetWorkflow(ctx, w.ID); err != nil {
+		t.Fatalf("expected the workflow kept while a task is left, got %v", err)
+	}
+	if err := st.Delete(ctx, child.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.GetWorkflow(ctx, w.ID); !errors.Is(err, store.ErrWorkflowNotFound) {
+		t.Fatalf("expected the workflow deleted with its last task, got %v", err)
+	}
+}